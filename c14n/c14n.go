@@ -0,0 +1,87 @@
+// Package c14n implements the Canonical XML Version 1.0 and the Exclusive XML Canonicalization Version 1.0
+// W3C recommendations, with and without comments.
+//
+// The canonical form of an XML document is physically different from the input
+// only in the changes permitted by the XML and Namespaces in XML specifications,
+// so two logically equivalent documents have the same canonical form.
+// It is used to digest and sign XML, such as the XML Digital Signatures of OPC packages.
+//
+// A whole document can be canonicalized from an io.Reader using Canonicalize.
+// Document subsets, such as the element referenced by a signature, can be canonicalized
+// writing their xml.Token stream to an Encoder, whose context has been set with the
+// namespace declarations and attributes inherited from the ancestors of the subset.
+package c14n
+
+import (
+	"io"
+)
+
+// Method is an enumerable for the canonicalization methods.
+type Method int
+
+const (
+	// Canonical is the Canonical XML Version 1.0 method, which omits comments.
+	Canonical Method = iota
+	// CanonicalWithComments is the Canonical XML Version 1.0 method, which keeps the comments.
+	CanonicalWithComments
+	// Exclusive is the Exclusive XML Canonicalization Version 1.0 method, which omits comments.
+	Exclusive
+	// ExclusiveWithComments is the Exclusive XML Canonicalization Version 1.0 method, which keeps the comments.
+	ExclusiveWithComments
+)
+
+var methodURIs = [...]string{
+	Canonical:             "http://www.w3.org/TR/2001/REC-xml-c14n-20010315",
+	CanonicalWithComments: "http://www.w3.org/TR/2001/REC-xml-c14n-20010315#WithComments",
+	Exclusive:             "http://www.w3.org/2001/10/xml-exc-c14n#",
+	ExclusiveWithComments: "http://www.w3.org/2001/10/xml-exc-c14n#WithComments",
+}
+
+// String returns the algorithm identifier of the method, as used in XML Signatures.
+func (m Method) String() string {
+	if m < 0 || int(m) >= len(methodURIs) {
+		return ""
+	}
+	return methodURIs[m]
+}
+
+// MethodByURI returns the method identified by the algorithm URI.
+func MethodByURI(uri string) (Method, bool) {
+	for m, u := range methodURIs {
+		if u == uri {
+			return Method(m), true
+		}
+	}
+	return 0, false
+}
+
+// WithComments reports whether the comments are kept by the method.
+func (m Method) WithComments() bool {
+	return m == CanonicalWithComments || m == ExclusiveWithComments
+}
+
+// IsExclusive reports whether m is an Exclusive XML Canonicalization method.
+func (m Method) IsExclusive() bool {
+	return m == Exclusive || m == ExclusiveWithComments
+}
+
+// Canonicalize writes to w the canonical form of the XML document read from r.
+// The document is decoded using a Decoder, so the document type declaration
+// internal subset is taken into account.
+func Canonicalize(w io.Writer, r io.Reader, m Method) error {
+	d := NewDecoder(r)
+	e := NewEncoder(w, m)
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err = e.EncodeToken(t); err != nil {
+			return err
+		}
+	}
+	return e.Close()
+}
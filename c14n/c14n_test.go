@@ -0,0 +1,250 @@
+package c14n
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// The inputs and outputs are the examples of the Canonical XML Version 1.0
+// and the Exclusive XML Canonicalization Version 1.0 recommendations.
+
+const w3cPIs = `<?xml version="1.0"?>
+
+<?xml-stylesheet   href="doc.xsl"
+   type="text/xsl"   ?>
+
+<!DOCTYPE doc SYSTEM "doc.dtd">
+
+<doc>Hello, world!<!-- Comment 1 --></doc>
+
+<?pi-without-data     ?>
+
+<!-- Comment 2 -->
+
+<!-- Comment 3 -->`
+
+const w3cWhitespace = `<doc>
+   <clean>   </clean>
+   <dirty>   A   B   </dirty>
+   <mixed>
+      A
+      <clean>   </clean>
+      B
+      <dirty>   A   B   </dirty>
+      C
+   </mixed>
+</doc>`
+
+const w3cTags = `<!DOCTYPE doc [<!ATTLIST e9 attr CDATA "default">]>
+<doc>
+   <e1   />
+   <e2   ></e2>
+   <e3   name = "elem3"   id="elem3"   />
+   <e4   name="elem4"   id="elem4"   ></e4>
+   <e5 a:attr="out" b:attr="sorted" attr2="all" attr="I'm"
+      xmlns:b="http://www.ietf.org"
+      xmlns:a="http://www.w3.org"
+      xmlns="http://example.org"/>
+   <e6 xmlns="" xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="" xmlns:a="http://www.w3.org">
+            <e9 xmlns="" xmlns:a="http://www.ietf.org"/>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+
+const w3cTagsOut = `<doc>
+   <e1></e1>
+   <e2></e2>
+   <e3 id="elem3" name="elem3"></e3>
+   <e4 id="elem4" name="elem4"></e4>
+   <e5 xmlns="http://example.org" xmlns:a="http://www.w3.org" xmlns:b="http://www.ietf.org" attr="I'm" attr2="all" b:attr="sorted" a:attr="out"></e5>
+   <e6 xmlns:a="http://www.w3.org">
+      <e7 xmlns="http://www.ietf.org">
+         <e8 xmlns="">
+            <e9 xmlns:a="http://www.ietf.org" attr="default"></e9>
+         </e8>
+      </e7>
+   </e6>
+</doc>`
+
+const w3cCharacters = `<!DOCTYPE doc [
+<!ATTLIST normId id ID #IMPLIED>
+<!ATTLIST normNames attr NMTOKENS #IMPLIED>
+]>
+<doc>
+   <text>First line&#x0d;&#10;Second line</text>
+   <value>&#x32;</value>
+   <compute><![CDATA[value>"0" && value<"10" ?"valid":"error"]]></compute>
+   <compute expr='value>"0" &amp;&amp; value&lt;"10" ?"valid":"error"'>valid</compute>
+   <norm attr=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+   <normNames attr='   A   &#x20;&#13;&#xa;&#9;   B   '/>
+   <normId id=' &apos;   &#x20;&#13;&#xa;&#9;   &apos; '/>
+</doc>`
+
+const w3cCharactersOut = `<doc>
+   <text>First line&#xD;
+Second line</text>
+   <value>2</value>
+   <compute>value&gt;"0" &amp;&amp; value&lt;"10" ?"valid":"error"</compute>
+   <compute expr="value>&quot;0&quot; &amp;&amp; value&lt;&quot;10&quot; ?&quot;valid&quot;:&quot;error&quot;">valid</compute>
+   <norm attr=" '    &#xD;&#xA;&#x9;   ' "></norm>
+   <normNames attr="A &#xD;&#xA;&#x9; B"></normNames>
+   <normId id="' &#xD;&#xA;&#x9; '"></normId>
+</doc>`
+
+const w3cEntities = `<!DOCTYPE doc [
+<!ATTLIST doc attrExtEnt ENTITY #IMPLIED>
+<!ENTITY ent1 "Hello">
+<!ENTITY ent2 SYSTEM "world.txt">
+<!ENTITY entExt SYSTEM "earth.gif" NDATA gif>
+<!NOTATION gif SYSTEM "viewgif.exe">
+]>
+<doc attrExtEnt="entExt">
+   &ent1;, &ent2;!
+</doc>
+
+<!-- Let world.txt contain "world" (excluding the quotes) -->`
+
+const excSubset = `<n0:local xmlns:n0="foo:bar" xmlns:n3="ftp://example.org"><n1:elem2 xmlns:n1="http://example.net" xml:lang="en">
+       <n3:stuff xmlns:n3="ftp://example.org"/>
+   </n1:elem2></n0:local>`
+
+func TestCanonicalize(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		m       Method
+		want    string
+		wantErr bool
+	}{
+		{"pis", w3cPIs, Canonical, "<?xml-stylesheet href=\"doc.xsl\"\n   type=\"text/xsl\"   ?>\n<doc>Hello, world!</doc>\n<?pi-without-data?>", false},
+		{"pisWithComments", w3cPIs, CanonicalWithComments, "<?xml-stylesheet href=\"doc.xsl\"\n   type=\"text/xsl\"   ?>\n" +
+			"<doc>Hello, world!<!-- Comment 1 --></doc>\n<?pi-without-data?>\n<!-- Comment 2 -->\n<!-- Comment 3 -->", false},
+		{"whitespace", w3cWhitespace, Canonical, w3cWhitespace, false},
+		{"tags", w3cTags, Canonical, w3cTagsOut, false},
+		{"characters", w3cCharacters, Canonical, w3cCharactersOut, false},
+		{"characterCRLF", "<doc>a\r\nb\rc&#13;</doc>", Canonical, "<doc>a\nb\nc&#xD;</doc>", false},
+		{"latin1", "<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?>\n<doc>&#169;\xa9</doc>", Canonical, "<doc>©©</doc>", false},
+		{"utf16", "\xff\xfe<\x00a\x00>\x00\xe9\x00<\x00/\x00a\x00>\x00", Canonical, "<a>é</a>", false},
+		{"markupEntity", `<!DOCTYPE a [<!ENTITY e "<b x='&#38;#60;'>&#38;amp;</b>">]><a>&e;</a>`, Canonical, `<a><b x="&lt;">&amp;</b></a>`, false},
+		{"exclusive", `<a xmlns="urn:a" xmlns:p="urn:p" xmlns:q="urn:q"><p:b q:c="1"><d/></p:b></a>`, Exclusive,
+			`<a xmlns="urn:a"><p:b xmlns:p="urn:p" xmlns:q="urn:q" q:c="1"><d></d></p:b></a>`, false},
+		{"exclusiveWithComments", `<a><!--c--></a>`, ExclusiveWithComments, `<a><!--c--></a>`, false},
+		{"externalEntity", w3cEntities, Canonical, "", true},
+		{"recursiveEntity", `<!DOCTYPE a [<!ENTITY e "&e;">]><a>&e;</a>`, Canonical, "", true},
+		{"unclosed", "<a><b></a>", Canonical, "", true},
+		{"twoRoots", "<a/><b/>", Canonical, "", true},
+		{"textOutsideRoot", "<a/>b", Canonical, "", true},
+		{"undeclaredPrefix", "<p:a/>", Exclusive, "", true},
+		{"unsupportedEncoding", `<?xml version="1.0" encoding="EBCDIC"?><a/>`, Canonical, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			err := Canonicalize(&buf, strings.NewReader(tt.in), tt.m)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Canonicalize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := buf.String(); !tt.wantErr && got != tt.want {
+				t.Errorf("Canonicalize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecoder_Entity(t *testing.T) {
+	d := NewDecoder(strings.NewReader(w3cEntities))
+	d.Entity = map[string]string{"ent2": "world"}
+	var buf bytes.Buffer
+	e := NewEncoder(&buf, Canonical)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Decoder.Token() error = %v", err)
+		}
+		e.EncodeToken(tok)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatalf("Encoder.Close() error = %v", err)
+	}
+	want := "<doc attrExtEnt=\"entExt\">\n   Hello, world!\n</doc>"
+	if got := buf.String(); got != want {
+		t.Errorf("Canonicalize() = %v, want %v", got, want)
+	}
+}
+
+func TestEncoder_subset(t *testing.T) {
+	tests := []struct {
+		name      string
+		m         Method
+		inclusive []string
+		want      string
+	}{
+		{"canonical", Canonical, nil, "<n1:elem2 xmlns:n0=\"foo:bar\" xmlns:n1=\"http://example.net\" xmlns:n3=\"ftp://example.org\" xml:lang=\"en\">\n" +
+			"       <n3:stuff></n3:stuff>\n   </n1:elem2>"},
+		{"exclusive", Exclusive, nil, "<n1:elem2 xmlns:n1=\"http://example.net\" xml:lang=\"en\">\n" +
+			"       <n3:stuff xmlns:n3=\"ftp://example.org\"></n3:stuff>\n   </n1:elem2>"},
+		{"inclusiveNamespaces", Exclusive, []string{"n0", "#default"}, "<n1:elem2 xmlns:n0=\"foo:bar\" xmlns:n1=\"http://example.net\" xml:lang=\"en\">\n" +
+			"       <n3:stuff xmlns:n3=\"ftp://example.org\"></n3:stuff>\n   </n1:elem2>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewDecoder(strings.NewReader(excSubset))
+			var buf bytes.Buffer
+			e := NewEncoder(&buf, tt.m)
+			e.SetInclusiveNamespaces(tt.inclusive...)
+			var depth int
+			for {
+				tok, err := d.Token()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Decoder.Token() error = %v", err)
+				}
+				switch tok := tok.(type) {
+				case xml.StartElement:
+					depth++
+					if depth == 1 {
+						e.SetContext(tok.Attr)
+						continue
+					}
+				case xml.EndElement:
+					depth--
+					if depth == 0 {
+						continue
+					}
+				}
+				if err = e.EncodeToken(tok); err != nil {
+					t.Fatalf("Encoder.EncodeToken() error = %v", err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("Encoder.Close() error = %v", err)
+			}
+			if got := buf.String(); got != tt.want {
+				t.Errorf("Encoder.EncodeToken() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMethodByURI(t *testing.T) {
+	for _, m := range []Method{Canonical, CanonicalWithComments, Exclusive, ExclusiveWithComments} {
+		got, ok := MethodByURI(m.String())
+		if !ok || got != m {
+			t.Errorf("MethodByURI(%s) = %v, %v", m, got, ok)
+		}
+	}
+	if _, ok := MethodByURI("urn:other"); ok {
+		t.Error("MethodByURI() want false")
+	}
+}
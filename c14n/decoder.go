@@ -0,0 +1,840 @@
+package c14n
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var predefinedEntities = map[string]string{
+	"lt":   "<",
+	"gt":   ">",
+	"amp":  "&",
+	"apos": "'",
+	"quot": `"`,
+}
+
+var lineEndings = strings.NewReplacer("\r\n", "\n", "\r", "\n")
+
+// A Decoder reads an XML document applying the changes required by the XPath data model,
+// which is the input of the canonicalization methods:
+//   - The document is converted to UTF-8 and the line endings are normalized.
+//   - The XML declaration and the document type declaration are not returned.
+//   - Character and entity references are replaced by their values.
+//   - CDATA sections are returned as character data.
+//   - Attribute values are normalized and the default attributes are added.
+//   - Whitespace outside the document element is not returned.
+//
+// The document type declaration internal subset is processed, but no external resource is ever read.
+//
+// The tokens have the same form as the ones returned by xml.Decoder.RawToken:
+// the Space of the names holds the namespace prefix and the namespace declarations
+// are returned as attributes.
+type Decoder struct {
+	// Entity maps the names of the external parsed entities to their replacement text.
+	// A reference to an external entity not defined in Entity is an error.
+	Entity map[string]string
+
+	r           io.Reader
+	inputs      []*input
+	entities    map[string]*entity
+	attlists    map[string][]*attDecl
+	active      map[string]bool
+	stack       []xml.Name
+	needClose   bool
+	rootSeen    bool
+	doctypeSeen bool
+	skipDecls   bool
+	err         error
+}
+
+// input is a replacement text being parsed. The first input is the document entity.
+type input struct {
+	s      string
+	pos    int
+	entity string
+}
+
+type entity struct {
+	value    string
+	external bool
+	unparsed bool
+}
+
+type attDecl struct {
+	name       string
+	typ        string
+	value      string
+	hasDefault bool
+}
+
+// NewDecoder returns a new decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{
+		r:        r,
+		entities: make(map[string]*entity),
+		attlists: make(map[string][]*attDecl),
+		active:   make(map[string]bool),
+	}
+}
+
+// Token returns the next XML token in the input stream.
+// At the end of the input stream, Token returns nil, io.EOF.
+// The document is read entirely from the underlying reader on the first call.
+func (d *Decoder) Token() (xml.Token, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	t, err := d.token()
+	if err != nil {
+		d.err = err
+		return nil, err
+	}
+	return t, nil
+}
+
+func (d *Decoder) init() error {
+	b, err := ioutil.ReadAll(d.r)
+	if err != nil {
+		return err
+	}
+	s, err := decodeText(b)
+	if err != nil {
+		return err
+	}
+	in := &input{s: lineEndings.Replace(s)}
+	if strings.HasPrefix(in.s, "<?xml") && len(in.s) > 5 && isSpace(in.s[5]) {
+		end := strings.Index(in.s, "?>")
+		if end < 0 {
+			return d.syntaxError("unterminated XML declaration")
+		}
+		in.pos = end + 2
+	}
+	d.inputs = []*input{in}
+	return nil
+}
+
+func (d *Decoder) token() (xml.Token, error) {
+	if d.inputs == nil {
+		if err := d.init(); err != nil {
+			return nil, err
+		}
+	}
+	if d.needClose {
+		d.needClose = false
+		return d.popElement(), nil
+	}
+	for {
+		in := d.inputs[len(d.inputs)-1]
+		if in.pos >= len(in.s) {
+			if len(d.inputs) > 1 {
+				delete(d.active, in.entity)
+				d.inputs = d.inputs[:len(d.inputs)-1]
+				continue
+			}
+			if len(d.stack) > 0 {
+				return nil, d.syntaxError("unexpected EOF")
+			}
+			if !d.rootSeen {
+				return nil, d.syntaxError("missing document element")
+			}
+			return nil, io.EOF
+		}
+		switch {
+		case in.hasPrefix("<?"):
+			return d.procInst(in)
+		case in.hasPrefix("<!--"):
+			return d.comment(in)
+		case in.hasPrefix("<![CDATA["):
+			return d.cdata(in)
+		case in.hasPrefix("<!DOCTYPE"):
+			if err := d.doctype(in); err != nil {
+				return nil, err
+			}
+		case in.hasPrefix("</"):
+			return d.endElement(in)
+		case in.hasPrefix("<"):
+			return d.startElement(in)
+		case in.hasPrefix("&"):
+			t, err := d.reference(in)
+			if t != nil || err != nil {
+				return t, err
+			}
+		default:
+			rest := in.s[in.pos:]
+			i := strings.IndexAny(rest, "<&")
+			if i < 0 {
+				i = len(rest)
+			}
+			in.pos += i
+			if len(d.stack) > 0 {
+				return xml.CharData(rest[:i]), nil
+			}
+			if strings.Trim(rest[:i], " \t\n") != "" {
+				return nil, d.syntaxError("character data outside the document element")
+			}
+		}
+	}
+}
+
+func (d *Decoder) procInst(in *input) (xml.Token, error) {
+	in.pos += len("<?")
+	target := in.name()
+	if target == "" {
+		return nil, d.syntaxError("invalid processing instruction target")
+	}
+	if strings.EqualFold(target, "xml") {
+		return nil, d.syntaxError("misplaced XML declaration")
+	}
+	end := strings.Index(in.s[in.pos:], "?>")
+	if end < 0 {
+		return nil, d.syntaxError("unterminated processing instruction")
+	}
+	inst := in.s[in.pos : in.pos+end]
+	in.pos += end + len("?>")
+	if inst != "" && !isSpace(inst[0]) {
+		return nil, d.syntaxError("invalid processing instruction target")
+	}
+	return xml.ProcInst{Target: target, Inst: []byte(strings.TrimLeft(inst, " \t\n"))}, nil
+}
+
+func (d *Decoder) comment(in *input) (xml.Token, error) {
+	in.pos += len("<!--")
+	end := strings.Index(in.s[in.pos:], "-->")
+	if end < 0 {
+		return nil, d.syntaxError("unterminated comment")
+	}
+	text := in.s[in.pos : in.pos+end]
+	in.pos += end + len("-->")
+	return xml.Comment(text), nil
+}
+
+func (d *Decoder) cdata(in *input) (xml.Token, error) {
+	if len(d.stack) == 0 {
+		return nil, d.syntaxError("CDATA section outside the document element")
+	}
+	in.pos += len("<![CDATA[")
+	end := strings.Index(in.s[in.pos:], "]]>")
+	if end < 0 {
+		return nil, d.syntaxError("unterminated CDATA section")
+	}
+	text := in.s[in.pos : in.pos+end]
+	in.pos += end + len("]]>")
+	return xml.CharData(text), nil
+}
+
+func (d *Decoder) startElement(in *input) (xml.Token, error) {
+	if len(d.stack) == 0 && d.rootSeen {
+		return nil, d.syntaxError("more than one document element")
+	}
+	in.pos++
+	name := in.name()
+	if name == "" {
+		return nil, d.syntaxError("invalid element name")
+	}
+	var attrs []xml.Attr
+	for {
+		sp := in.skipSpace()
+		if in.consume("/>") {
+			d.needClose = true
+			break
+		}
+		if in.consume(">") {
+			break
+		}
+		an := in.name()
+		if !sp || an == "" {
+			return nil, d.syntaxError("invalid attribute in element " + name)
+		}
+		in.skipSpace()
+		if !in.consume("=") {
+			return nil, d.syntaxError("attribute " + an + " without value")
+		}
+		in.skipSpace()
+		raw, ok := in.quoted()
+		if !ok {
+			return nil, d.syntaxError("unquoted or unterminated value of attribute " + an)
+		}
+		v, err := d.attrValue(raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attrs {
+			if qualifiedName(a.Name) == an {
+				return nil, d.syntaxError("attribute " + an + " redefined")
+			}
+		}
+		attrs = append(attrs, xml.Attr{Name: splitName(an), Value: v})
+	}
+	attrs = d.applyAttlist(name, attrs)
+	n := splitName(name)
+	d.rootSeen = true
+	d.stack = append(d.stack, n)
+	return xml.StartElement{Name: n, Attr: attrs}, nil
+}
+
+// applyAttlist normalizes the values of the declared attributes whose type is not CDATA
+// and adds the declared default values of the missing attributes.
+func (d *Decoder) applyAttlist(name string, attrs []xml.Attr) []xml.Attr {
+	decls := d.attlists[name]
+	for i := range attrs {
+		for _, ad := range decls {
+			if ad.name == qualifiedName(attrs[i].Name) && ad.typ != "CDATA" {
+				attrs[i].Value = collapseSpaces(attrs[i].Value)
+			}
+		}
+	}
+	for _, ad := range decls {
+		if !ad.hasDefault {
+			continue
+		}
+		found := false
+		for _, a := range attrs {
+			if qualifiedName(a.Name) == ad.name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			attrs = append(attrs, xml.Attr{Name: splitName(ad.name), Value: ad.value})
+		}
+	}
+	return attrs
+}
+
+func (d *Decoder) endElement(in *input) (xml.Token, error) {
+	in.pos += len("</")
+	name := in.name()
+	in.skipSpace()
+	if !in.consume(">") {
+		return nil, d.syntaxError("invalid end element " + name)
+	}
+	if len(d.stack) == 0 {
+		return nil, d.syntaxError("unexpected end element </" + name + ">")
+	}
+	if top := qualifiedName(d.stack[len(d.stack)-1]); top != name {
+		return nil, d.syntaxError("element <" + top + "> closed by </" + name + ">")
+	}
+	return d.popElement(), nil
+}
+
+func (d *Decoder) popElement() xml.Token {
+	n := d.stack[len(d.stack)-1]
+	d.stack = d.stack[:len(d.stack)-1]
+	return xml.EndElement{Name: n}
+}
+
+// reference returns the value of a character reference or of a predefined entity.
+// Other entity references are expanded pushing their replacement text as a new input,
+// in which case the returned token is nil.
+func (d *Decoder) reference(in *input) (xml.Token, error) {
+	if len(d.stack) == 0 {
+		return nil, d.syntaxError("reference outside the document element")
+	}
+	semi := strings.IndexByte(in.s[in.pos:], ';')
+	if semi < 0 {
+		return nil, d.syntaxError("unterminated reference")
+	}
+	ref := in.s[in.pos+1 : in.pos+semi]
+	in.pos += semi + 1
+	if strings.HasPrefix(ref, "#") {
+		r, ok := charRef(ref)
+		if !ok {
+			return nil, d.syntaxError("invalid character reference &" + ref + ";")
+		}
+		return xml.CharData(string(r)), nil
+	}
+	if v, ok := predefinedEntities[ref]; ok {
+		return xml.CharData(v), nil
+	}
+	if d.active[ref] {
+		return nil, d.syntaxError("recursive reference to entity " + ref)
+	}
+	var text string
+	e, ok := d.entities[ref]
+	switch {
+	case ok && e.unparsed:
+		return nil, d.syntaxError("reference to unparsed entity " + ref)
+	case ok && !e.external:
+		text = e.value
+	default:
+		v, ok := d.Entity[ref]
+		if !ok {
+			return nil, fmt.Errorf("c14n: entity %s not defined", ref)
+		}
+		text = lineEndings.Replace(v)
+	}
+	d.active[ref] = true
+	d.inputs = append(d.inputs, &input{s: text, entity: ref})
+	return nil, nil
+}
+
+// attrValue returns the normalized value of an attribute, as defined in XML 1.0 §3.3.3,
+// excluding the additional normalization of the attributes whose type is not CDATA.
+func (d *Decoder) attrValue(raw string) (string, error) {
+	var b strings.Builder
+	if err := d.normalizeAttr(&b, raw); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+func (d *Decoder) normalizeAttr(b *strings.Builder, s string) error {
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case '<':
+			return d.syntaxError("unescaped < in attribute value")
+		case '\t', '\n', '\r':
+			b.WriteByte(' ')
+			i++
+		case '&':
+			semi := strings.IndexByte(s[i:], ';')
+			if semi < 0 {
+				return d.syntaxError("unterminated reference in attribute value")
+			}
+			ref := s[i+1 : i+semi]
+			i += semi + 1
+			if strings.HasPrefix(ref, "#") {
+				r, ok := charRef(ref)
+				if !ok {
+					return d.syntaxError("invalid character reference &" + ref + ";")
+				}
+				b.WriteRune(r)
+				continue
+			}
+			if v, ok := predefinedEntities[ref]; ok {
+				b.WriteString(v)
+				continue
+			}
+			e, ok := d.entities[ref]
+			if !ok || e.external {
+				return d.syntaxError("invalid entity " + ref + " in attribute value")
+			}
+			if d.active[ref] {
+				return d.syntaxError("recursive reference to entity " + ref)
+			}
+			d.active[ref] = true
+			err := d.normalizeAttr(b, e.value)
+			delete(d.active, ref)
+			if err != nil {
+				return err
+			}
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	return nil
+}
+
+func (d *Decoder) doctype(in *input) error {
+	if d.doctypeSeen || d.rootSeen || len(d.inputs) > 1 {
+		return d.syntaxError("misplaced document type declaration")
+	}
+	d.doctypeSeen = true
+	in.pos += len("<!DOCTYPE")
+	if !in.skipSpace() || in.name() == "" {
+		return d.syntaxError("invalid document type declaration")
+	}
+	in.skipSpace()
+	if _, ok := in.externalID(); !ok {
+		return d.syntaxError("invalid document type external identifier")
+	}
+	in.skipSpace()
+	if in.consume("[") {
+		if err := d.internalSubset(in); err != nil {
+			return err
+		}
+		in.skipSpace()
+	}
+	if !in.consume(">") {
+		return d.syntaxError("unterminated document type declaration")
+	}
+	return nil
+}
+
+func (d *Decoder) internalSubset(in *input) error {
+	for {
+		in.skipSpace()
+		var err error
+		switch {
+		case in.consume("]"):
+			return nil
+		case in.hasPrefix("<!--"), in.hasPrefix("<?"):
+			end := "-->"
+			if in.hasPrefix("<?") {
+				end = "?>"
+			}
+			i := strings.Index(in.s[in.pos:], end)
+			if i < 0 {
+				return d.syntaxError("unterminated markup in document type declaration")
+			}
+			in.pos += i + len(end)
+		case in.consume("<!ENTITY"):
+			err = d.entityDecl(in)
+		case in.consume("<!ATTLIST"):
+			err = d.attlistDecl(in)
+		case in.hasPrefix("<!ELEMENT"), in.hasPrefix("<!NOTATION"):
+			if !in.skipDecl() {
+				return d.syntaxError("unterminated markup declaration")
+			}
+		case in.hasPrefix("%"):
+			// Parameter entities are not read, so the declarations
+			// that follow may depend on them and are not processed.
+			i := strings.IndexByte(in.s[in.pos:], ';')
+			if i < 0 {
+				return d.syntaxError("unterminated parameter entity reference")
+			}
+			in.pos += i + 1
+			d.skipDecls = true
+		default:
+			return d.syntaxError("invalid document type declaration")
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (d *Decoder) entityDecl(in *input) error {
+	if !in.skipSpace() {
+		return d.syntaxError("invalid entity declaration")
+	}
+	if in.hasPrefix("%") {
+		if !in.skipDecl() {
+			return d.syntaxError("unterminated entity declaration")
+		}
+		return nil
+	}
+	name := in.name()
+	if name == "" || !in.skipSpace() {
+		return d.syntaxError("invalid entity declaration")
+	}
+	e := new(entity)
+	if raw, ok := in.quoted(); ok {
+		v, err := expandCharRefs(raw)
+		if err != nil {
+			return d.syntaxError(err.Error())
+		}
+		e.value = v
+	} else {
+		if present, ok := in.externalID(); !present || !ok {
+			return d.syntaxError("invalid entity declaration " + name)
+		}
+		e.external = true
+		in.skipSpace()
+		if in.consume("NDATA") {
+			in.skipSpace()
+			e.unparsed = in.name() != ""
+		}
+	}
+	in.skipSpace()
+	if !in.consume(">") {
+		return d.syntaxError("unterminated entity declaration " + name)
+	}
+	if _, ok := d.entities[name]; !ok && !d.skipDecls {
+		d.entities[name] = e
+	}
+	return nil
+}
+
+func (d *Decoder) attlistDecl(in *input) error {
+	if !in.skipSpace() {
+		return d.syntaxError("invalid attribute-list declaration")
+	}
+	elem := in.name()
+	if elem == "" {
+		return d.syntaxError("invalid attribute-list declaration")
+	}
+	for {
+		in.skipSpace()
+		if in.consume(">") {
+			return nil
+		}
+		ad := &attDecl{name: in.name()}
+		if ad.name == "" || !in.skipSpace() {
+			return d.syntaxError("invalid attribute-list declaration of " + elem)
+		}
+		if in.hasPrefix("(") {
+			ad.typ = "ENUMERATION"
+		} else if ad.typ = in.name(); ad.typ == "NOTATION" {
+			in.skipSpace()
+		}
+		if in.consume("(") {
+			i := strings.IndexByte(in.s[in.pos:], ')')
+			if i < 0 {
+				return d.syntaxError("unterminated enumeration in attribute-list declaration of " + elem)
+			}
+			in.pos += i + 1
+		}
+		if ad.typ == "" || !in.skipSpace() {
+			return d.syntaxError("invalid attribute-list declaration of " + elem)
+		}
+		if !in.consume("#REQUIRED") && !in.consume("#IMPLIED") {
+			if in.consume("#FIXED") && !in.skipSpace() {
+				return d.syntaxError("invalid attribute-list declaration of " + elem)
+			}
+			raw, ok := in.quoted()
+			if !ok {
+				return d.syntaxError("invalid default value in attribute-list declaration of " + elem)
+			}
+			v, err := d.attrValue(raw)
+			if err != nil {
+				return err
+			}
+			if ad.typ != "CDATA" {
+				v = collapseSpaces(v)
+			}
+			ad.value, ad.hasDefault = v, true
+		}
+		if d.skipDecls {
+			continue
+		}
+		found := false
+		for _, prev := range d.attlists[elem] {
+			found = found || prev.name == ad.name
+		}
+		if !found {
+			d.attlists[elem] = append(d.attlists[elem], ad)
+		}
+	}
+}
+
+func (d *Decoder) syntaxError(msg string) error {
+	in := d.inputs
+	line := 1
+	if len(in) > 0 {
+		line += strings.Count(in[0].s[:in[0].pos], "\n")
+	}
+	return &xml.SyntaxError{Msg: msg, Line: line}
+}
+
+func (in *input) hasPrefix(s string) bool {
+	return strings.HasPrefix(in.s[in.pos:], s)
+}
+
+func (in *input) consume(s string) bool {
+	if in.hasPrefix(s) {
+		in.pos += len(s)
+		return true
+	}
+	return false
+}
+
+// skipSpace skips the whitespace and reports whether there was any.
+func (in *input) skipSpace() bool {
+	start := in.pos
+	for in.pos < len(in.s) && isSpace(in.s[in.pos]) {
+		in.pos++
+	}
+	return in.pos > start
+}
+
+func (in *input) name() string {
+	start := in.pos
+	for in.pos < len(in.s) && isNameByte(in.s[in.pos]) {
+		in.pos++
+	}
+	return in.s[start:in.pos]
+}
+
+// quoted returns the content of a literal delimited by single or double quotes.
+func (in *input) quoted() (string, bool) {
+	if in.pos >= len(in.s) || (in.s[in.pos] != '"' && in.s[in.pos] != '\'') {
+		return "", false
+	}
+	end := strings.IndexByte(in.s[in.pos+1:], in.s[in.pos])
+	if end < 0 {
+		return "", false
+	}
+	v := in.s[in.pos+1 : in.pos+1+end]
+	in.pos += end + 2
+	return v, true
+}
+
+// externalID skips a SYSTEM or PUBLIC external identifier.
+// It reports whether it is present and whether it is well-formed.
+func (in *input) externalID() (present, ok bool) {
+	switch {
+	case in.consume("SYSTEM"):
+		in.skipSpace()
+		_, ok = in.quoted()
+	case in.consume("PUBLIC"):
+		in.skipSpace()
+		if _, ok = in.quoted(); ok {
+			in.skipSpace()
+			_, ok = in.quoted()
+		}
+	default:
+		return false, true
+	}
+	return true, ok
+}
+
+// skipDecl skips a markup declaration up to its closing bracket.
+func (in *input) skipDecl() bool {
+	for in.pos < len(in.s) {
+		switch c := in.s[in.pos]; c {
+		case '"', '\'':
+			if _, ok := in.quoted(); !ok {
+				return false
+			}
+		case '>':
+			in.pos++
+			return true
+		default:
+			in.pos++
+		}
+	}
+	return false
+}
+
+// decodeText returns the UTF-8 text of the document b, whose encoding is detected
+// from the byte order mark or from the XML declaration.
+func decodeText(b []byte) (string, error) {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xEF, 0xBB, 0xBF}):
+		b = b[3:]
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return decodeUTF16(b[2:], binary.BigEndian)
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return decodeUTF16(b[2:], binary.LittleEndian)
+	case bytes.HasPrefix(b, []byte{0, '<', 0, '?'}):
+		return decodeUTF16(b, binary.BigEndian)
+	case bytes.HasPrefix(b, []byte{'<', 0, '?', 0}):
+		return decodeUTF16(b, binary.LittleEndian)
+	}
+	switch enc := strings.ToUpper(declaredEncoding(b)); enc {
+	case "", "UTF-8", "US-ASCII", "ASCII":
+	case "ISO-8859-1", "ISO_8859-1", "LATIN1", "L1":
+		r := make([]rune, len(b))
+		for i, c := range b {
+			r[i] = rune(c)
+		}
+		return string(r), nil
+	default:
+		return "", fmt.Errorf("c14n: unsupported encoding %s", enc)
+	}
+	if !utf8.Valid(b) {
+		return "", errors.New("c14n: invalid UTF-8")
+	}
+	return string(b), nil
+}
+
+func decodeUTF16(b []byte, order binary.ByteOrder) (string, error) {
+	if len(b)%2 != 0 {
+		return "", errors.New("c14n: invalid UTF-16")
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = order.Uint16(b[2*i:])
+	}
+	return string(utf16.Decode(u)), nil
+}
+
+// declaredEncoding returns the encoding declared in the XML declaration of b, if any.
+func declaredEncoding(b []byte) string {
+	if !bytes.HasPrefix(b, []byte("<?xml")) {
+		return ""
+	}
+	end := bytes.Index(b, []byte("?>"))
+	if end < 0 {
+		return ""
+	}
+	in := &input{s: string(b[:end])}
+	i := strings.Index(in.s, "encoding")
+	if i < 0 {
+		return ""
+	}
+	in.pos = i + len("encoding")
+	in.skipSpace()
+	if !in.consume("=") {
+		return ""
+	}
+	in.skipSpace()
+	enc, _ := in.quoted()
+	return enc
+}
+
+// expandCharRefs replaces the character references of an entity value,
+// which is done when the entity is declared.
+func expandCharRefs(s string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "&#")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), nil
+		}
+		semi := strings.IndexByte(s[i:], ';')
+		if semi < 0 {
+			return "", errors.New("unterminated character reference")
+		}
+		r, ok := charRef(s[i+1 : i+semi])
+		if !ok {
+			return "", errors.New("invalid character reference " + s[i:i+semi+1])
+		}
+		b.WriteString(s[:i])
+		b.WriteRune(r)
+		s = s[i+semi+1:]
+	}
+}
+
+// charRef returns the character referenced by ref, which is the text
+// of a character reference without the leading & and the trailing ;.
+func charRef(ref string) (rune, bool) {
+	var (
+		n   uint64
+		err error
+	)
+	if strings.HasPrefix(ref, "#x") {
+		n, err = strconv.ParseUint(ref[2:], 16, 32)
+	} else {
+		n, err = strconv.ParseUint(ref[1:], 10, 32)
+	}
+	if err != nil {
+		return 0, false
+	}
+	r := rune(n)
+	return r, isChar(r)
+}
+
+// isChar reports whether r is in the Char production of XML 1.0.
+func isChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isNameByte(c byte) bool {
+	return !isSpace(c) && !strings.ContainsRune(`<>/=?!"'&;[]()%|,#`, rune(c))
+}
+
+// collapseSpaces discards the leading and trailing spaces of s and
+// replaces the sequences of spaces by a single space.
+func collapseSpaces(s string) string {
+	var b strings.Builder
+	for _, f := range strings.Split(s, " ") {
+		if f == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f)
+	}
+	return b.String()
+}
@@ -0,0 +1,358 @@
+package c14n
+
+import (
+	"bufio"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// An Encoder writes the canonical form of an XML token stream to an output stream.
+//
+// The tokens shall preserve the namespace prefixes of the document, as the ones returned by
+// Decoder.Token or xml.Decoder.RawToken: the Space of the names holds the prefix
+// and the namespace declarations are attributes named "xmlns" or with the "xmlns" prefix.
+// Character data outside the document element and directives are ignored.
+type Encoder struct {
+	w         *bufio.Writer
+	method    Method
+	inclusive map[string]bool
+	context   []xml.Attr
+	stack     []*frame
+	afterRoot bool
+	closed    bool
+}
+
+// frame holds the state of an open element.
+type frame struct {
+	name     xml.Name
+	inScope  map[string]string // prefix:namespace declarations in scope
+	rendered map[string]string // prefix:namespace declarations rendered by the output ancestors
+}
+
+// NewEncoder returns a new encoder that writes to w using the canonicalization method m.
+func NewEncoder(w io.Writer, m Method) *Encoder {
+	return &Encoder{w: bufio.NewWriter(w), method: m}
+}
+
+// SetInclusiveNamespaces sets the InclusiveNamespaces PrefixList parameter of the exclusive methods,
+// whose namespaces are rendered following the Canonical XML rules. The default namespace is
+// denoted by "#default". It has no effect on the inclusive methods.
+func (e *Encoder) SetInclusiveNamespaces(prefixes ...string) {
+	e.inclusive = make(map[string]bool, len(prefixes))
+	for _, p := range prefixes {
+		if p == "#default" {
+			p = ""
+		}
+		e.inclusive[p] = true
+	}
+}
+
+// SetContext sets the namespace declarations and the attributes in the xml namespace
+// that are in scope of the first element, when the token stream is a document subset
+// whose apex element is not the document element.
+// When attributes are repeated, the first ones, which shall be the nearest to the apex, take precedence.
+// It shall be called before encoding any token.
+func (e *Encoder) SetContext(attrs []xml.Attr) {
+	e.context = attrs
+}
+
+// EncodeToken writes the canonical form of t.
+func (e *Encoder) EncodeToken(t xml.Token) error {
+	if e.closed {
+		return errors.New("c14n: encoder closed")
+	}
+	switch t := t.(type) {
+	case xml.StartElement:
+		return e.writeStart(t)
+	case xml.EndElement:
+		return e.writeEnd(t)
+	case xml.CharData:
+		if len(e.stack) > 0 {
+			e.writeEscaped(string(t), false)
+		}
+	case xml.Comment:
+		if e.method.WithComments() {
+			e.writeMisc(func() {
+				e.w.WriteString("<!--")
+				e.w.Write(t)
+				e.w.WriteString("-->")
+			})
+		}
+	case xml.ProcInst:
+		if t.Target == "xml" {
+			return nil // the XML declaration is not a processing instruction
+		}
+		e.writeMisc(func() {
+			e.w.WriteString("<?")
+			e.w.WriteString(t.Target)
+			if len(t.Inst) > 0 {
+				e.w.WriteByte(' ')
+				e.w.Write(t.Inst)
+			}
+			e.w.WriteString("?>")
+		})
+	}
+	return nil
+}
+
+// Flush flushes any buffered output to the underlying writer.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Close checks that all the elements have been closed and flushes the output.
+func (e *Encoder) Close() error {
+	e.closed = true
+	if len(e.stack) > 0 {
+		return fmt.Errorf("c14n: unclosed element %s", qualifiedName(e.stack[len(e.stack)-1].name))
+	}
+	return e.w.Flush()
+}
+
+// writeMisc writes comments and processing instructions, which are
+// separated from the document element by a line feed.
+func (e *Encoder) writeMisc(write func()) {
+	if len(e.stack) == 0 && e.afterRoot {
+		e.w.WriteByte('\n')
+	}
+	write()
+	if len(e.stack) == 0 && !e.afterRoot {
+		e.w.WriteByte('\n')
+	}
+}
+
+func (e *Encoder) writeStart(t xml.StartElement) error {
+	inScope := make(map[string]string)
+	rendered := map[string]string{"": ""}
+	var inherited []xml.Attr
+	if len(e.stack) > 0 {
+		parent := e.stack[len(e.stack)-1]
+		for k, v := range parent.inScope {
+			inScope[k] = v
+		}
+		for k, v := range parent.rendered {
+			rendered[k] = v
+		}
+	} else {
+		for i := len(e.context) - 1; i >= 0; i-- {
+			a := e.context[i]
+			if isNamespaceDecl(a) {
+				inScope[declaredPrefix(a)] = a.Value
+			}
+		}
+		inherited = e.inheritedXMLAttrs(t)
+	}
+	for _, a := range t.Attr {
+		if isNamespaceDecl(a) {
+			inScope[declaredPrefix(a)] = a.Value
+		}
+	}
+	if _, ok := inScope[""]; !ok {
+		inScope[""] = ""
+	}
+
+	var nsAttrs []xml.Attr
+	render := func(prefix string) {
+		uri, ok := inScope[prefix]
+		if !ok {
+			return
+		}
+		if prev, ok := rendered[prefix]; ok && prev == uri {
+			return
+		}
+		rendered[prefix] = uri
+		nsAttrs = append(nsAttrs, xml.Attr{Name: xml.Name{Local: prefix}, Value: uri})
+	}
+	if e.method.IsExclusive() {
+		// Only the visibly utilized namespaces and the inclusive ones are rendered.
+		utilized := []string{t.Name.Space}
+		for _, a := range t.Attr {
+			if !isNamespaceDecl(a) && a.Name.Space != "" && a.Name.Space != "xml" {
+				utilized = append(utilized, a.Name.Space)
+			}
+		}
+		for _, p := range utilized {
+			if _, ok := inScope[p]; !ok {
+				return fmt.Errorf("c14n: undeclared namespace prefix %s", p)
+			}
+			render(p)
+		}
+		for p := range e.inclusive {
+			render(p)
+		}
+	} else {
+		for p := range inScope {
+			if p != "xml" {
+				render(p)
+			}
+		}
+	}
+	sort.Slice(nsAttrs, func(i, j int) bool { return nsAttrs[i].Name.Local < nsAttrs[j].Name.Local })
+
+	type qualifiedAttr struct {
+		space string
+		attr  xml.Attr
+	}
+	attrs := make([]qualifiedAttr, 0, len(t.Attr)+len(inherited))
+	for _, a := range append(inherited, t.Attr...) {
+		if isNamespaceDecl(a) {
+			continue
+		}
+		var space string
+		switch a.Name.Space {
+		case "":
+		case "xml":
+			space = xmlNamespace
+		default:
+			var ok bool
+			if space, ok = inScope[a.Name.Space]; !ok {
+				return fmt.Errorf("c14n: undeclared namespace prefix %s", a.Name.Space)
+			}
+		}
+		attrs = append(attrs, qualifiedAttr{space, a})
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		if attrs[i].space != attrs[j].space {
+			return attrs[i].space < attrs[j].space
+		}
+		return attrs[i].attr.Name.Local < attrs[j].attr.Name.Local
+	})
+
+	e.w.WriteByte('<')
+	e.w.WriteString(qualifiedName(t.Name))
+	for _, a := range nsAttrs {
+		e.w.WriteString(" xmlns")
+		if a.Name.Local != "" {
+			e.w.WriteByte(':')
+			e.w.WriteString(a.Name.Local)
+		}
+		e.writeAttrValue(a.Value)
+	}
+	for _, a := range attrs {
+		e.w.WriteByte(' ')
+		e.w.WriteString(qualifiedName(a.attr.Name))
+		e.writeAttrValue(a.attr.Value)
+	}
+	e.w.WriteByte('>')
+	e.stack = append(e.stack, &frame{name: t.Name, inScope: inScope, rendered: rendered})
+	return nil
+}
+
+// inheritedXMLAttrs returns the attributes in the xml namespace of the context
+// which are not overridden by the apex element t. Only the inclusive methods inherit them.
+func (e *Encoder) inheritedXMLAttrs(t xml.StartElement) []xml.Attr {
+	if e.method.IsExclusive() {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, a := range t.Attr {
+		if a.Name.Space == "xml" {
+			seen[a.Name.Local] = true
+		}
+	}
+	var attrs []xml.Attr
+	for _, a := range e.context {
+		if a.Name.Space == "xml" && !seen[a.Name.Local] {
+			seen[a.Name.Local] = true
+			attrs = append(attrs, a)
+		}
+	}
+	return attrs
+}
+
+func (e *Encoder) writeEnd(t xml.EndElement) error {
+	if len(e.stack) == 0 {
+		return fmt.Errorf("c14n: unexpected end element %s", qualifiedName(t.Name))
+	}
+	f := e.stack[len(e.stack)-1]
+	if f.name != t.Name {
+		return fmt.Errorf("c14n: element %s closed by %s", qualifiedName(f.name), qualifiedName(t.Name))
+	}
+	e.stack = e.stack[:len(e.stack)-1]
+	e.w.WriteString("</")
+	e.w.WriteString(qualifiedName(t.Name))
+	e.w.WriteByte('>')
+	if len(e.stack) == 0 {
+		e.afterRoot = true
+	}
+	return nil
+}
+
+func (e *Encoder) writeAttrValue(v string) {
+	e.w.WriteString(`="`)
+	e.writeEscaped(v, true)
+	e.w.WriteByte('"')
+}
+
+func (e *Encoder) writeEscaped(s string, attr bool) {
+	last := 0
+	for i := 0; i < len(s); i++ {
+		var esc string
+		switch s[i] {
+		case '&':
+			esc = "&amp;"
+		case '<':
+			esc = "&lt;"
+		case '>':
+			if attr {
+				continue
+			}
+			esc = "&gt;"
+		case '"':
+			if !attr {
+				continue
+			}
+			esc = "&quot;"
+		case '\t':
+			if !attr {
+				continue
+			}
+			esc = "&#x9;"
+		case '\n':
+			if !attr {
+				continue
+			}
+			esc = "&#xA;"
+		case '\r':
+			esc = "&#xD;"
+		default:
+			continue
+		}
+		e.w.WriteString(s[last:i])
+		e.w.WriteString(esc)
+		last = i + 1
+	}
+	e.w.WriteString(s[last:])
+}
+
+func isNamespaceDecl(a xml.Attr) bool {
+	return (a.Name.Space == "" && a.Name.Local == "xmlns") || a.Name.Space == "xmlns"
+}
+
+func declaredPrefix(a xml.Attr) string {
+	if a.Name.Space == "xmlns" {
+		return a.Name.Local
+	}
+	return ""
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+// splitName splits a qualified name into its prefix and local part.
+func splitName(qname string) xml.Name {
+	if i := strings.IndexByte(qname, ':'); i > 0 && i < len(qname)-1 {
+		return xml.Name{Space: qname[:i], Local: qname[i+1:]}
+	}
+	return xml.Name{Local: qname}
+}
@@ -0,0 +1,270 @@
+package opc
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"strings"
+)
+
+// sniffLen is the number of bytes read by DetectContentType.
+const sniffLen = 512
+
+const (
+	wordprocessingMLPrefix = "application/vnd.openxmlformats-officedocument.wordprocessingml."
+	spreadsheetMLPrefix    = "application/vnd.openxmlformats-officedocument.spreadsheetml."
+	presentationMLPrefix   = "application/vnd.openxmlformats-officedocument.presentationml."
+	xpsPrefix              = "application/vnd.ms-package.xps-"
+	openTypeContentType    = "application/vnd.ms-opentype"
+	octetStreamContentType = "application/octet-stream"
+)
+
+// DefaultContentTypeRegistry is the ContentTypeRegistry used by Writer.CreateAuto
+// when the Writer does not have one.
+var DefaultContentTypeRegistry = NewContentTypeRegistry()
+
+// ContentTypeRegistry maps part names to content types,
+// either by the role that the part plays in a well-known format or by its extension.
+type ContentTypeRegistry struct {
+	names      []contentTypeRule
+	extensions map[string]string // lowercase extension:contenttype
+}
+
+type contentTypeRule struct {
+	pattern     string // Uppercase path.Match pattern.
+	contentType string
+}
+
+// NewContentTypeRegistry returns a ContentTypeRegistry which knows the common extensions
+// and the main parts of OOXML, 3MF, XPS and NuGet packages.
+func NewContentTypeRegistry() *ContentTypeRegistry {
+	r := &ContentTypeRegistry{extensions: make(map[string]string)}
+	for ext, ct := range map[string]string{
+		"xml":    "application/xml",
+		"rels":   relationshipContentType,
+		"txt":    "text/plain",
+		"json":   "application/json",
+		"pdf":    "application/pdf",
+		"png":    "image/png",
+		"jpg":    "image/jpeg",
+		"jpeg":   "image/jpeg",
+		"gif":    "image/gif",
+		"bmp":    "image/bmp",
+		"tif":    "image/tiff",
+		"tiff":   "image/tiff",
+		"svg":    "image/svg+xml",
+		"webp":   "image/webp",
+		"emf":    "image/x-emf",
+		"wmf":    "image/x-wmf",
+		"ttf":    openTypeContentType,
+		"otf":    openTypeContentType,
+		"woff":   "font/woff",
+		"woff2":  "font/woff2",
+		"odttf":  "application/vnd.ms-package.obfuscated-opentype",
+		"psdsor": signatureOriginContentType,
+		"psdsxs": signatureContentType,
+		"model":  "application/vnd.ms-package.3dmanufacturing-3dmodel+xml",
+		"fdseq":  xpsPrefix + "fixeddocumentsequence+xml",
+		"fdoc":   xpsPrefix + "fixeddocument+xml",
+		"fpage":  xpsPrefix + "fixedpage+xml",
+		"dict":   xpsPrefix + "resourcedictionary+xml",
+		"nuspec": "application/octet",
+		"psmdcp": corePropsContentType,
+		"vml":    "application/vnd.openxmlformats-officedocument.vmlDrawing",
+		"bin":    octetStreamContentType,
+		"xlsx":   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"docx":   "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"pptx":   "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	} {
+		r.RegisterExtension(ext, ct)
+	}
+	for pattern, ct := range map[string]string{
+		"/docProps/core.xml":                         corePropsContentType,
+		"/docProps/app.xml":                          extendedPropsContentType,
+		"/docProps/custom.xml":                       customPropsContentType,
+		"/word/document.xml":                         wordprocessingMLPrefix + "document.main+xml",
+		"/word/styles.xml":                           wordprocessingMLPrefix + "styles+xml",
+		"/word/settings.xml":                         wordprocessingMLPrefix + "settings+xml",
+		"/word/webSettings.xml":                      wordprocessingMLPrefix + "webSettings+xml",
+		"/word/fontTable.xml":                        wordprocessingMLPrefix + "fontTable+xml",
+		"/word/numbering.xml":                        wordprocessingMLPrefix + "numbering+xml",
+		"/word/footnotes.xml":                        wordprocessingMLPrefix + "footnotes+xml",
+		"/word/endnotes.xml":                         wordprocessingMLPrefix + "endnotes+xml",
+		"/word/comments.xml":                         wordprocessingMLPrefix + "comments+xml",
+		"/word/header*.xml":                          wordprocessingMLPrefix + "header+xml",
+		"/word/footer*.xml":                          wordprocessingMLPrefix + "footer+xml",
+		"/*/theme/theme*.xml":                        "application/vnd.openxmlformats-officedocument.theme+xml",
+		"/xl/workbook.xml":                           spreadsheetMLPrefix + "sheet.main+xml",
+		"/xl/styles.xml":                             spreadsheetMLPrefix + "styles+xml",
+		"/xl/sharedStrings.xml":                      spreadsheetMLPrefix + "sharedStrings+xml",
+		"/xl/calcChain.xml":                          spreadsheetMLPrefix + "calcChain+xml",
+		"/xl/worksheets/sheet*.xml":                  spreadsheetMLPrefix + "worksheet+xml",
+		"/xl/chartsheets/sheet*.xml":                 spreadsheetMLPrefix + "chartsheet+xml",
+		"/xl/tables/table*.xml":                      spreadsheetMLPrefix + "table+xml",
+		"/xl/comments*.xml":                          spreadsheetMLPrefix + "comments+xml",
+		"/ppt/presentation.xml":                      presentationMLPrefix + "presentation.main+xml",
+		"/ppt/presProps.xml":                         presentationMLPrefix + "presProps+xml",
+		"/ppt/viewProps.xml":                         presentationMLPrefix + "viewProps+xml",
+		"/ppt/tableStyles.xml":                       presentationMLPrefix + "tableStyles+xml",
+		"/ppt/slides/slide*.xml":                     presentationMLPrefix + "slide+xml",
+		"/ppt/slideLayouts/slideLayout*.xml":         presentationMLPrefix + "slideLayout+xml",
+		"/ppt/slideMasters/slideMaster*.xml":         presentationMLPrefix + "slideMaster+xml",
+		"/ppt/notesSlides/notesSlide*.xml":           presentationMLPrefix + "notesSlide+xml",
+		"/ppt/notesMasters/notesMaster*.xml":         presentationMLPrefix + "notesMaster+xml",
+		"/*/charts/chart*.xml":                       "application/vnd.openxmlformats-officedocument.drawingml.chart+xml",
+		"/xl/drawings/drawing*.xml":                  "application/vnd.openxmlformats-officedocument.drawing+xml",
+		"/Metadata/thumbnail.png":                    "image/png",
+		"/Documents/*/FixedDocument.fdoc":            xpsPrefix + "fixeddocument+xml",
+		"/Documents/*/Structure/DocStructure.struct": xpsPrefix + "documentstructure+xml",
+	} {
+		r.RegisterPartName(pattern, ct)
+	}
+	return r
+}
+
+// RegisterExtension maps the parts with the extension ext, with or without the leading dot,
+// to contentType. The extensions are not case sensitive.
+func (r *ContentTypeRegistry) RegisterExtension(ext, contentType string) {
+	r.extensions[strings.ToLower(strings.TrimPrefix(ext, "."))] = contentType
+}
+
+// RegisterPartName maps the parts whose name matches pattern to contentType,
+// which takes precedence over the content type of their extension.
+// The pattern syntax is the one of path.Match and it is not case sensitive.
+// The patterns registered later take precedence over the previous ones.
+func (r *ContentTypeRegistry) RegisterPartName(pattern, contentType string) {
+	r.names = append(r.names, contentTypeRule{strings.ToUpper(pattern), contentType})
+}
+
+// ContentType returns the content type of the part called name and whether it is known.
+func (r *ContentTypeRegistry) ContentType(name string) (string, bool) {
+	upper := partKey(name)
+	for i := len(r.names) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(r.names[i].pattern, upper); ok {
+			return r.names[i].contentType, true
+		}
+	}
+	ct, ok := r.extensions[partExtension(upper)]
+	return ct, ok
+}
+
+// contentTypeSignatures are the magic bytes of the images and fonts recognized by DetectContentType.
+var contentTypeSignatures = []struct {
+	offset      int
+	magic       []byte
+	contentType string
+}{
+	{0, []byte("\x89PNG\r\n\x1a\n"), "image/png"},
+	{0, []byte("\xff\xd8\xff"), "image/jpeg"},
+	{0, []byte("GIF87a"), "image/gif"},
+	{0, []byte("GIF89a"), "image/gif"},
+	{0, []byte("II*\x00"), "image/tiff"},
+	{0, []byte("MM\x00*"), "image/tiff"},
+	{8, []byte("WEBP"), "image/webp"},
+	{40, []byte(" EMF"), "image/x-emf"},
+	{0, []byte("\xd7\xcd\xc6\x9a"), "image/x-wmf"},
+	{0, []byte("BM"), "image/bmp"},
+	{0, []byte("\x00\x01\x00\x00"), openTypeContentType},
+	{0, []byte("OTTO"), openTypeContentType},
+	{0, []byte("true"), openTypeContentType},
+	{0, []byte("ttcf"), openTypeContentType},
+	{0, []byte("wOFF"), "font/woff"},
+	{0, []byte("wOF2"), "font/woff2"},
+}
+
+// DetectContentType returns the content type of the image or font stored in data,
+// recognized by its magic bytes. It considers at most the first 512 bytes of data
+// and returns "application/octet-stream" if data is not recognized.
+func DetectContentType(data []byte) string {
+	if len(data) > sniffLen {
+		data = data[:sniffLen]
+	}
+	for _, s := range contentTypeSignatures {
+		if len(data) >= s.offset+len(s.magic) && bytes.Equal(data[s.offset:s.offset+len(s.magic)], s.magic) {
+			if s.contentType == "image/webp" && !bytes.HasPrefix(data, []byte("RIFF")) {
+				continue
+			}
+			return s.contentType
+		}
+	}
+	return octetStreamContentType
+}
+
+// CreateAuto adds a file to the OPC archive using the provided name,
+// compressed using the Deflate default method, like Create does.
+// The content type is the one registered for name in the Writer ContentTypeRegistry.
+// If there is none, it is detected from the first bytes written to the part with DetectContentType,
+// so the part is not created until 512 bytes have been written or the Writer is used again.
+// Its name is checked by CreateAuto, and an error creating it is returned by every later call to the Writer.
+//
+// This returns a Writer to which the file contents should be written.
+// The file's contents must be written to the io.Writer before the next call to Create, CreatePart, or Close.
+func (w *Writer) CreateAuto(name string) (io.Writer, error) {
+	registry := w.ContentTypeRegistry
+	if registry == nil {
+		registry = DefaultContentTypeRegistry
+	}
+	part := &Part{Name: name}
+	if ct, ok := registry.ContentType(name); ok {
+		part.ContentType = ct
+		return w.CreatePart(part, CompressionNormal)
+	}
+	if err := w.createPending(); err != nil {
+		return nil, err
+	}
+	// The content type is not known yet, so the part is checked with a placeholder one.
+	if err := w.p.validatePart(&Part{Name: name, ContentType: octetStreamContentType}); err != nil {
+		return nil, err
+	}
+	if err := w.createLastPartRelationships(); err != nil {
+		return nil, err
+	}
+	w.last = part
+	w.pending = &sniffWriter{w: w, part: part, compression: CompressionNormal}
+	return w.pending, nil
+}
+
+// sniffWriter buffers the first bytes of a part created with CreateAuto
+// until its content type can be detected.
+type sniffWriter struct {
+	w           *Writer
+	part        *Part
+	compression CompressionOption
+	buf         []byte
+	dst         io.Writer // The part writer, once it is created.
+}
+
+func (s *sniffWriter) Write(p []byte) (int, error) {
+	if s.dst != nil {
+		return s.dst.Write(p)
+	}
+	s.buf = append(s.buf, p...)
+	if len(s.buf) >= sniffLen {
+		if err := s.w.createPending(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// createPending creates the part created with CreateAuto whose content type is not detected yet.
+// It shall be called before writing anything else to the ZIP file.
+// If the part cannot be created, the error is returned by every later call, including Close.
+func (w *Writer) createPending() error {
+	if w.pendingErr != nil {
+		return w.pendingErr
+	}
+	s := w.pending
+	if s == nil {
+		return nil
+	}
+	w.pending = nil
+	s.part.ContentType = DetectContentType(s.buf)
+	dst, err := w.addToPackage(s.part, s.compression)
+	if err == nil {
+		s.dst = dst
+		_, err = dst.Write(s.buf)
+	}
+	w.pendingErr = err
+	return err
+}
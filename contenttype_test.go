@@ -0,0 +1,155 @@
+package opc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestContentTypeRegistry_ContentType(t *testing.T) {
+	r := NewContentTypeRegistry()
+	r.RegisterExtension(".ABC", "a/b")
+	r.RegisterPartName("/custom/*.xml", "c/d")
+	tests := []struct {
+		name   string
+		want   string
+		wantOk bool
+	}{
+		{"/word/document.xml", "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml", true},
+		{"/WORD/Document.XML", "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml", true},
+		{"/xl/worksheets/sheet3.xml", "application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml", true},
+		{"/ppt/theme/theme1.xml", "application/vnd.openxmlformats-officedocument.theme+xml", true},
+		{"/3D/3dmodel.model", "application/vnd.ms-package.3dmanufacturing-3dmodel+xml", true},
+		{"/Documents/1/Pages/1.fpage", "application/vnd.ms-package.xps-fixedpage+xml", true},
+		{"/package/services/metadata/core-properties/a.psmdcp", corePropsContentType, true},
+		{"/a/b.PNG", "image/png", true},
+		{"/a.xml", "application/xml", true},
+		{"/a.abc", "a/b", true},
+		{"/custom/a.xml", "c/d", true},
+		{"/a.unknown", "", false},
+		{"/a", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := r.ContentType(tt.name)
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("ContentTypeRegistry.ContentType() = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	emf := make([]byte, 44)
+	copy(emf, "\x01\x00\x00\x00")
+	copy(emf[40:], " EMF")
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"png", "\x89PNG\r\n\x1a\n\x00\x00", "image/png"},
+		{"jpeg", "\xff\xd8\xff\xe0", "image/jpeg"},
+		{"gif", "GIF89a...", "image/gif"},
+		{"tiff", "II*\x00...", "image/tiff"},
+		{"webp", "RIFF\x00\x00\x00\x00WEBPVP8 ", "image/webp"},
+		{"notWebp", "ABCD\x00\x00\x00\x00WEBPVP8 ", "application/octet-stream"},
+		{"emf", string(emf), "image/x-emf"},
+		{"bmp", "BM\x00\x00", "image/bmp"},
+		{"ttf", "\x00\x01\x00\x00\x00\x10", "application/vnd.ms-opentype"},
+		{"otf", "OTTO\x00\x10", "application/vnd.ms-opentype"},
+		{"woff2", "wOF2\x00\x01", "font/woff2"},
+		{"empty", "", "application/octet-stream"},
+		{"text", "hello", "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectContentType([]byte(tt.data)); got != tt.want {
+				t.Errorf("DetectContentType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriter_CreateAuto_pendingError(t *testing.T) {
+	w := NewWriter(new(bytes.Buffer))
+	pw, err := w.CreateAuto("/a")
+	if err != nil {
+		t.Fatalf("Writer.CreateAuto() error = %v", err)
+	}
+	pw.Write([]byte("abc"))
+	w.pending.compression = -3 // The part cannot be created.
+	if err := w.Flush(); err == nil {
+		t.Fatal("Writer.Flush() want error")
+	}
+	if _, err := w.Create("/b", "text/plain"); err == nil {
+		t.Error("Writer.Create() want the error of the pending part")
+	}
+	if err := w.Close(); err == nil {
+		t.Error("Writer.Close() want the error of the pending part")
+	}
+}
+
+func TestWriter_CreateAuto(t *testing.T) {
+	png := "\x89PNG\r\n\x1a\n" + strings.Repeat("a", 600)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.ContentTypeRegistry = NewContentTypeRegistry()
+	w.ContentTypeRegistry.RegisterExtension("dat", "application/x-dat")
+	files := []struct {
+		name, content, wantType string
+	}{
+		{"/word/document.xml", "<a/>", "application/vnd.openxmlformats-officedocument.wordprocessingml.document.main+xml"},
+		{"/a.dat", "dat", "application/x-dat"},
+		{"/media/image1", png, "image/png"},
+		{"/media/image2", "GIF89a", "image/gif"},
+		{"/media/unknown", "abc", "application/octet-stream"},
+	}
+	for i, f := range files {
+		pw, err := w.CreateAuto(f.name)
+		if err != nil {
+			t.Fatalf("Writer.CreateAuto() error = %v", err)
+		}
+		// Write the content in two chunks to buffer part of it.
+		pw.Write([]byte(f.content[:len(f.content)/2]))
+		pw.Write([]byte(f.content[len(f.content)/2:]))
+		if i == 3 {
+			w.Relationships = append(w.Relationships, &Relationship{Type: thumbnailRel, TargetURI: f.name})
+		}
+	}
+	if _, err := w.CreateAuto("a.xml"); err == nil {
+		t.Error("Writer.CreateAuto() want error for an invalid part name")
+	}
+	if _, err := w.CreateAuto("/b."); err == nil {
+		t.Error("Writer.CreateAuto() want error for an invalid part name")
+	}
+	if _, err := w.CreateAuto("/media/unknown"); err == nil {
+		t.Error("Writer.CreateAuto() want error for a duplicated part name")
+	}
+	if _, err := w.CreateAuto("/media/image1/a"); err == nil {
+		t.Error("Writer.CreateAuto() want error for a part name colliding with a prefix")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if len(r.Files) != len(files) {
+		t.Fatalf("Reader.Files = %d, want %d", len(r.Files), len(files))
+	}
+	for i, f := range files {
+		got := r.Files[i]
+		if got.Name != f.name || got.ContentType != f.wantType {
+			t.Errorf("Reader.Files[%d] = (%s, %s), want (%s, %s)", i, got.Name, got.ContentType, f.name, f.wantType)
+		}
+		rc, _ := got.Open()
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if string(b) != f.content {
+			t.Errorf("File.Open() %s content = %q, want %q", got.Name, b, f.content)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+package opc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qmuntal/opc/c14n"
+)
+
+const (
+	customPropsRel         = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/custom-properties"
+	customPropsContentType = "application/vnd.openxmlformats-officedocument.custom-properties+xml"
+	customPropsDefaultName = "/docProps/custom.xml"
+	customPropsNamespace   = "http://schemas.openxmlformats.org/officeDocument/2006/custom-properties"
+	customPropsFMTID       = "{D5CDD505-2E9C-101B-9397-08002B2CF9AE}"
+	customPropsFirstPID    = 2
+)
+
+// CustomProperties are the user defined properties of an Office document,
+// stored in the custom properties part defined in ISO/IEC 29500-1 §22.3.
+//
+// The property values are mapped to and from the vt variant types as follows:
+//
+//	string:    lpwstr, lpstr and bstr
+//	int:       i1, i2, i4, i8, int, ui1, ui2, ui4, ui8 and uint
+//	float64:   r4 and r8
+//	bool:      bool
+//	time.Time: filetime and date
+//
+// The values of any other type are represented by a Variant, so they are preserved when
+// the properties are written again. So are the integers that do not fit in an int,
+// such as the ui8 values above math.MaxInt64, and the decimal values, which a float64 cannot hold exactly.
+type CustomProperties struct {
+	PartName       string // Won't be written to the package, only used to indicate the location of the CustomProperties part. If empty the default location is "/docProps/custom.xml".
+	RelationshipID string // Won't be written to the package, only used to indicate the relationship ID for the CustomProperties part.
+	props          []*customProperty
+}
+
+// Variant is a custom property value whose vt type is not mapped to a Go type.
+type Variant struct {
+	Type string // The local name of the vt element, such as "vector" or "cy".
+	XML  string // The vt element, including the declarations of the namespaces it uses.
+}
+
+type customProperty struct {
+	name       string
+	fmtid      string
+	pid        int
+	linkTarget string
+	vtType     string // The vt type of value, unless it is a Variant.
+	value      interface{}
+}
+
+// Names returns the names of the properties in the order they are stored.
+func (c *CustomProperties) Names() []string {
+	names := make([]string, len(c.props))
+	for i, p := range c.props {
+		names[i] = p.name
+	}
+	return names
+}
+
+// Get returns the value of the property called name, whose case is not significant,
+// and whether it exists.
+func (c *CustomProperties) Get(name string) (interface{}, bool) {
+	if p := c.find(name); p != nil {
+		return p.value, true
+	}
+	return nil, false
+}
+
+// Set sets the value of the property called name, adding it if it does not exist.
+// The value type shall be one of the types listed in CustomProperties, an int32, an int64 or a float32.
+// The new properties are identified by the next free pid.
+func (c *CustomProperties) Set(name string, value interface{}) error {
+	if name == "" {
+		return fmt.Errorf("opc: %s: the custom property name cannot be empty", c.partName())
+	}
+	vtType, v, err := customValue(value)
+	if err != nil {
+		return fmt.Errorf("opc: %s: custom property %s: %v", c.partName(), name, err)
+	}
+	if p := c.find(name); p != nil {
+		p.vtType, p.value = vtType, v
+		return nil
+	}
+	pid := customPropsFirstPID
+	for _, p := range c.props {
+		if p.pid >= pid {
+			pid = p.pid + 1
+		}
+	}
+	c.props = append(c.props, &customProperty{name: name, fmtid: customPropsFMTID, pid: pid, vtType: vtType, value: v})
+	return nil
+}
+
+// Delete removes the property called name and reports whether it existed.
+func (c *CustomProperties) Delete(name string) bool {
+	for i, p := range c.props {
+		if strings.EqualFold(p.name, name) {
+			c.props = append(c.props[:i], c.props[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CustomProperties) find(name string) *customProperty {
+	for _, p := range c.props {
+		if strings.EqualFold(p.name, name) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (c *CustomProperties) clone() CustomProperties {
+	cc := *c
+	cc.props = make([]*customProperty, len(c.props))
+	for i, p := range c.props {
+		pc := *p
+		cc.props[i] = &pc
+	}
+	return cc
+}
+
+// partName returns the absolute name of the custom properties part.
+func (c *CustomProperties) partName() string {
+	if c.PartName == "" {
+		return customPropsDefaultName
+	}
+	return ResolveRelationship("/", c.PartName)
+}
+
+// customValue returns the vt type used to store value and value converted to the type returned by Get.
+func customValue(value interface{}) (string, interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return "lpwstr", v, nil
+	case int:
+		if v < math.MinInt32 || v > math.MaxInt32 {
+			return "i8", v, nil
+		}
+		return "i4", v, nil
+	case int32:
+		return "i4", int(v), nil
+	case int64:
+		return "i8", int(v), nil
+	case float32:
+		return "r4", float64(v), nil
+	case float64:
+		return "r8", v, nil
+	case bool:
+		return "bool", v, nil
+	case time.Time:
+		return "filetime", v, nil
+	case Variant:
+		if v.Type == "" {
+			return "", nil, fmt.Errorf("the variant type cannot be empty")
+		}
+		if _, err := parseXMLTree(strings.NewReader(v.XML)); err != nil {
+			return "", nil, fmt.Errorf("invalid variant: %v", err)
+		}
+		return "", v, nil
+	}
+	return "", nil, fmt.Errorf("unsupported value type %T", value)
+}
+
+// parseCustomValue converts the text of a vt element of type vtType to the type returned by Get.
+func parseCustomValue(vtType, text string) (interface{}, bool) {
+	switch vtType {
+	case "lpwstr", "lpstr", "bstr":
+		return text, true
+	case "i1", "i2", "i4", "i8", "int", "ui1", "ui2", "ui4", "ui8", "uint":
+		n, err := strconv.Atoi(strings.TrimSpace(text))
+		return n, err == nil
+	case "r4", "r8":
+		f, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+		return f, err == nil
+	case "bool":
+		b, err := strconv.ParseBool(strings.TrimSpace(text))
+		return b, err == nil
+	case "filetime", "date":
+		t, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(text))
+		return t, err == nil
+	}
+	return nil, false
+}
+
+// formatCustomValue returns the text of the vt element holding value.
+func formatCustomValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case int:
+		return strconv.Itoa(v)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	}
+	return ""
+}
+
+type customPropertiesXMLMarshal struct {
+	XMLName    xml.Name                    `xml:"Properties"`
+	XML        string                      `xml:"xmlns,attr"`
+	XMLVT      string                      `xml:"xmlns:vt,attr"`
+	Properties []*customPropertyXMLMarshal `xml:"property"`
+}
+
+type customPropertyXMLMarshal struct {
+	FMTID      string `xml:"fmtid,attr"`
+	PID        int    `xml:"pid,attr"`
+	Name       string `xml:"name,attr"`
+	LinkTarget string `xml:"linkTarget,attr,omitempty"`
+	Value      string `xml:",innerxml"`
+}
+
+func (c *CustomProperties) encode(w io.Writer) error {
+	x := &customPropertiesXMLMarshal{XML: customPropsNamespace, XMLVT: vtNamespace}
+	for _, p := range c.props {
+		var value string
+		if v, ok := p.value.(Variant); ok {
+			value = v.XML
+		} else {
+			var b strings.Builder
+			xml.EscapeText(&b, []byte(formatCustomValue(p.value)))
+			value = fmt.Sprintf("<vt:%s>%s</vt:%s>", p.vtType, b.String(), p.vtType)
+		}
+		x.Properties = append(x.Properties, &customPropertyXMLMarshal{p.fmtid, p.pid, p.name, p.linkTarget, value})
+	}
+	w.Write(([]byte)(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "    ")
+	return enc.Encode(x)
+}
+
+func decodeCustomProperties(r io.Reader, props *CustomProperties) error {
+	name := props.partName()
+	doc, err := parseXMLTree(r)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be decoded: %v", name, err)
+	}
+	root := doc.root()
+	if root == nil || !root.is(customPropsNamespace, "Properties") {
+		return fmt.Errorf("opc: %s: cannot be decoded: expected a custom properties document", name)
+	}
+	props.props = nil
+	for _, e := range root.elements(customPropsNamespace, "property") {
+		p := new(customProperty)
+		p.name, _ = e.attr("name")
+		p.fmtid, _ = e.attr("fmtid")
+		p.linkTarget, _ = e.attr("linkTarget")
+		pid, _ := e.attr("pid")
+		if p.pid, err = strconv.Atoi(strings.TrimSpace(pid)); err != nil {
+			return fmt.Errorf("opc: %s: cannot be decoded: property %s has an invalid pid: %v", name, p.name, err)
+		}
+		var value *xmlElement
+		for _, c := range e.Children {
+			if ce, ok := c.(*xmlElement); ok {
+				value = ce
+				break
+			}
+		}
+		if value == nil {
+			return fmt.Errorf("opc: %s: cannot be decoded: property %s has no value", name, p.name)
+		}
+		if value.lookupNamespace(value.Name.Space) == vtNamespace {
+			if v, ok := parseCustomValue(value.Name.Local, value.text()); ok {
+				p.vtType, p.value = value.Name.Local, v
+			}
+		}
+		if p.value == nil {
+			b, err := canonicalize(value, c14n.Canonical)
+			if err != nil {
+				return fmt.Errorf("opc: %s: cannot be decoded: %v", name, err)
+			}
+			p.value = Variant{Type: value.Name.Local, XML: string(bytes.TrimSpace(b))}
+		}
+		props.props = append(props.props, p)
+	}
+	return nil
+}
@@ -0,0 +1,267 @@
+package opc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func buildCustomString(content string) string {
+	s := `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+	s += `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties"`
+	s += ` xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">`
+	return s + content + "</Properties>"
+}
+
+func TestCustomProperties_Set(t *testing.T) {
+	date := time.Date(2020, 10, 18, 17, 54, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		value   interface{}
+		want    interface{}
+		wantXML string
+		wantErr bool
+	}{
+		{"string", "High", "High", `<vt:lpwstr>High</vt:lpwstr>`, false},
+		{"escaped", "a<b", "a<b", `<vt:lpwstr>a&lt;b</vt:lpwstr>`, false},
+		{"int", 5, 5, `<vt:i4>5</vt:i4>`, false},
+		{"bigInt", 1 << 40, 1 << 40, `<vt:i8>1099511627776</vt:i8>`, false},
+		{"int32", int32(-5), -5, `<vt:i4>-5</vt:i4>`, false},
+		{"int64", int64(5), 5, `<vt:i8>5</vt:i8>`, false},
+		{"float32", float32(0.5), 0.5, `<vt:r4>0.5</vt:r4>`, false},
+		{"float64", 1.25, 1.25, `<vt:r8>1.25</vt:r8>`, false},
+		{"bool", true, true, `<vt:bool>true</vt:bool>`, false},
+		{"time", date, date, `<vt:filetime>2020-10-18T17:54:00Z</vt:filetime>`, false},
+		{"fractionalTime", date.Add(500 * time.Millisecond), date.Add(500 * time.Millisecond), `<vt:filetime>2020-10-18T17:54:00.5Z</vt:filetime>`, false},
+		{"variant", Variant{"cy", `<vt:cy xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">1.5</vt:cy>`},
+			Variant{"cy", `<vt:cy xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">1.5</vt:cy>`},
+			`<vt:cy xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">1.5</vt:cy>`, false},
+		{"invalidVariant", Variant{"cy", `<vt:cy>`}, nil, "", true},
+		{"emptyVariantType", Variant{"", `<a/>`}, nil, "", true},
+		{"unsupported", []string{"a"}, nil, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c CustomProperties
+			if err := c.Set(tt.name, tt.value); (err != nil) != tt.wantErr {
+				t.Fatalf("CustomProperties.Set() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got, ok := c.Get(strings.ToUpper(tt.name))
+			if ok == tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CustomProperties.Get() = (%v, %v), want %v", got, ok, tt.want)
+			}
+			if tt.wantErr {
+				return
+			}
+			var b bytes.Buffer
+			if err := c.encode(&b); err != nil {
+				t.Fatalf("CustomProperties.encode() error = %v", err)
+			}
+			want := buildCustomString("\n" + `    <property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="` + tt.name + `">` + tt.wantXML + "</property>\n")
+			if b.String() != want {
+				t.Errorf("CustomProperties.encode() = %v, want %v", b.String(), want)
+			}
+		})
+	}
+}
+
+func TestCustomProperties_pids(t *testing.T) {
+	var c CustomProperties
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Set("c", 3)
+	if !c.Delete("B") || c.Delete("b") {
+		t.Error("CustomProperties.Delete() want true and then false")
+	}
+	c.Set("A", "x")
+	c.Set("d", 4)
+	if got := c.Names(); !reflect.DeepEqual(got, []string{"a", "c", "d"}) {
+		t.Errorf("CustomProperties.Names() = %v", got)
+	}
+	var pids []int
+	for _, p := range c.props {
+		pids = append(pids, p.pid)
+	}
+	if !reflect.DeepEqual(pids, []int{2, 4, 5}) {
+		t.Errorf("CustomProperties pids = %v, want [2 4 5]", pids)
+	}
+	if v, _ := c.Get("a"); v != "x" {
+		t.Errorf("CustomProperties.Get() = %v, want x", v)
+	}
+	if err := c.Set("", 1); err == nil {
+		t.Error("CustomProperties.Set() want error for an empty name")
+	}
+}
+
+func Test_decodeCustomProperties(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		wantNames []string
+		want      []interface{}
+		wantErr   bool
+	}{
+		{"typed", `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="ClassificationLevel"><vt:lpwstr>High</vt:lpwstr></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="3" name="ProjectId"><vt:i4>42</vt:i4></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="4" name="ReviewDate"><vt:filetime>2020-10-18T17:54:00Z</vt:filetime></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="5" name="Ratio"><vt:r8>0.5</vt:r8></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="6" name="Reviewed"><vt:bool>1</vt:bool></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="7" name="Created"><vt:date>2020-10-18T17:54:00.123Z</vt:date></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="8" name="Size"><vt:ui8>9223372036854775807</vt:ui8></property>`,
+			[]string{"ClassificationLevel", "ProjectId", "ReviewDate", "Ratio", "Reviewed", "Created", "Size"},
+			[]interface{}{"High", 42, time.Date(2020, 10, 18, 17, 54, 0, 0, time.UTC), 0.5, true,
+				time.Date(2020, 10, 18, 17, 54, 0, 123000000, time.UTC), 9223372036854775807}, false},
+		{"unknown", `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="Price"><vt:cy>1.5</vt:cy></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="3" name="Invalid"><vt:i4>a</vt:i4></property>
+			<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="4" name="Size"><vt:ui8>9223372036854775808</vt:ui8></property>`,
+			[]string{"Price", "Invalid", "Size"},
+			[]interface{}{
+				Variant{"cy", `<vt:cy xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">1.5</vt:cy>`},
+				Variant{"i4", `<vt:i4 xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">a</vt:i4>`},
+				Variant{"ui8", `<vt:ui8 xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">9223372036854775808</vt:ui8>`},
+			}, false},
+		{"invalidPid", `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="a" name="a"><vt:i4>1</vt:i4></property>`, nil, nil, true},
+		{"noValue", `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="a"></property>`, nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c CustomProperties
+			err := decodeCustomProperties(strings.NewReader(buildCustomString(tt.content)), &c)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeCustomProperties() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := c.Names(); !reflect.DeepEqual(got, tt.wantNames) {
+				t.Errorf("CustomProperties.Names() = %v, want %v", got, tt.wantNames)
+			}
+			for i, name := range tt.wantNames {
+				if got, _ := c.Get(name); !reflect.DeepEqual(got, tt.want[i]) {
+					t.Errorf("CustomProperties.Get(%s) = %v, want %v", name, got, tt.want[i])
+				}
+			}
+		})
+	}
+	if err := decodeCustomProperties(strings.NewReader("<a/>"), new(CustomProperties)); err == nil {
+		t.Error("decodeCustomProperties() want error for a document that is not a custom properties one")
+	}
+}
+
+func TestCustomProperties_decimal(t *testing.T) {
+	content := `<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="2" name="Large"><vt:decimal>12345678901234567890.123</vt:decimal></property>` +
+		`<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="3" name="Integer"><vt:decimal>1000000000000000000000</vt:decimal></property>` +
+		`<property fmtid="{D5CDD505-2E9C-101B-9397-08002B2CF9AE}" pid="4" name="Fraction"><vt:decimal>0.1000000000000000000001</vt:decimal></property>`
+	var c CustomProperties
+	if err := decodeCustomProperties(strings.NewReader(buildCustomString(content)), &c); err != nil {
+		t.Fatalf("decodeCustomProperties() error = %v", err)
+	}
+	var b bytes.Buffer
+	if err := c.encode(&b); err != nil {
+		t.Fatalf("CustomProperties.encode() error = %v", err)
+	}
+	for _, want := range []string{">12345678901234567890.123</vt:decimal>", ">1000000000000000000000</vt:decimal>", ">0.1000000000000000000001</vt:decimal>"} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("CustomProperties.encode() = %v, want it to contain %v", b.String(), want)
+		}
+	}
+	if v, _ := c.Get("Large"); v != (Variant{"decimal", `<vt:decimal xmlns="http://schemas.openxmlformats.org/officeDocument/2006/custom-properties" xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">12345678901234567890.123</vt:decimal>`}) {
+		t.Errorf("CustomProperties.Get() = %v, want a decimal variant", v)
+	}
+}
+
+func TestWriter_CustomProperties(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.CustomProperties.Set("ClassificationLevel", "High")
+	w.CustomProperties.Set("Price", Variant{"cy", `<vt:cy xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">1.5</vt:cy>`})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if r.CustomProperties.PartName != customPropsDefaultName || len(r.Files) != 1 || r.Files[0].Name != customPropsDefaultName {
+		t.Errorf("Reader.CustomProperties.PartName = %s, files = %v", r.CustomProperties.PartName, r.Files)
+	}
+	if v, _ := r.CustomProperties.Get("ClassificationLevel"); v != "High" {
+		t.Errorf("CustomProperties.Get() = %v, want High", v)
+	}
+
+	// Round-trip the unknown variant and delete all the properties afterwards.
+	buf2 := new(bytes.Buffer)
+	w, _ = NewWriterFromReader(buf2, r)
+	w.CustomProperties.Delete("ClassificationLevel")
+	if _, ok := r.CustomProperties.Get("ClassificationLevel"); !ok {
+		t.Error("NewWriterFromReader() shall not share the custom properties with the Reader")
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	r2, err := NewReader(bytes.NewReader(buf2.Bytes()), int64(buf2.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	v, _ := r2.CustomProperties.Get("Price")
+	if vv, ok := v.(Variant); !ok || vv.Type != "cy" || !strings.Contains(vv.XML, ">1.5</vt:cy>") {
+		t.Errorf("CustomProperties.Get() = %v, want cy variant", v)
+	}
+	if got := r2.CustomProperties.Names(); !reflect.DeepEqual(got, []string{"Price"}) {
+		t.Errorf("CustomProperties.Names() = %v", got)
+	}
+
+	buf3 := new(bytes.Buffer)
+	w, _ = NewWriterFromReader(buf3, r2)
+	w.CustomProperties.Delete("Price")
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	r3, err := NewReader(bytes.NewReader(buf3.Bytes()), int64(buf3.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if len(r3.Relationships) != 0 || r3.CustomProperties.PartName != "" || len(r3.Files) != 0 {
+		t.Errorf("Reader.Relationships = %v, want none", r3.Relationships)
+	}
+
+	// The custom properties relationships added by the caller are kept.
+	buf4 := new(bytes.Buffer)
+	w, _ = NewWriterFromReader(buf4, r2)
+	w.CustomProperties.Delete("Price")
+	w.Relationships = append(w.Relationships, &Relationship{ID: "rIdCaller", Type: customPropsRel, TargetURI: "http://a.com/custom.xml", TargetMode: ModeExternal})
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	r4, err := NewReader(bytes.NewReader(buf4.Bytes()), int64(buf4.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if len(r4.Relationships) != 1 || r4.Relationships[0].ID != "rIdCaller" {
+		t.Errorf("Reader.Relationships = %v, want the caller relationship", r4.Relationships)
+	}
+}
+
+func Test_newReader_invalidCustomProperties(t *testing.T) {
+	a := new(mockArchive)
+	a.On("Files").Return([]archiveFile{
+		newMockFile("[Content_Types].xml", ioutil.NopCloser(bytes.NewBufferString(new(cTypeBuilder).
+			withOverride(customPropsContentType, "/docProps/custom.xml").String())), nil),
+		newMockFile("_rels/.rels", ioutil.NopCloser(bytes.NewBufferString(new(relsBuilder).
+			withRel("rId1", customPropsRel, "docProps/custom.xml").String())), nil),
+		newMockFile("docProps/custom.xml", ioutil.NopCloser(bytes.NewBufferString("<a/>")), nil),
+	})
+	r, err := newReader(a)
+	if err != nil {
+		t.Fatalf("newReader() error = %v", err)
+	}
+	if r.CustomProperties.PartName != "" || len(r.CustomProperties.Names()) != 0 {
+		t.Errorf("Reader.CustomProperties = %v, want empty", r.CustomProperties)
+	}
+	if len(r.Files) != 1 || r.Files[0].Name != "/docProps/custom.xml" {
+		t.Errorf("Reader.Files = %v, want the custom properties part", r.Files)
+	}
+}
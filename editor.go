@@ -0,0 +1,212 @@
+package opc
+
+import (
+	"archive/zip"
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// SignatureEditor adds and removes digital signatures of an existing package.
+//
+// Unlike NewWriterFromReader, which re-encodes the relationships and the content types,
+// SignatureEditor copies the contents of every part and relationships part byte for byte,
+// except the ones implementing the removed signatures, so the remaining signatures stay valid.
+// Only the content types stream and the relationships of the Digital Signature Origin part,
+// which cannot be signed, are regenerated.
+type SignatureEditor struct {
+	r       *Reader
+	w       *Writer
+	removed []string
+}
+
+// NewSignatureEditor returns a new SignatureEditor writing to w the package read by r.
+// The parts of r are read when the SignatureEditor is closed, so r shall remain readable until then.
+func NewSignatureEditor(w io.Writer, r *Reader) *SignatureEditor {
+	ew := NewWriter(w)
+	ew.p = r.p.clone()
+	for _, f := range r.Files {
+		ew.digests = append(ew.digests, &partDigest{part: f.Part, file: f})
+		if len(f.Relationships) > 0 {
+			ew.digests = append(ew.digests, &partDigest{
+				part:   &Part{Name: relationshipsPartName(f.Name), ContentType: relationshipContentType},
+				source: f.Name,
+				rels:   f.Relationships,
+			})
+		}
+	}
+	if r.Properties.PartName != "" {
+		name := ResolveRelationship("/", r.Properties.PartName)
+		for _, a := range r.files {
+			if PartName("/" + a.Name()).Equal(PartName(name)) {
+				part := &Part{Name: name, ContentType: corePropsContentType}
+				ew.digests = append(ew.digests, &partDigest{part: part, file: &File{part, a.Size(), a}})
+				break
+			}
+		}
+	}
+	return &SignatureEditor{r: r, w: ew}
+}
+
+// Sign requests a new package digital signature, which will be created when the SignatureEditor is closed.
+// The arguments are the ones of Writer.Sign. If opts does not select the signed parts and relationships,
+// all the parts and relationships of the package not implementing a signature are signed.
+func (e *SignatureEditor) Sign(signer crypto.Signer, certs []*x509.Certificate, opts *SignatureOptions) error {
+	return e.w.Sign(signer, certs, opts)
+}
+
+// Remove requests the removal of the package signature stored in the part name,
+// which shall be the PartName of one of the Reader signatures.
+// The certificate parts of the signature are also removed unless other signatures use them.
+func (e *SignatureEditor) Remove(name string) error {
+	for _, s := range e.r.Signatures {
+		if PartName(s.PartName).Equal(PartName(name)) {
+			e.removed = append(e.removed, s.PartName)
+			return nil
+		}
+	}
+	return fmt.Errorf("opc: %s: cannot be removed: the part is not a package signature", name)
+}
+
+// Close writes the package with the requested signature changes.
+// It does not close the underlying writer.
+func (e *SignatureEditor) Close() error {
+	if err := e.write(); err != nil {
+		e.w.w.Close()
+		return err
+	}
+	return e.w.w.Close()
+}
+
+func (e *SignatureEditor) write() error {
+	origin := e.r.sigParts.origin
+	newOrigin := origin == "" && len(e.w.signatures) > 0
+	skipped := e.removedParts()
+	skipped[partKey(contentTypesName)] = true
+	var pkgRels []*Relationship
+	if newOrigin {
+		// The package has no signatures, so the package relationships can be modified.
+		origin = signatureOriginDefaultName
+		pkgRels = append([]*Relationship(nil), e.r.Relationships...)
+		rel := &Relationship{Type: signatureOriginRel, TargetURI: origin, TargetMode: ModeInternal}
+		pkgRels = append(pkgRels, rel)
+		rel.ID = newRelationshipID(pkgRels)
+		skipped[partKey(packageRelName)] = true
+	}
+	if origin != "" {
+		skipped[partKey(relationshipsPartName(origin))] = true
+	}
+	for _, f := range e.r.files {
+		name := "/" + f.Name()
+		if strings.HasSuffix(name, "/") || skipped[partKey(name)] {
+			continue
+		}
+		if err := e.copyFile(f); err != nil {
+			return err
+		}
+	}
+	if newOrigin {
+		rw, err := e.w.addToPackage(&Part{Name: packageRelName, ContentType: relationshipContentType}, CompressionNormal)
+		if err != nil {
+			return err
+		}
+		e.w.setDigestRelationships("/", pkgRels)
+		if err = encodeRelationships(rw, pkgRels); err != nil {
+			return err
+		}
+	} else if len(e.r.Relationships) > 0 {
+		e.w.digests = append(e.w.digests, &partDigest{
+			part:   &Part{Name: packageRelName, ContentType: relationshipContentType},
+			source: "/",
+			rels:   e.r.Relationships,
+		})
+	}
+	originRels, err := e.w.writeSignatures(e.keptOriginRelationships())
+	if err != nil {
+		return err
+	}
+	if newOrigin {
+		if _, err = e.w.addToPackage(&Part{Name: origin, ContentType: signatureOriginContentType}, CompressionNormal); err != nil {
+			return err
+		}
+	}
+	if len(originRels) > 0 {
+		rw, err := e.w.addToPackage(&Part{Name: relationshipsPartName(origin), ContentType: relationshipContentType}, CompressionNormal)
+		if err != nil {
+			return err
+		}
+		if err = encodeRelationships(rw, originRels); err != nil {
+			return err
+		}
+	}
+	return e.w.createContentTypes()
+}
+
+// removedParts returns the keys, see partKey, of the parts implementing the removed signatures
+// and deletes them from the package.
+func (e *SignatureEditor) removedParts() map[string]bool {
+	sp := e.r.sigParts
+	removed := make(map[string]bool)
+	for _, name := range e.removed {
+		removed[partKey(name)] = true
+		removed[partKey(relationshipsPartName(name))] = true
+		for _, c := range sp.certificates[name] {
+			removed[partKey(c)] = true
+		}
+	}
+	// Keep the certificate parts shared with the remaining signatures.
+	for _, name := range sp.signatures {
+		if removed[partKey(name)] {
+			continue
+		}
+		for _, c := range sp.certificates[name] {
+			delete(removed, partKey(c))
+		}
+	}
+	for name := range removed {
+		e.w.p.deletePart(name)
+		delete(e.w.p.contentTypes.overrides, name)
+	}
+	return removed
+}
+
+// keptOriginRelationships returns the relationships of the origin part
+// that do not target a removed signature.
+func (e *SignatureEditor) keptOriginRelationships() []*Relationship {
+	sp := e.r.sigParts
+	rels := make([]*Relationship, 0, len(sp.originRels)+len(e.w.signatures))
+	for _, r := range sp.originRels {
+		removed := false
+		for _, name := range e.removed {
+			if r.TargetMode == ModeInternal && PartName(ResolveRelationship(sp.origin, r.TargetURI)).Equal(PartName(name)) {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			rels = append(rels, r)
+		}
+	}
+	return rels
+}
+
+func (e *SignatureEditor) copyFile(f archiveFile) error {
+	fh := &zip.FileHeader{
+		Name:     f.Name(),
+		Modified: e.w.now(),
+	}
+	e.w.setCompressor(fh, CompressionNormal)
+	fw, err := e.w.w.CreateHeader(fh)
+	if err != nil {
+		return fmt.Errorf("opc: /%s: cannot be created: %v", f.Name(), err)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("opc: /%s: cannot be opened: %v", f.Name(), err)
+	}
+	defer rc.Close()
+	_, err = io.Copy(fw, rc)
+	return err
+}
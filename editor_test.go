@@ -0,0 +1,115 @@
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/x509"
+	"testing"
+)
+
+func newTwiceSignedPackage(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	for i := 0; i < 2; i++ {
+		key := newTestRSAKey(t)
+		if err := w.Sign(key, []*x509.Certificate{newTestCertificate(t, key)}, nil); err != nil {
+			t.Fatalf("Writer.Sign() error = %v", err)
+		}
+	}
+	pw, _ := w.Create("/a.xml", "application/xml")
+	pw.Write([]byte("<a/>"))
+	w.Relationships = append(w.Relationships, &Relationship{ID: "rId1", Type: "main", TargetURI: "/a.xml"})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestSignatureEditor(t *testing.T) {
+	unsigned, err := OpenReader("testdata/office.docx")
+	if err != nil {
+		t.Fatalf("failed to open test file: %v", err)
+	}
+	defer unsigned.Close()
+	signed := newSignedPackage(t, newTestRSAKey(t), &SignatureOptions{XAdES: &XAdESOptions{}})
+	signedReader, _ := NewReader(bytes.NewReader(signed), int64(len(signed)))
+	twice := newTwiceSignedPackage(t)
+	twiceReader, _ := NewReader(bytes.NewReader(twice), int64(len(twice)))
+	tests := []struct {
+		name       string
+		r          *Reader
+		sign       bool
+		opts       *SignatureOptions
+		remove     []string
+		wantSigs   []string
+		wantAbsent []string
+	}{
+		{"addToUnsigned", unsigned.Reader, true, nil, nil, []string{"/_xmlsignatures/sig1.xml"}, nil},
+		{"addOfficeToUnsigned", unsigned.Reader, true, &SignatureOptions{Office: &OfficeOptions{}}, nil, []string{"/_xmlsignatures/sig1.xml"}, nil},
+		{"addToSigned", signedReader, true, nil, nil, []string{"/_xmlsignatures/sig1.xml", "/_xmlsignatures/sig2.xml"}, nil},
+		{"removeOne", twiceReader, false, nil, []string{"/_xmlsignatures/sig1.xml"}, []string{"/_xmlsignatures/sig2.xml"}, []string{"_xmlsignatures/sig1.xml"}},
+		{"replace", twiceReader, true, nil, []string{"/_XMLSIGNATURES/SIG2.XML"}, []string{"/_xmlsignatures/sig1.xml", "/_xmlsignatures/sig3.xml"}, []string{"_xmlsignatures/sig2.xml"}},
+		{"removeAll", twiceReader, false, nil, []string{"/_xmlsignatures/sig1.xml", "/_xmlsignatures/sig2.xml"}, nil, []string{"_xmlsignatures/sig1.xml", "_xmlsignatures/sig2.xml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			e := NewSignatureEditor(&buf, tt.r)
+			if tt.sign {
+				key := newTestRSAKey(t)
+				if err := e.Sign(key, []*x509.Certificate{newTestCertificate(t, key)}, tt.opts); err != nil {
+					t.Fatalf("SignatureEditor.Sign() error = %v", err)
+				}
+			}
+			for _, name := range tt.remove {
+				if err := e.Remove(name); err != nil {
+					t.Fatalf("SignatureEditor.Remove() error = %v", err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatalf("SignatureEditor.Close() error = %v", err)
+			}
+			r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			if len(r.Signatures) != len(tt.wantSigs) {
+				t.Fatalf("NewReader() signatures = %d, want %d", len(r.Signatures), len(tt.wantSigs))
+			}
+			for i, s := range r.Signatures {
+				if s.PartName != tt.wantSigs[i] {
+					t.Errorf("Signature.PartName = %s, want %s", s.PartName, tt.wantSigs[i])
+				}
+				if !s.Valid() {
+					t.Errorf("Signature.Valid() %s error = %v", s.PartName, s.Err)
+				}
+				if tt.opts == nil && len(s.Uncovered) != 0 {
+					t.Errorf("Signature.Uncovered %s = %v, want none", s.PartName, s.Uncovered)
+				}
+			}
+			if len(r.Files) != len(tt.r.Files) {
+				t.Errorf("NewReader() files = %d, want %d", len(r.Files), len(tt.r.Files))
+			}
+			zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			for _, name := range tt.wantAbsent {
+				for _, f := range zr.File {
+					if f.Name == name {
+						t.Errorf("SignatureEditor.Close() part %s not removed", name)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSignatureEditor_Remove(t *testing.T) {
+	b := newSignedPackage(t, newTestRSAKey(t), nil)
+	r, _ := NewReader(bytes.NewReader(b), int64(len(b)))
+	e := NewSignatureEditor(new(bytes.Buffer), r)
+	for _, name := range []string{"/a.xml", "/_xmlsignatures/origin.sigs", "/_xmlsignatures/sig2.xml"} {
+		if err := e.Remove(name); err == nil {
+			t.Errorf("SignatureEditor.Remove(%s) want error", name)
+		}
+	}
+}
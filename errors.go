@@ -24,10 +24,42 @@ var errorsString = map[int]string{
 	127: "a relationship type cannot be empty",
 	128: "a relationship target URI reference shall be a URI or a relative reference",
 	129: "a relationship target URI must be relative if the TargetMode is Internal",
+	130: "a relationship target shall be a part of the package if the TargetMode is Internal",
 	205: "a Default content type shall not have more than one content type for each extension and a Override shall not have more than one content type for each PartName",
 	206: "a package shall not have an empty extension in a Default element",
 	208: "a part content type shall appear in [Content_Types].xml",
 	310: "a package shall contain a file named [Content_Types].xml to store all the data content types",
+	311: "a piece name shall be [n].piece or [n].last.piece, where n is a decimal number without leading zeros",
+	312: "the pieces of an interleaved part shall be numbered contiguously starting with 0",
+	313: "an interleaved part shall have exactly one last piece, which shall be the one with the highest number",
+	401: "a package shall not have more than one core properties relationship",
+	402: "the core properties part shall not use the Markup Compatibility namespace",
+	403: "the core properties part shall not contain refinements of the Dublin Core elements other than dcterms:created and dcterms:modified",
+	404: "the xml:lang attribute shall only be used in the Dublin Core elements and the keywords of the core properties part",
+	405: "the xsi:type attribute shall only be used in the dcterms:created and dcterms:modified core properties, where it shall be present with the value dcterms:W3CDTF",
+	406: "the created, lastPrinted and modified core properties shall be dates formatted as defined in the W3C Date and Time Formats profile",
+	501: "a thumbnail part shall have an image content type",
+	502: "a package or a part shall not have more than one thumbnail relationship",
+	601: "a package shall not have more than one Digital Signature Origin part",
+	605: "a signature shall be created using a supported digest and signature method",
+	607: "a reference to a part shall include its content type in the ContentType query component",
+	608: "a signature shall not reference the content types stream or the Digital Signature Origin part and its relationships",
+	609: "a signature shall only reference parts that exist in the package",
+	610: "a signature shall contain the X.509 certificate of the signer",
+	611: "a signature shall contain exactly one signed package-specific Object element",
+	612: "a package-specific Object element shall contain a Manifest element and a valid SignatureTime signature property",
+	613: "a reference digest value shall match the digest of the referenced content",
+	614: "a signature value shall be valid for the canonicalized SignedInfo element and the signer certificate",
+	615: "a reference shall only use the transforms defined for package digital signatures",
+	616: "a Digital Signature XML Signature part shall contain a well-formed XML signature",
+	617: "a XAdES signature shall sign its SignedProperties element, which shall identify the signer certificate",
+	618: "a XAdES signature timestamp shall be a valid RFC 3161 time-stamp token over the canonicalized SignatureValue element",
+	619: "the signer certificate shall chain to a trusted root and be valid at the signing time",
+	620: "the certificates of the signer chain shall not be revoked at the signing time",
+	621: "the revocation status of the signer chain shall be stated by a valid CRL or OCSP response",
+	701: "a pack URI shall use the pack scheme and have an authority",
+	702: "a pack URI authority shall encode an absolute package URI without fragment",
+	703: "a pack URI shall not have a query component",
 }
 
 // An Error from this package is always associated to an OPC entity that is not conformant with the OPC specs.
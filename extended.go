@@ -0,0 +1,473 @@
+package opc
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/qmuntal/opc/c14n"
+)
+
+const (
+	extendedPropsRel         = "http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties"
+	extendedPropsContentType = "application/vnd.openxmlformats-officedocument.extended-properties+xml"
+	extendedPropsDefaultName = "/docProps/app.xml"
+	extendedPropsNamespace   = "http://schemas.openxmlformats.org/officeDocument/2006/extended-properties"
+	vtNamespace              = "http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes"
+)
+
+// ExtendedProperties are the application specific properties of an Office document,
+// stored in the extended properties part defined in ISO/IEC 29500-1 §22.2.
+// The properties with a zero value are not written.
+// When the properties are read from a package, the elements they don't model
+// and the ones whose value is not modified are written back as they were read.
+type ExtendedProperties struct {
+	PartName             string        // Won't be written to the package, only used to indicate the location of the ExtendedProperties part. If empty the default location is "/docProps/app.xml".
+	RelationshipID       string        // Won't be written to the package, only used to indicate the relationship ID for the ExtendedProperties part.
+	Template             string        // The name of the document template.
+	Manager              string        // The name of the supervisor of the document creator.
+	Company              string        // The name of the company associated with the document.
+	Pages                int           // The total number of pages.
+	Words                int           // The total number of words.
+	Characters           int           // The total number of characters.
+	PresentationFormat   string        // The intended format of a presentation document.
+	Lines                int           // The total number of lines.
+	Paragraphs           int           // The total number of paragraphs.
+	Slides               int           // The total number of slides of a presentation document.
+	Notes                int           // The number of slides of a presentation document that contain notes.
+	TotalTime            int           // The total time that the document has been edited, in minutes.
+	HiddenSlides         int           // The number of hidden slides of a presentation document.
+	MMClips              int           // The total number of sound or video clips.
+	ScaleCrop            bool          // Indicates the display mode of the document thumbnail. If true the thumbnail is scaled, otherwise it is cropped.
+	HeadingPairs         []HeadingPair // The grouping of the document parts listed in TitlesOfParts.
+	TitlesOfParts        []string      // The titles of the document parts.
+	LinksUpToDate        bool          // Indicates whether the hyperlinks of the document are up to date.
+	CharactersWithSpaces int           // The total number of characters, including spaces.
+	SharedDoc            bool          // Indicates whether the document is shared between multiple producers.
+	HyperlinkBase        string        // The base string used to evaluate the relative hyperlinks of the document.
+	HLinks               []Hyperlink   // The hyperlinks of the document.
+	HyperlinksChanged    bool          // Indicates whether the hyperlinks have been changed and shall be updated by the consumer.
+	DigSig               []byte        // The signature of a digitally signed VBA project.
+	Application          string        // The name of the application that created the document.
+	AppVersion           string        // The version of the application that produced the document, formatted as XX.YYYY.
+	DocSecurity          int           // The security level of the document.
+	doc                  *xmlElement   // The decoded document, whose unknown content is written back.
+}
+
+// HeadingPair names a group of consecutive TitlesOfParts, which has Count titles.
+type HeadingPair struct {
+	Name  string
+	Count int
+}
+
+// Hyperlink is a hyperlink of the document stored in the HLinks extended property,
+// whose fields are the ones of the VtHyperlink structure defined in [MS-OSHARED].
+type Hyperlink struct {
+	Hash           int    // A hash of the Target and Location.
+	App            int    // An application specific value.
+	OfficeDocument int    // An application specific value.
+	Info           int    // Whether the hyperlink is an external or an internal one, among others.
+	Target         string // The hyperlink target.
+	Location       string // The location within the target.
+}
+
+// isZero reports whether e has no property to be written.
+// The properties decoded from a package are written even if they are all empty,
+// so their unknown content is preserved.
+func (e *ExtendedProperties) isZero() bool {
+	return e.doc == nil && e.Template == "" && e.Manager == "" && e.Company == "" &&
+		e.Pages == 0 && e.Words == 0 && e.Characters == 0 && e.PresentationFormat == "" &&
+		e.Lines == 0 && e.Paragraphs == 0 && e.Slides == 0 && e.Notes == 0 && e.TotalTime == 0 &&
+		e.HiddenSlides == 0 && e.MMClips == 0 && !e.ScaleCrop && len(e.HeadingPairs) == 0 &&
+		len(e.TitlesOfParts) == 0 && !e.LinksUpToDate && e.CharactersWithSpaces == 0 && !e.SharedDoc &&
+		e.HyperlinkBase == "" && len(e.HLinks) == 0 && !e.HyperlinksChanged && len(e.DigSig) == 0 &&
+		e.Application == "" && e.AppVersion == "" && e.DocSecurity == 0
+}
+
+// extendedProperty is an element of the extended properties part
+// and the field of ExtendedProperties holding its value.
+type extendedProperty struct {
+	local string
+	value interface{} // A pointer to the field.
+}
+
+// properties returns the properties of e in the order defined by the schema.
+func (e *ExtendedProperties) properties() []extendedProperty {
+	return []extendedProperty{
+		{"Template", &e.Template}, {"Manager", &e.Manager}, {"Company", &e.Company},
+		{"Pages", &e.Pages}, {"Words", &e.Words}, {"Characters", &e.Characters},
+		{"PresentationFormat", &e.PresentationFormat}, {"Lines", &e.Lines}, {"Paragraphs", &e.Paragraphs},
+		{"Slides", &e.Slides}, {"Notes", &e.Notes}, {"TotalTime", &e.TotalTime},
+		{"HiddenSlides", &e.HiddenSlides}, {"MMClips", &e.MMClips}, {"ScaleCrop", &e.ScaleCrop},
+		{"HeadingPairs", &e.HeadingPairs}, {"TitlesOfParts", &e.TitlesOfParts}, {"LinksUpToDate", &e.LinksUpToDate},
+		{"CharactersWithSpaces", &e.CharactersWithSpaces}, {"SharedDoc", &e.SharedDoc}, {"HyperlinkBase", &e.HyperlinkBase},
+		{"HLinks", &e.HLinks}, {"HyperlinksChanged", &e.HyperlinksChanged}, {"DigSig", &e.DigSig},
+		{"Application", &e.Application}, {"AppVersion", &e.AppVersion}, {"DocSecurity", &e.DocSecurity},
+	}
+}
+
+// isEmpty reports whether the property has a zero value, which is not written unless it was decoded.
+func (p extendedProperty) isEmpty() bool {
+	v := reflect.ValueOf(p.value).Elem()
+	if v.Kind() == reflect.Slice {
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+// equal reports whether p and q hold the same value.
+func (p extendedProperty) equal(q extendedProperty) bool {
+	if p.isEmpty() && q.isEmpty() {
+		return true
+	}
+	return reflect.DeepEqual(reflect.ValueOf(p.value).Elem().Interface(), reflect.ValueOf(q.value).Elem().Interface())
+}
+
+// findExtendedProperty returns the index of the property held by e, or -1 if e is not a known property.
+func findExtendedProperty(props []extendedProperty, e *xmlElement) int {
+	for i, p := range props {
+		if e.is(extendedPropsNamespace, p.local) {
+			return i
+		}
+	}
+	return -1
+}
+
+type extendedPropertiesXMLMarshal struct {
+	XMLName              xml.Name         `xml:"Properties"`
+	XML                  string           `xml:"xmlns,attr"`
+	XMLVT                string           `xml:"xmlns:vt,attr"`
+	Template             string           `xml:"Template,omitempty"`
+	Manager              string           `xml:"Manager,omitempty"`
+	Company              string           `xml:"Company,omitempty"`
+	Pages                int              `xml:"Pages,omitempty"`
+	Words                int              `xml:"Words,omitempty"`
+	Characters           int              `xml:"Characters,omitempty"`
+	PresentationFormat   string           `xml:"PresentationFormat,omitempty"`
+	Lines                int              `xml:"Lines,omitempty"`
+	Paragraphs           int              `xml:"Paragraphs,omitempty"`
+	Slides               int              `xml:"Slides,omitempty"`
+	Notes                int              `xml:"Notes,omitempty"`
+	TotalTime            int              `xml:"TotalTime,omitempty"`
+	HiddenSlides         int              `xml:"HiddenSlides,omitempty"`
+	MMClips              int              `xml:"MMClips,omitempty"`
+	ScaleCrop            bool             `xml:"ScaleCrop,omitempty"`
+	HeadingPairs         *vtVectorMarshal `xml:"HeadingPairs>vt:vector,omitempty"`
+	TitlesOfParts        *vtVectorMarshal `xml:"TitlesOfParts>vt:vector,omitempty"`
+	LinksUpToDate        bool             `xml:"LinksUpToDate,omitempty"`
+	CharactersWithSpaces int              `xml:"CharactersWithSpaces,omitempty"`
+	SharedDoc            bool             `xml:"SharedDoc,omitempty"`
+	HyperlinkBase        string           `xml:"HyperlinkBase,omitempty"`
+	HLinks               *vtVectorMarshal `xml:"HLinks>vt:vector,omitempty"`
+	HyperlinksChanged    bool             `xml:"HyperlinksChanged,omitempty"`
+	DigSig               *vtBlobMarshal   `xml:"DigSig,omitempty"`
+	Application          string           `xml:"Application,omitempty"`
+	AppVersion           string           `xml:"AppVersion,omitempty"`
+	DocSecurity          int              `xml:"DocSecurity,omitempty"`
+}
+
+// vtXML is a value of a docPropsVTypes type, such as vt:lpstr or vt:i4.
+type vtXML struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type vtVariantMarshal struct {
+	Value vtXML
+}
+
+type vtVectorMarshal struct {
+	Size     int                `xml:"size,attr"`
+	BaseType string             `xml:"baseType,attr"`
+	Variants []vtVariantMarshal `xml:"vt:variant"`
+	Values   []vtXML
+}
+
+type vtBlobMarshal struct {
+	Blob string `xml:"vt:blob"`
+}
+
+func newVT(vtType, value string) vtXML {
+	return vtXML{XMLName: xml.Name{Local: "vt:" + vtType}, Value: value}
+}
+
+func (e *ExtendedProperties) encode(w io.Writer) error {
+	if e.doc != nil {
+		w.Write(([]byte)(xml.Header))
+		return e.encodeDocument(w)
+	}
+	return e.encodeStruct(w)
+}
+
+// encodeStruct writes the properties of e to a new document.
+func (e *ExtendedProperties) encodeStruct(w io.Writer) error {
+	x := &extendedPropertiesXMLMarshal{
+		XML: extendedPropsNamespace, XMLVT: vtNamespace,
+		Template: e.Template, Manager: e.Manager, Company: e.Company,
+		Pages: e.Pages, Words: e.Words, Characters: e.Characters,
+		PresentationFormat: e.PresentationFormat, Lines: e.Lines, Paragraphs: e.Paragraphs,
+		Slides: e.Slides, Notes: e.Notes, TotalTime: e.TotalTime,
+		HiddenSlides: e.HiddenSlides, MMClips: e.MMClips, ScaleCrop: e.ScaleCrop,
+		LinksUpToDate: e.LinksUpToDate, CharactersWithSpaces: e.CharactersWithSpaces, SharedDoc: e.SharedDoc,
+		HyperlinkBase: e.HyperlinkBase, HyperlinksChanged: e.HyperlinksChanged,
+		Application: e.Application, AppVersion: e.AppVersion, DocSecurity: e.DocSecurity,
+	}
+	if len(e.HeadingPairs) > 0 {
+		x.HeadingPairs = &vtVectorMarshal{Size: 2 * len(e.HeadingPairs), BaseType: "variant"}
+		for _, h := range e.HeadingPairs {
+			x.HeadingPairs.Variants = append(x.HeadingPairs.Variants,
+				vtVariantMarshal{newVT("lpstr", h.Name)}, vtVariantMarshal{newVT("i4", strconv.Itoa(h.Count))})
+		}
+	}
+	if len(e.TitlesOfParts) > 0 {
+		x.TitlesOfParts = &vtVectorMarshal{Size: len(e.TitlesOfParts), BaseType: "lpstr"}
+		for _, t := range e.TitlesOfParts {
+			x.TitlesOfParts.Values = append(x.TitlesOfParts.Values, newVT("lpstr", t))
+		}
+	}
+	if len(e.HLinks) > 0 {
+		x.HLinks = &vtVectorMarshal{Size: 6 * len(e.HLinks), BaseType: "variant"}
+		for _, h := range e.HLinks {
+			x.HLinks.Variants = append(x.HLinks.Variants,
+				vtVariantMarshal{newVT("i4", strconv.Itoa(h.Hash))}, vtVariantMarshal{newVT("i4", strconv.Itoa(h.App))},
+				vtVariantMarshal{newVT("i4", strconv.Itoa(h.OfficeDocument))}, vtVariantMarshal{newVT("i4", strconv.Itoa(h.Info))},
+				vtVariantMarshal{newVT("lpwstr", h.Target)}, vtVariantMarshal{newVT("lpwstr", h.Location)})
+		}
+	}
+	if len(e.DigSig) > 0 {
+		x.DigSig = &vtBlobMarshal{base64.StdEncoding.EncodeToString(e.DigSig)}
+	}
+	w.Write(([]byte)(xml.Header))
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "    ")
+	return enc.Encode(x)
+}
+
+// encodeDocument writes the decoded document updated with the properties of e.
+// The unknown elements, and the known ones whose value has not changed, are written as they were decoded.
+func (e *ExtendedProperties) encodeDocument(w io.Writer) error {
+	src := e.doc.root()
+	doc := new(xmlElement)
+	root := &xmlElement{Name: src.Name, Attr: append([]xml.Attr(nil), src.Attr...), parent: doc}
+	doc.Children = []xml.Token{root}
+	props := e.properties()
+	written := make([]bool, len(props))
+	var indent, trailing xml.CharData
+	for i, child := range src.Children {
+		el, ok := child.(*xmlElement)
+		if !ok {
+			if cd, ok := child.(xml.CharData); ok && len(bytes.TrimSpace(cd)) == 0 {
+				if i == len(src.Children)-1 {
+					trailing = cd
+					continue
+				}
+				if indent == nil {
+					indent = cd
+				}
+			}
+			root.Children = append(root.Children, child)
+			continue
+		}
+		j := findExtendedProperty(props, el)
+		if j < 0 {
+			root.Children = append(root.Children, el)
+			continue
+		}
+		var decoded ExtendedProperties
+		unchanged := decodeExtendedProperty(el, decoded.properties()[j].value) == nil && props[j].equal(decoded.properties()[j])
+		if written[j] || (!unchanged && props[j].isEmpty()) {
+			// Remove the indentation of the removed element.
+			if n := len(root.Children); n > 0 {
+				if cd, ok := root.Children[n-1].(xml.CharData); ok && len(bytes.TrimSpace(cd)) == 0 {
+					root.Children = root.Children[:n-1]
+				}
+			}
+			continue
+		}
+		written[j] = true
+		if !unchanged {
+			ne, err := e.newPropertyElement(j)
+			if err != nil {
+				return err
+			}
+			el = ne
+		}
+		root.Children = append(root.Children, el)
+	}
+	for i, p := range props {
+		if written[i] || p.isEmpty() {
+			continue
+		}
+		if indent != nil {
+			root.Children = append(root.Children, indent)
+		}
+		el, err := e.newPropertyElement(i)
+		if err != nil {
+			return err
+		}
+		root.Children = append(root.Children, el)
+	}
+	if trailing != nil {
+		root.Children = append(root.Children, trailing)
+	}
+	b, err := canonicalize(doc, c14n.CanonicalWithComments)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be encoded: %v", e.partName(), err)
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// newPropertyElement returns the element holding the property i of e as written to a new document,
+// which declares the namespaces it uses.
+func (e *ExtendedProperties) newPropertyElement(i int) (*xmlElement, error) {
+	var single ExtendedProperties
+	reflect.ValueOf(single.properties()[i].value).Elem().Set(reflect.ValueOf(e.properties()[i].value).Elem())
+	var b bytes.Buffer
+	if err := single.encodeStruct(&b); err != nil {
+		return nil, err
+	}
+	doc, err := parseXMLTree(&b)
+	if err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot be encoded: %v", e.partName(), err)
+	}
+	el := doc.root().element(extendedPropsNamespace, e.properties()[i].local)
+	el.Attr = append(el.Attr, xml.Attr{Name: xml.Name{Local: "xmlns"}, Value: extendedPropsNamespace},
+		xml.Attr{Name: xml.Name{Space: "xmlns", Local: "vt"}, Value: vtNamespace})
+	return el, nil
+}
+
+func decodeExtendedProperties(r io.Reader, props *ExtendedProperties) error {
+	name := props.partName()
+	doc, err := parseXMLTree(r)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be decoded: %v", name, err)
+	}
+	root := doc.root()
+	if root == nil || !root.is(extendedPropsNamespace, "Properties") {
+		return fmt.Errorf("opc: %s: cannot be decoded: expected an extended properties document", name)
+	}
+	fields := props.properties()
+	for _, p := range fields {
+		reflect.ValueOf(p.value).Elem().Set(reflect.Zero(reflect.TypeOf(p.value).Elem()))
+	}
+	found := make([]bool, len(fields))
+	for _, c := range root.Children {
+		if e, ok := c.(*xmlElement); ok {
+			if i := findExtendedProperty(fields, e); i >= 0 && !found[i] {
+				if err := decodeExtendedProperty(e, fields[i].value); err != nil {
+					return fmt.Errorf("opc: %s: cannot be decoded: %v", name, err)
+				}
+				found[i] = true
+			}
+		}
+	}
+	props.doc = doc
+	return nil
+}
+
+// decodeExtendedProperty decodes the property held by e into value, which points to the field of the property.
+func decodeExtendedProperty(e *xmlElement, value interface{}) error {
+	text := strings.TrimSpace(e.text())
+	switch v := value.(type) {
+	case *string:
+		*v = e.text()
+	case *int:
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", e.Name.Local, err)
+		}
+		*v = n
+	case *bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("invalid %s: %v", e.Name.Local, err)
+		}
+		*v = b
+	case *[]string:
+		*v = nil
+		for _, t := range vectorValues(e) {
+			*v = append(*v, t.text())
+		}
+	case *[]HeadingPair:
+		values := vectorValues(e)
+		if len(values)%2 != 0 {
+			return fmt.Errorf("the HeadingPairs vector shall hold name and count pairs")
+		}
+		*v = nil
+		for i := 0; i < len(values); i += 2 {
+			n, err := strconv.Atoi(strings.TrimSpace(values[i+1].text()))
+			if err != nil {
+				return fmt.Errorf("invalid HeadingPairs count: %v", err)
+			}
+			*v = append(*v, HeadingPair{values[i].text(), n})
+		}
+	case *[]Hyperlink:
+		values := vectorValues(e)
+		if len(values)%6 != 0 {
+			return fmt.Errorf("the HLinks vector shall hold groups of six variants")
+		}
+		*v = nil
+		for i := 0; i < len(values); i += 6 {
+			var ints [4]int
+			for j := range ints {
+				n, err := strconv.Atoi(strings.TrimSpace(values[i+j].text()))
+				if err != nil {
+					return fmt.Errorf("invalid HLinks value: %v", err)
+				}
+				ints[j] = n
+			}
+			*v = append(*v, Hyperlink{ints[0], ints[1], ints[2], ints[3], values[i+4].text(), values[i+5].text()})
+		}
+	case *[]byte:
+		*v = nil
+		if blob := e.element(vtNamespace, "blob"); blob != nil {
+			b, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(blob.text()), ""))
+			if err != nil {
+				return fmt.Errorf("invalid DigSig: %v", err)
+			}
+			if len(b) > 0 {
+				*v = b
+			}
+		}
+	}
+	return nil
+}
+
+// vectorValues returns the values of the vt:vector held by e, extracting them from the variants if needed.
+func vectorValues(e *xmlElement) []*xmlElement {
+	vector := e.element(vtNamespace, "vector")
+	if vector == nil {
+		return nil
+	}
+	var values []*xmlElement
+	for _, c := range vector.Children {
+		value, ok := c.(*xmlElement)
+		if !ok {
+			continue
+		}
+		if value.is(vtNamespace, "variant") {
+			for _, vc := range value.Children {
+				if ve, ok := vc.(*xmlElement); ok {
+					value = ve
+					break
+				}
+			}
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// partName returns the absolute name of the extended properties part.
+func (e *ExtendedProperties) partName() string {
+	if e.PartName == "" {
+		return extendedPropsDefaultName
+	}
+	return ResolveRelationship("/", e.PartName)
+}
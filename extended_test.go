@@ -0,0 +1,267 @@
+package opc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func buildExtendedString(content string) string {
+	s := `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+	s += `<Properties xmlns="http://schemas.openxmlformats.org/officeDocument/2006/extended-properties"`
+	s += ` xmlns:vt="http://schemas.openxmlformats.org/officeDocument/2006/docPropsVTypes">`
+	return s + content + "</Properties>"
+}
+
+func TestExtendedProperties_encode(t *testing.T) {
+	tests := []struct {
+		name string
+		e    *ExtendedProperties
+		want string
+	}{
+		{"empty", &ExtendedProperties{}, buildExtendedString("")},
+		{"simple", &ExtendedProperties{Application: "opc", Pages: 2, ScaleCrop: true, DigSig: []byte("sig")}, buildExtendedString(`
+    <Pages>2</Pages>
+    <ScaleCrop>true</ScaleCrop>
+    <DigSig>
+        <vt:blob>c2ln</vt:blob>
+    </DigSig>
+    <Application>opc</Application>
+`)},
+		{"vectors", &ExtendedProperties{
+			HeadingPairs:  []HeadingPair{{"Title", 1}},
+			TitlesOfParts: []string{"a"},
+			HLinks:        []Hyperlink{{1, 2, 3, 4, "http://a.com", "b"}},
+		}, buildExtendedString(`
+    <HeadingPairs>
+        <vt:vector size="2" baseType="variant">
+            <vt:variant>
+                <vt:lpstr>Title</vt:lpstr>
+            </vt:variant>
+            <vt:variant>
+                <vt:i4>1</vt:i4>
+            </vt:variant>
+        </vt:vector>
+    </HeadingPairs>
+    <TitlesOfParts>
+        <vt:vector size="1" baseType="lpstr">
+            <vt:lpstr>a</vt:lpstr>
+        </vt:vector>
+    </TitlesOfParts>
+    <HLinks>
+        <vt:vector size="6" baseType="variant">
+            <vt:variant>
+                <vt:i4>1</vt:i4>
+            </vt:variant>
+            <vt:variant>
+                <vt:i4>2</vt:i4>
+            </vt:variant>
+            <vt:variant>
+                <vt:i4>3</vt:i4>
+            </vt:variant>
+            <vt:variant>
+                <vt:i4>4</vt:i4>
+            </vt:variant>
+            <vt:variant>
+                <vt:lpwstr>http://a.com</vt:lpwstr>
+            </vt:variant>
+            <vt:variant>
+                <vt:lpwstr>b</vt:lpwstr>
+            </vt:variant>
+        </vt:vector>
+    </HLinks>
+`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := new(bytes.Buffer)
+			if err := tt.e.encode(w); err != nil {
+				t.Fatalf("ExtendedProperties.encode() error = %v", err)
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("ExtendedProperties.encode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_decodeExtendedProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    ExtendedProperties
+		wantErr bool
+	}{
+		{"simple", `<Template>Normal.dotm</Template><TotalTime>1</TotalTime><Application>Microsoft Office Word</Application>
+			<DocSecurity>0</DocSecurity><ScaleCrop>false</ScaleCrop><SharedDoc>true</SharedDoc><AppVersion>16.0000</AppVersion>
+			<DigSig><vt:blob>c2ln
+			</vt:blob></DigSig>`,
+			ExtendedProperties{Template: "Normal.dotm", TotalTime: 1, Application: "Microsoft Office Word", SharedDoc: true, AppVersion: "16.0000", DigSig: []byte("sig")}, false},
+		{"vectors", `<HeadingPairs><vt:vector size="4" baseType="variant">
+			<vt:variant><vt:lpstr>Worksheets</vt:lpstr></vt:variant><vt:variant><vt:i4>2</vt:i4></vt:variant>
+			<vt:variant><vt:lpstr>Named Ranges</vt:lpstr></vt:variant><vt:variant><vt:i4>1</vt:i4></vt:variant>
+			</vt:vector></HeadingPairs>
+			<TitlesOfParts><vt:vector size="3" baseType="lpstr"><vt:lpstr>Sheet1</vt:lpstr><vt:lpstr>Sheet2</vt:lpstr><vt:lpstr>Range</vt:lpstr></vt:vector></TitlesOfParts>
+			<HLinks><vt:vector size="6" baseType="variant">
+			<vt:variant><vt:i4>-1</vt:i4></vt:variant><vt:variant><vt:i4>0</vt:i4></vt:variant><vt:variant><vt:i4>0</vt:i4></vt:variant>
+			<vt:variant><vt:i4>5</vt:i4></vt:variant><vt:variant><vt:lpwstr>http://a.com</vt:lpwstr></vt:variant><vt:variant><vt:lpwstr></vt:lpwstr></vt:variant>
+			</vt:vector></HLinks>`,
+			ExtendedProperties{
+				HeadingPairs:  []HeadingPair{{"Worksheets", 2}, {"Named Ranges", 1}},
+				TitlesOfParts: []string{"Sheet1", "Sheet2", "Range"},
+				HLinks:        []Hyperlink{{-1, 0, 0, 5, "http://a.com", ""}},
+			}, false},
+		{"invalidXML", `<Pages>`, ExtendedProperties{}, true},
+		{"invalidInt", `<Pages>a</Pages>`, ExtendedProperties{}, true},
+		{"oddHeadingPairs", `<HeadingPairs><vt:vector size="1" baseType="variant"><vt:variant><vt:lpstr>a</vt:lpstr></vt:variant></vt:vector></HeadingPairs>`, ExtendedProperties{}, true},
+		{"invalidHeadingPairsCount", `<HeadingPairs><vt:vector size="2" baseType="variant"><vt:variant><vt:lpstr>a</vt:lpstr></vt:variant><vt:variant><vt:lpstr>b</vt:lpstr></vt:variant></vt:vector></HeadingPairs>`, ExtendedProperties{}, true},
+		{"incompleteHLinks", `<HLinks><vt:vector size="1" baseType="variant"><vt:variant><vt:i4>1</vt:i4></vt:variant></vt:vector></HLinks>`, ExtendedProperties{}, true},
+		{"invalidDigSig", `<DigSig><vt:blob>!</vt:blob></DigSig>`, ExtendedProperties{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ExtendedProperties
+			err := decodeExtendedProperties(strings.NewReader(buildExtendedString(tt.content)), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeExtendedProperties() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got.doc = nil
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeExtendedProperties() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriter_ExtendedProperties(t *testing.T) {
+	r, err := OpenReader("testdata/office.docx")
+	if err != nil {
+		t.Fatalf("OpenReader() error = %v", err)
+	}
+	defer r.Close()
+	want := r.ExtendedProperties
+	if want.Application != "Microsoft Office Word" || want.PartName != "docProps/app.xml" {
+		t.Fatalf("Reader.ExtendedProperties = %v", want)
+	}
+	var found bool
+	for _, f := range r.Files {
+		found = found || f.Name == "/docProps/app.xml"
+	}
+	if !found {
+		t.Error("Reader.Files does not contain the extended properties part")
+	}
+	var buf bytes.Buffer
+	w, err := NewWriterFromReader(&buf, r.Reader)
+	if err != nil {
+		t.Fatalf("NewWriterFromReader() error = %v", err)
+	}
+	w.ExtendedProperties.Company = "opc"
+	want.Company = "opc"
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	got, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	got.ExtendedProperties.doc, want.doc = nil, nil
+	if !reflect.DeepEqual(got.ExtendedProperties, want) {
+		t.Errorf("Reader.ExtendedProperties = %v, want %v", got.ExtendedProperties, want)
+	}
+	var rels int
+	for _, rel := range got.Relationships {
+		if rel.Type == extendedPropsRel {
+			rels++
+		}
+	}
+	if rels != 1 {
+		t.Errorf("Reader.Relationships has %d extended properties relationships, want 1", rels)
+	}
+
+	buf.Reset()
+	w = NewWriter(&buf)
+	w.ExtendedProperties = ExtendedProperties{Application: "opc", TitlesOfParts: []string{"a"}}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	got, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	want = ExtendedProperties{PartName: extendedPropsDefaultName, RelationshipID: "rId0", Application: "opc", TitlesOfParts: []string{"a"}}
+	got.ExtendedProperties.doc = nil
+	if !reflect.DeepEqual(got.ExtendedProperties, want) {
+		t.Errorf("Reader.ExtendedProperties = %v, want %v", got.ExtendedProperties, want)
+	}
+}
+
+func TestExtendedProperties_encodeDocument(t *testing.T) {
+	content := `
+	<Company>a</Company>
+	<!-- comment -->
+	<HeadingPairs><vt:vector baseType="variant" size="2"><vt:variant><vt:lpwstr>Title</vt:lpwstr></vt:variant><vt:variant><vt:i2>1</vt:i2></vt:variant></vt:vector></HeadingPairs>
+	<Unknown xmlns="urn:custom">b</Unknown>
+	<ScaleCrop>false</ScaleCrop>
+`
+	tests := []struct {
+		name   string
+		update func(*ExtendedProperties)
+		want   string
+	}{
+		{"unchanged", func(*ExtendedProperties) {}, content},
+		{"changed", func(e *ExtendedProperties) { e.Company = "c" }, strings.Replace(content, "<Company>a</Company>", "<Company>c</Company>", 1)},
+		{"removed", func(e *ExtendedProperties) { e.Company = "" }, strings.Replace(content, "\n\t<Company>a</Company>", "", 1)},
+		{"added", func(e *ExtendedProperties) { e.Pages = 2 }, strings.TrimSuffix(content, "\n") + "\n\t<Pages>2</Pages>\n"},
+		{"vector", func(e *ExtendedProperties) { e.HeadingPairs[0].Count = 2 }, strings.Replace(content,
+			`<HeadingPairs><vt:vector baseType="variant" size="2"><vt:variant><vt:lpwstr>Title</vt:lpwstr></vt:variant><vt:variant><vt:i2>1</vt:i2></vt:variant></vt:vector></HeadingPairs>`, `<HeadingPairs>
+        <vt:vector baseType="variant" size="2">
+            <vt:variant>
+                <vt:lpstr>Title</vt:lpstr>
+            </vt:variant>
+            <vt:variant>
+                <vt:i4>2</vt:i4>
+            </vt:variant>
+        </vt:vector>
+    </HeadingPairs>`, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e ExtendedProperties
+			if err := decodeExtendedProperties(strings.NewReader(buildExtendedString(content)), &e); err != nil {
+				t.Fatalf("decodeExtendedProperties() error = %v", err)
+			}
+			tt.update(&e)
+			w := new(bytes.Buffer)
+			if err := e.encode(w); err != nil {
+				t.Fatalf("ExtendedProperties.encode() error = %v", err)
+			}
+			if got, want := w.String(), buildExtendedString(tt.want); got != want {
+				t.Errorf("ExtendedProperties.encode() = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func Test_newReader_invalidExtendedProperties(t *testing.T) {
+	a := new(mockArchive)
+	a.On("Files").Return([]archiveFile{
+		newMockFile("[Content_Types].xml", ioutil.NopCloser(bytes.NewBufferString(new(cTypeBuilder).
+			withOverride(extendedPropsContentType, "/docProps/app.xml").String())), nil),
+		newMockFile("_rels/.rels", ioutil.NopCloser(bytes.NewBufferString(new(relsBuilder).
+			withRel("rId1", extendedPropsRel, "docProps/app.xml").String())), nil),
+		newMockFile("docProps/app.xml", ioutil.NopCloser(bytes.NewBufferString("<Pages>a</Pages>")), nil),
+	})
+	r, err := newReader(a)
+	if err != nil {
+		t.Fatalf("newReader() error = %v", err)
+	}
+	want := ExtendedProperties{PartName: "docProps/app.xml", RelationshipID: "rId1"}
+	if !reflect.DeepEqual(r.ExtendedProperties, want) {
+		t.Errorf("Reader.ExtendedProperties = %v, want %v", r.ExtendedProperties, want)
+	}
+	if len(r.Files) != 1 || r.Files[0].Name != "/docProps/app.xml" {
+		t.Errorf("Reader.Files = %v, want the extended properties part", r.Files)
+	}
+}
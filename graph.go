@@ -0,0 +1,179 @@
+package opc
+
+import (
+	"path"
+	"strings"
+)
+
+// SourcedRelationship is a relationship along with the name of its source part,
+// which is "/" for the package relationships.
+type SourcedRelationship struct {
+	Source string
+	*Relationship
+}
+
+// TargetName returns the normalized name of the part targeted by the relationship,
+// or an empty string if it is external.
+func (r SourcedRelationship) TargetName() string {
+	if r.TargetMode != ModeInternal {
+		return ""
+	}
+	return NormalizePartName(path.Clean(ResolveRelationship(r.Source, r.TargetURI)))
+}
+
+// Target returns the file targeted by rel, whose source is the part called source or "/" for the package.
+// It returns nil if rel is external or if its target is not listed in Files.
+func (r *Reader) Target(source string, rel *Relationship) *File {
+	name := SourcedRelationship{source, rel}.TargetName()
+	if name == "" {
+		return nil
+	}
+	return r.file(name)
+}
+
+// TargetsByType returns the files targeted by the relationships of the part called source,
+// or of the package if source is "/", whose type is relType.
+// The targets that are external or not listed in Files are omitted.
+func (r *Reader) TargetsByType(source, relType string) []*File {
+	var files []*File
+	for _, rel := range r.relationshipsOf(source) {
+		if !strings.EqualFold(rel.Type, relType) {
+			continue
+		}
+		if f := r.Target(source, rel); f != nil {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// Reachable returns the files that can be reached following the relationships
+// from the package root, in breadth-first order.
+func (r *Reader) Reachable() []*File {
+	files := r.fileIndex()
+	visited := make(map[string]bool)
+	var reached []*File
+	queue := []SourcedRelationship{}
+	for _, rel := range r.Relationships {
+		queue = append(queue, SourcedRelationship{"/", rel})
+	}
+	for len(queue) > 0 {
+		rel := queue[0]
+		queue = queue[1:]
+		name := partKey(rel.TargetName())
+		f, ok := files[name]
+		if !ok || visited[name] {
+			continue
+		}
+		visited[name] = true
+		reached = append(reached, f)
+		for _, child := range f.Relationships {
+			queue = append(queue, SourcedRelationship{f.Name, child})
+		}
+	}
+	return reached
+}
+
+// OrphanParts returns the files that cannot be reached following the relationships
+// from the package root.
+func (r *Reader) OrphanParts() []*File {
+	reached := make(map[*File]bool)
+	for _, f := range r.Reachable() {
+		reached[f] = true
+	}
+	var orphans []*File
+	for _, f := range r.Files {
+		if !reached[f] {
+			orphans = append(orphans, f)
+		}
+	}
+	return orphans
+}
+
+// IncomingRelationships returns the relationships of the package and of the files
+// that target the part called name.
+func (r *Reader) IncomingRelationships(name string) []SourcedRelationship {
+	var incoming []SourcedRelationship
+	for _, rel := range r.allRelationships() {
+		if PartName(rel.TargetName()).Equal(PartName(name)) {
+			incoming = append(incoming, rel)
+		}
+	}
+	return incoming
+}
+
+// DanglingRelationships returns the internal relationships of the package and of its parts,
+// including the ones not listed in Files, whose target is not a part of the package.
+func (r *Reader) DanglingRelationships() []SourcedRelationship {
+	var dangling []SourcedRelationship
+	for _, rel := range r.loadedRelationships() {
+		if validateRelationshipTargets([]SourcedRelationship{rel}, r.partExists) != nil {
+			dangling = append(dangling, rel)
+		}
+	}
+	return dangling
+}
+
+// partExists reports whether the package has a part called name,
+// including the parts that are not listed in Files.
+func (r *Reader) partExists(name string) bool {
+	if r.p.partExists(name) {
+		return true
+	}
+	for _, pn := range r.propParts {
+		if PartName(pn).Equal(PartName(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// allRelationships returns the package relationships followed by the relationships of each file.
+func (r *Reader) allRelationships() []SourcedRelationship {
+	rels := make([]SourcedRelationship, 0, len(r.Relationships))
+	for _, rel := range r.Relationships {
+		rels = append(rels, SourcedRelationship{"/", rel})
+	}
+	for _, f := range r.Files {
+		for _, rel := range f.Relationships {
+			rels = append(rels, SourcedRelationship{f.Name, rel})
+		}
+	}
+	return rels
+}
+
+// loadedRelationships returns the relationships returned by allRelationships
+// followed by the relationships of the parts not listed in Files.
+func (r *Reader) loadedRelationships() []SourcedRelationship {
+	return append(r.allRelationships(), r.hiddenRels...)
+}
+
+// relationshipsOf returns the relationships of the part called source, or of the package if source is "/".
+func (r *Reader) relationshipsOf(source string) []*Relationship {
+	if source == "/" {
+		return r.Relationships
+	}
+	if f := r.file(source); f != nil {
+		return f.Relationships
+	}
+	return nil
+}
+
+// file returns the file called name or an equivalent one.
+func (r *Reader) file(name string) *File {
+	for _, f := range r.Files {
+		if PartName(f.Name).Equal(PartName(name)) {
+			return f
+		}
+	}
+	return nil
+}
+
+// fileIndex returns the files indexed by the key of their name, see partKey.
+func (r *Reader) fileIndex() map[string]*File {
+	files := make(map[string]*File, len(r.Files))
+	for _, f := range r.Files {
+		files[partKey(f.Name)] = f
+	}
+	return files
+}
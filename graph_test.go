@@ -0,0 +1,150 @@
+package opc
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func newGraphTestPackage(t *testing.T) []byte {
+	t.Helper()
+	rels := func(s string) string {
+		return `<?xml version="1.0" encoding="UTF-8"?><Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + s + `</Relationships>`
+	}
+	return newTestZip(t,
+		zipItem{"[Content_Types].xml", new(cTypeBuilder).withDefault("application/xml", "xml").withDefault(relationshipContentType, "rels").
+			withDefault("image/png", "png").withOverride(corePropsContentType, "/docProps/core.xml").String()},
+		zipItem{"_rels/.rels", rels(`<Relationship Id="rId1" Type="http://a.com/doc" Target="docs/a.xml"/>` +
+			`<Relationship Id="rId2" Type="` + corePropsRel + `" Target="docProps/core.xml"/>`)},
+		zipItem{"docProps/core.xml", `<?xml version="1.0" encoding="UTF-8"?><cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties"></cp:coreProperties>`},
+		zipItem{"docs/a.xml", "<a/>"},
+		zipItem{"docs/_rels/a.xml.rels", rels(`<Relationship Id="rId1" Type="http://a.com/styles" Target="styles.xml#normal"/>` +
+			`<Relationship Id="rId2" Type="http://a.com/image" Target="../media/image.png"/>` +
+			`<Relationship Id="rId3" Type="http://a.com/image" Target="../media/missing.png"/>` +
+			`<Relationship Id="rId4" Type="http://a.com/link" Target="http://a.com" TargetMode="External"/>`)},
+		zipItem{"docs/styles.xml", "<styles/>"},
+		zipItem{"docs/_rels/styles.xml.rels", rels(`<Relationship Id="rId1" Type="http://a.com/doc" Target="a.xml"/>`)},
+		zipItem{"media/image.png", "png"},
+		zipItem{"orphan.xml", "<orphan/>"},
+	)
+}
+
+func newGraphTestReader(t *testing.T) *Reader {
+	t.Helper()
+	b := newGraphTestPackage(t)
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	return r
+}
+
+func fileNames(files []*File) []string {
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	return names
+}
+
+func relationshipIDs(rels []SourcedRelationship) []string {
+	var ids []string
+	for _, rel := range rels {
+		ids = append(ids, rel.Source+"#"+rel.ID)
+	}
+	return ids
+}
+
+func TestReader_Target(t *testing.T) {
+	r := newGraphTestReader(t)
+	doc := r.file("/docs/a.xml")
+	tests := []struct {
+		name   string
+		source string
+		rel    *Relationship
+		want   string
+	}{
+		{"package", "/", r.Relationships[0], "/docs/a.xml"},
+		{"relative", doc.Name, doc.Relationships[1], "/media/image.png"},
+		{"equivalent", doc.Name, &Relationship{ID: "rId9", Type: "http://a.com/image", TargetURI: "../MEDIA/%69mage.PNG"}, "/media/image.png"},
+		{"missing", doc.Name, doc.Relationships[2], ""},
+		{"external", doc.Name, doc.Relationships[3], ""},
+		{"props", "/", r.Relationships[1], ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if f := r.Target(tt.source, tt.rel); f != nil {
+				got = f.Name
+			}
+			if got != tt.want {
+				t.Errorf("Reader.Target() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_TargetsByType(t *testing.T) {
+	r := newGraphTestReader(t)
+	tests := []struct {
+		name    string
+		source  string
+		relType string
+		want    []string
+	}{
+		{"package", "/", "http://a.com/doc", []string{"/docs/a.xml"}},
+		{"part", "/DOCS/A.XML", "http://a.com/image", []string{"/media/image.png"}},
+		{"external", "/docs/a.xml", "http://a.com/link", nil},
+		{"noSource", "/b.xml", "http://a.com/image", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := fileNames(r.TargetsByType(tt.source, tt.relType)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.TargetsByType() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_Reachable(t *testing.T) {
+	r := newGraphTestReader(t)
+	want := []string{"/docs/a.xml", "/docs/styles.xml", "/media/image.png"}
+	if got := fileNames(r.Reachable()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.Reachable() = %v, want %v", got, want)
+	}
+	want = []string{"/orphan.xml"}
+	if got := fileNames(r.OrphanParts()); !reflect.DeepEqual(got, want) {
+		t.Errorf("Reader.OrphanParts() = %v, want %v", got, want)
+	}
+}
+
+func TestReader_IncomingRelationships(t *testing.T) {
+	r := newGraphTestReader(t)
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"/docs/a.xml", []string{"/#rId1", "/docs/styles.xml#rId1"}},
+		{"/MEDIA/image.png", []string{"/docs/a.xml#rId2"}},
+		{"/orphan.xml", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := relationshipIDs(r.IncomingRelationships(tt.name)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.IncomingRelationships() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReader_DanglingRelationships(t *testing.T) {
+	r := newGraphTestReader(t)
+	want := []string{"/docs/a.xml#rId3"}
+	got := r.DanglingRelationships()
+	if ids := relationshipIDs(got); !reflect.DeepEqual(ids, want) {
+		t.Fatalf("Reader.DanglingRelationships() = %v, want %v", ids, want)
+	}
+	if name := got[0].TargetName(); name != "/media/missing.png" {
+		t.Errorf("SourcedRelationship.TargetName() = %v, want %v", name, "/media/missing.png")
+	}
+}
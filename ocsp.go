@@ -0,0 +1,179 @@
+package opc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+var (
+	oidOCSPBasic       = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 48, 1, 1}
+	oidExtKeyUsageOCSP = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 9}
+)
+
+var signatureAlgorithmOIDs = map[x509.SignatureAlgorithm]asn1.ObjectIdentifier{
+	x509.SHA1WithRSA:     {1, 2, 840, 113549, 1, 1, 5},
+	x509.SHA256WithRSA:   {1, 2, 840, 113549, 1, 1, 11},
+	x509.SHA384WithRSA:   {1, 2, 840, 113549, 1, 1, 12},
+	x509.SHA512WithRSA:   {1, 2, 840, 113549, 1, 1, 13},
+	x509.ECDSAWithSHA1:   {1, 2, 840, 10045, 4, 1},
+	x509.ECDSAWithSHA256: {1, 2, 840, 10045, 4, 3, 2},
+	x509.ECDSAWithSHA384: {1, 2, 840, 10045, 4, 3, 3},
+	x509.ECDSAWithSHA512: {1, 2, 840, 10045, 4, 3, 4},
+}
+
+func signatureAlgorithmByOID(oid asn1.ObjectIdentifier) x509.SignatureAlgorithm {
+	for alg, o := range signatureAlgorithmOIDs {
+		if o.Equal(oid) {
+			return alg
+		}
+	}
+	return x509.UnknownSignatureAlgorithm
+}
+
+// ASN.1 structures defined in RFC 6960.
+
+type ocspResponse struct {
+	Status        asn1.Enumerated
+	ResponseBytes ocspResponseBytes `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseBytes struct {
+	ResponseType asn1.ObjectIdentifier
+	Response     []byte
+}
+
+type basicOCSPResponse struct {
+	TBSResponseData    asn1.RawValue
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          asn1.BitString
+	Certificates       []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+}
+
+type ocspResponseData struct {
+	Version     int `asn1:"optional,explicit,default:0,tag:0"`
+	ResponderID asn1.RawValue
+	ProducedAt  time.Time `asn1:"generalized"`
+	Responses   []ocspSingleResponse
+	Extensions  []pkix.Extension `asn1:"optional,explicit,tag:1"`
+}
+
+type ocspCertID struct {
+	HashAlgorithm  pkix.AlgorithmIdentifier
+	IssuerNameHash []byte
+	IssuerKeyHash  []byte
+	SerialNumber   *big.Int
+}
+
+type ocspSingleResponse struct {
+	CertID     ocspCertID
+	Good       asn1.Flag        `asn1:"tag:0,optional"`
+	Revoked    ocspRevokedInfo  `asn1:"tag:1,optional"`
+	Unknown    asn1.Flag        `asn1:"tag:2,optional"`
+	ThisUpdate time.Time        `asn1:"generalized"`
+	NextUpdate time.Time        `asn1:"generalized,explicit,tag:0,optional"`
+	Extensions []pkix.Extension `asn1:"explicit,tag:1,optional"`
+}
+
+type ocspRevokedInfo struct {
+	RevocationTime time.Time       `asn1:"generalized"`
+	Reason         asn1.Enumerated `asn1:"explicit,tag:0,optional"`
+}
+
+// basicOCSP is a successful OCSP response.
+type basicOCSP struct {
+	basicOCSPResponse
+	ocspResponseData
+	certs []*x509.Certificate
+}
+
+func parseOCSPResponse(der []byte) (*basicOCSP, error) {
+	var resp ocspResponse
+	if rest, err := asn1.Unmarshal(der, &resp); err != nil || len(rest) > 0 {
+		return nil, errors.New("opc: malformed OCSP response")
+	}
+	if resp.Status != 0 {
+		return nil, fmt.Errorf("opc: OCSP response status %d", resp.Status)
+	}
+	if !resp.ResponseBytes.ResponseType.Equal(oidOCSPBasic) {
+		return nil, errors.New("opc: unsupported OCSP response type")
+	}
+	b := new(basicOCSP)
+	if _, err := asn1.Unmarshal(resp.ResponseBytes.Response, &b.basicOCSPResponse); err != nil {
+		return nil, fmt.Errorf("opc: malformed basic OCSP response: %v", err)
+	}
+	if _, err := asn1.Unmarshal(b.TBSResponseData.FullBytes, &b.ocspResponseData); err != nil {
+		return nil, fmt.Errorf("opc: malformed OCSP response data: %v", err)
+	}
+	for _, raw := range b.Certificates {
+		cert, err := x509.ParseCertificate(raw.FullBytes)
+		if err != nil {
+			return nil, fmt.Errorf("opc: malformed OCSP responder certificate: %v", err)
+		}
+		b.certs = append(b.certs, cert)
+	}
+	return b, nil
+}
+
+// find returns the single response about cert, which is issued by issuer.
+func (b *basicOCSP) find(cert, issuer *x509.Certificate) *ocspSingleResponse {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(issuer.RawSubjectPublicKeyInfo, &spki); err != nil {
+		return nil
+	}
+	for i, r := range b.Responses {
+		hash, ok := hashByOID(r.CertID.HashAlgorithm.Algorithm)
+		if !ok || r.CertID.SerialNumber == nil || r.CertID.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+			continue
+		}
+		if bytes.Equal(r.CertID.IssuerNameHash, digest(hash, issuer.RawSubject)) &&
+			bytes.Equal(r.CertID.IssuerKeyHash, digest(hash, spki.PublicKey.RightAlign())) {
+			return &b.Responses[i]
+		}
+	}
+	return nil
+}
+
+// verify checks that the response is signed by issuer or by a responder certificate
+// to which issuer has delegated the OCSP signing.
+func (b *basicOCSP) verify(issuer *x509.Certificate) error {
+	alg := signatureAlgorithmByOID(b.SignatureAlgorithm.Algorithm)
+	if alg == x509.UnknownSignatureAlgorithm {
+		return errors.New("opc: unsupported OCSP signature algorithm")
+	}
+	tbs, sig := b.TBSResponseData.FullBytes, b.Signature.RightAlign()
+	if issuer.CheckSignature(alg, tbs, sig) == nil {
+		return nil
+	}
+	for _, c := range b.certs {
+		if c.CheckSignatureFrom(issuer) != nil || !hasOCSPSigning(c) {
+			continue
+		}
+		if c.CheckSignature(alg, tbs, sig) == nil {
+			return nil
+		}
+	}
+	return errors.New("opc: the OCSP response is not signed by the certificate issuer or a delegated responder")
+}
+
+func hasOCSPSigning(cert *x509.Certificate) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == x509.ExtKeyUsageOCSPSigning {
+			return true
+		}
+	}
+	for _, u := range cert.UnknownExtKeyUsage {
+		if u.Equal(oidExtKeyUsageOCSP) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package opc
+
+import (
+	"path"
+	"strconv"
+	"strings"
+)
+
+const (
+	officeSigNamespace = "http://schemas.microsoft.com/office/2006/digsig"
+	officeObjectID     = "idOfficeObject"
+	officeV1DetailsID  = "idOfficeV1Details"
+)
+
+// officeSignedRelationshipTypes contains the relationship types, without their namespace,
+// whose relationships and targets are signed by Microsoft Office.
+// Document properties, thumbnails and signatures are not signed so they can be updated.
+var officeSignedRelationshipTypes = map[string]bool{
+	"activeXControl": true, "aFChunk": true, "attachedTemplate": true, "attachedToolbars": true,
+	"audio": true, "calcChain": true, "chart": true, "chartColorStyle": true, "chartLayout": true,
+	"chartsheet": true, "chartStyle": true, "chartUserShapes": true, "commentAuthors": true,
+	"comments": true, "connections": true, "connectorXml": true, "control": true, "ctrlProp": true,
+	"customData": true, "customProperty": true, "customXml": true, "diagram": true,
+	"diagramColors": true, "diagramColorsHeader": true, "diagramData": true, "diagramDrawing": true,
+	"diagramLayout": true, "diagramLayoutHeader": true, "diagramQuickStyle": true,
+	"diagramQuickStyleHeader": true, "dialogsheet": true, "dictionary": true, "documentParts": true,
+	"downRev": true, "drawing": true, "endnotes": true, "externalLink": true, "externalLinkPath": true,
+	"font": true, "fontTable": true, "footer": true, "footnotes": true, "functionPrototypes": true,
+	"glossaryDocument": true, "graphicFrameDoc": true, "groupShapeXml": true, "handoutMaster": true,
+	"hdphoto": true, "header": true, "hyperlink": true, "image": true, "ink": true, "inkXml": true,
+	"keyMapCustomizations": true, "legacyDiagramText": true, "legacyDocTextInfo": true,
+	"mailMergeHeaderSource": true, "mailMergeRecipientData": true, "mailMergeSource": true,
+	"media": true, "notesMaster": true, "notesSlide": true, "numbering": true, "officeDocument": true,
+	"oleObject": true, "package": true, "pictureXml": true, "pivotCacheDefinition": true,
+	"pivotCacheRecords": true, "pivotTable": true, "presProps": true, "printerSettings": true,
+	"queryTable": true, "recipientData": true, "settings": true, "shapeXml": true,
+	"sharedStrings": true, "sheetMetadata": true, "slicer": true, "slicerCache": true, "slide": true,
+	"slideLayout": true, "slideMaster": true, "slideUpdateInfo": true, "slideUpdateUrl": true,
+	"smartTags": true, "styles": true, "stylesWithEffects": true, "table": true,
+	"tableSingleCells": true, "tableStyles": true, "tags": true, "theme": true, "themeOverride": true,
+	"timeline": true, "timelineCache": true, "transform": true, "ui/altText": true,
+	"ui/buttonSize": true, "ui/controlID": true, "ui/description": true, "ui/enabled": true,
+	"ui/extensibility": true, "ui/helperText": true, "ui/imageID": true, "ui/imageMso": true,
+	"ui/keyTip": true, "ui/label": true, "ui/lcid": true, "ui/loud": true, "ui/pressed": true,
+	"ui/progID": true, "ui/ribbonID": true, "ui/showImage": true, "ui/showLabel": true,
+	"ui/supertip": true, "ui/target": true, "ui/text": true, "ui/title": true, "ui/tooltip": true,
+	"ui/userCustomization": true, "ui/visible": true, "userXmlData": true, "vbaProject": true,
+	"video": true, "viewProps": true, "vmlDrawing": true, "volatileDependencies": true,
+	"webSettings": true, "wordVbaData": true, "worksheet": true, "wsSortMap": true,
+	"xlBinaryIndex": true, "xlExternalLinkPath/xlAlternateStartup": true,
+	"xlExternalLinkPath/xlLibrary": true, "xlExternalLinkPath/xlPathMissing": true,
+	"xlExternalLinkPath/xlStartup": true, "xlIntlMacrosheet": true, "xlMacrosheet": true,
+	"xmlMaps": true,
+}
+
+// OfficeOptions defines the details stored in the SignatureInfoV1 element
+// of a signature created with the layout expected by Microsoft Office.
+// Zero values are replaced by the ones of a common Office installation.
+type OfficeOptions struct {
+	Comments             string // The purpose for signing the document.
+	WindowsVersion       string // Version of Windows where the signature is created. If empty "10.0" is used.
+	OfficeVersion        string // Version of Office where the signature is created. If empty "16.0" is used.
+	ApplicationVersion   string // Version of the application where the signature is created. If empty "16.0" is used.
+	Monitors             int    // Number of monitors of the signing system. If zero 1 is used.
+	HorizontalResolution int    // Horizontal resolution of the primary monitor. If zero 1920 is used.
+	VerticalResolution   int    // Vertical resolution of the primary monitor. If zero 1080 is used.
+	ColorDepth           int    // Color depth of the primary monitor. If zero 32 is used.
+}
+
+func (o OfficeOptions) withDefaults() *OfficeOptions {
+	if o.WindowsVersion == "" {
+		o.WindowsVersion = "10.0"
+	}
+	if o.OfficeVersion == "" {
+		o.OfficeVersion = "16.0"
+	}
+	if o.ApplicationVersion == "" {
+		o.ApplicationVersion = "16.0"
+	}
+	if o.Monitors == 0 {
+		o.Monitors = 1
+	}
+	if o.HorizontalResolution == 0 {
+		o.HorizontalResolution = 1920
+	}
+	if o.VerticalResolution == 0 {
+		o.VerticalResolution = 1080
+	}
+	if o.ColorDepth == 0 {
+		o.ColorDepth = 32
+	}
+	return &o
+}
+
+// newOfficeObjectNode creates the Object that holds the SignatureInfoV1 element
+// defined in [MS-OFFCRYPTO] §2.5.2.
+func newOfficeObjectNode(o *OfficeOptions) *xmlNode {
+	return newXMLNode("Object", "Id", officeObjectID).add(
+		newXMLNode("SignatureProperties").add(
+			newXMLNode("SignatureProperty", "Id", officeV1DetailsID, "Target", "#"+packageSignatureID).add(
+				newXMLNode("SignatureInfoV1", "xmlns", officeSigNamespace).add(
+					newXMLNode("SetupID"),
+					newXMLNode("SignatureText"),
+					newXMLNode("SignatureImage"),
+					newXMLTextNode("SignatureComments", o.Comments),
+					newXMLTextNode("WindowsVersion", o.WindowsVersion),
+					newXMLTextNode("OfficeVersion", o.OfficeVersion),
+					newXMLTextNode("ApplicationVersion", o.ApplicationVersion),
+					newXMLTextNode("Monitors", strconv.Itoa(o.Monitors)),
+					newXMLTextNode("HorizontalResolution", strconv.Itoa(o.HorizontalResolution)),
+					newXMLTextNode("VerticalResolution", strconv.Itoa(o.VerticalResolution)),
+					newXMLTextNode("ColorDepth", strconv.Itoa(o.ColorDepth)),
+					newXMLTextNode("SignatureProviderId", "{00000000-0000-0000-0000-000000000000}"),
+					newXMLNode("SignatureProviderUrl"),
+					newXMLTextNode("SignatureProviderDetails", "9"),
+					newXMLTextNode("SignatureType", "1"),
+				),
+			),
+		),
+	)
+}
+
+// isOfficeSignedRelationship reports whether relationships of type relType are signed by Microsoft Office.
+func isOfficeSignedRelationship(relType string) bool {
+	name := relType
+	if i := strings.LastIndex(relType, "/relationships/"); i >= 0 {
+		name = relType[i+len("/relationships/"):]
+	}
+	return officeSignedRelationshipTypes[name]
+}
+
+// officeReferences returns the parts and relationships signed by Microsoft Office,
+// which are the internal relationships of the signed types and their targets.
+func (w *Writer) officeReferences() ([]string, []SignedRelationships) {
+	var (
+		parts []string
+		rels  []SignedRelationships
+	)
+	seen := make(map[string]struct{})
+	for _, d := range w.digests {
+		if d.rels == nil {
+			continue
+		}
+		var ids []string
+		for _, r := range d.rels {
+			if r.TargetMode != ModeInternal || !isOfficeSignedRelationship(r.Type) {
+				continue
+			}
+			ids = append(ids, r.ID)
+			name := path.Clean(ResolveRelationship(d.source, r.TargetURI))
+			if _, ok := seen[partKey(name)]; !ok {
+				seen[partKey(name)] = struct{}{}
+				parts = append(parts, name)
+			}
+		}
+		if len(ids) > 0 {
+			rels = append(rels, SignedRelationships{Source: d.source, IDs: ids})
+		}
+	}
+	return parts, rels
+}
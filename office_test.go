@@ -0,0 +1,96 @@
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/x509"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestWriter_Sign_Office(t *testing.T) {
+	r, err := OpenReader("testdata/office.docx")
+	if err != nil {
+		t.Fatalf("failed to open test file: %v", err)
+	}
+	defer r.Close()
+	var buf bytes.Buffer
+	w, err := NewWriterFromReader(&buf, r.Reader)
+	if err != nil {
+		t.Fatalf("NewWriterFromReader() error = %v", err)
+	}
+	key := newTestRSAKey(t)
+	if err = w.Sign(key, []*x509.Certificate{newTestCertificate(t, key)}, &SignatureOptions{Office: &OfficeOptions{Comments: "approved"}}); err != nil {
+		t.Fatalf("Writer.Sign() error = %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+
+	signed, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	s := signed.Signatures[0]
+	if !s.Valid() {
+		t.Fatalf("Signature.Valid() error = %v", s.Err)
+	}
+	if s.XAdES == nil {
+		t.Error("Signature.XAdES = nil")
+	}
+	want := []string{
+		"/_rels/.rels", "/word/_rels/document.xml.rels", "/word/document.xml", "/word/fontTable.xml",
+		"/word/settings.xml", "/word/styles.xml", "/word/theme/theme1.xml", "/word/webSettings.xml",
+	}
+	got := append([]string(nil), s.Covered...)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Signature.Covered = %v, want %v", got, want)
+	}
+	wantUncovered := []string{"/docProps/app.xml", "/docProps/core.xml"}
+	gotUncovered := append([]string(nil), s.Uncovered...)
+	sort.Strings(gotUncovered)
+	if !reflect.DeepEqual(gotUncovered, wantUncovered) {
+		t.Errorf("Signature.Uncovered = %v, want %v", gotUncovered, wantUncovered)
+	}
+
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	doc := string(readZipFile(t, zr, "_xmlsignatures/sig1.xml"))
+	for _, s := range []string{
+		`<Reference Type="http://www.w3.org/2000/09/xmldsig#Object" URI="#idOfficeObject">`,
+		`<Object Id="idOfficeObject">`,
+		`<SignatureInfoV1 xmlns="http://schemas.microsoft.com/office/2006/digsig">`,
+		`<SignatureComments>approved</SignatureComments>`,
+		`<WindowsVersion>10.0</WindowsVersion><OfficeVersion>16.0</OfficeVersion>`,
+		`<xd:SigningCertificate>`,
+		`<xd:SignaturePolicyImplied></xd:SignaturePolicyImplied>`,
+	} {
+		if !strings.Contains(doc, s) {
+			t.Errorf("Writer.Sign() signature does not contain %s", s)
+		}
+	}
+}
+
+func Test_isOfficeSignedRelationship(t *testing.T) {
+	tests := []struct {
+		relType string
+		want    bool
+	}{
+		{"http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument", true},
+		{"http://purl.oclc.org/ooxml/officeDocument/relationships/officeDocument", true},
+		{"http://schemas.microsoft.com/office/2006/relationships/ui/extensibility", true},
+		{"http://schemas.openxmlformats.org/officeDocument/2006/relationships/extended-properties", false},
+		{corePropsRel, false},
+		{signatureOriginRel, false},
+		{"styles", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.relType, func(t *testing.T) {
+			if got := isOfficeSignedRelationship(tt.relType); got != tt.want {
+				t.Errorf("isOfficeSignedRelationship() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
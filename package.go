@@ -8,13 +8,15 @@
 package opc
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
-	"mime"
+	"io/ioutil"
 	"path"
 	"sort"
 	"strings"
+	"time"
 )
 
 const (
@@ -26,40 +28,122 @@ const (
 	packageRelName          = "/_rels/.rels"
 )
 
+const (
+	corePropsNamespace = "http://schemas.openxmlformats.org/package/2006/metadata/core-properties"
+	dcNamespace        = "http://purl.org/dc/elements/1.1/"
+	dctermsNamespace   = "http://purl.org/dc/terms/"
+	xsiNamespace       = "http://www.w3.org/2001/XMLSchema-instance"
+	mcNamespace        = "http://schemas.openxmlformats.org/markup-compatibility/2006"
+)
+
 type pkg struct {
-	parts        map[string]struct{}
+	parts        map[string]packagePart // uppercase part name:part
 	contentTypes contentTypes
 }
 
+// packagePart is the name and the content type of a part,
+// used to arrange the content types stream when the package is written.
+type packagePart struct {
+	name        string
+	contentType string
+}
+
 func newPackage() *pkg {
 	return &pkg{
-		parts: make(map[string]struct{}, 0),
+		parts: make(map[string]packagePart, 0),
 	}
 }
 
-func (p *pkg) partExists(partName string) bool {
-	_, ok := p.parts[partName]
+func (p *pkg) partExists(name string) bool {
+	_, ok := p.parts[partKey(name)]
 	return ok
 }
 
 func (p *pkg) add(part *Part) error {
+	if err := p.validatePart(part); err != nil {
+		return err
+	}
+	name := partKey(part.Name)
+	p.contentTypes.add(name, part.ContentType)
+	p.parts[name] = packagePart{NormalizePartName(part.Name), normalizeContentType(part.ContentType)}
+	return nil
+}
+
+// validatePart checks that part is valid and can be added to p.
+func (p *pkg) validatePart(part *Part) error {
 	if err := part.validate(); err != nil {
 		return err
 	}
-	name := strings.ToUpper(NormalizePartName(part.Name))
+	name := partKey(part.Name)
 	if p.partExists(name) {
 		return newError(112, part.Name)
 	}
 	if p.checkPrefixCollision(name) {
 		return newError(111, part.Name)
 	}
-	p.contentTypes.add(name, part.ContentType)
-	p.parts[name] = struct{}{}
 	return nil
 }
 
+func (p *pkg) clone() *pkg {
+	c := &pkg{parts: make(map[string]packagePart, len(p.parts))}
+	for name, part := range p.parts {
+		c.parts[name] = part
+	}
+	for e, ct := range p.contentTypes.defaults {
+		c.contentTypes.addDefault(e, ct)
+	}
+	for pn, ct := range p.contentTypes.overrides {
+		c.contentTypes.addOverride(pn, ct)
+	}
+	return c
+}
+
+// arrangeContentTypes returns the content types of the parts of p arranged with strategy s.
+// The automatic strategy returns the content types as they have been added.
+func (p *pkg) arrangeContentTypes(s ContentTypeStrategy) *contentTypes {
+	switch s {
+	case ContentTypeOverridesOnly:
+		ct := new(contentTypes)
+		ct.ensureOverridesMap()
+		for name, part := range p.parts {
+			ct.addOverride(name, part.contentType)
+		}
+		return ct
+	case ContentTypeOptimal:
+		counts := make(map[string]map[string]int) // extension:contenttype:parts
+		for name, part := range p.parts {
+			if ext := partExtension(name); ext != "" {
+				if counts[ext] == nil {
+					counts[ext] = make(map[string]int)
+				}
+				counts[ext][part.contentType]++
+			}
+		}
+		ct := new(contentTypes)
+		ct.ensureDefaultsMap()
+		ct.ensureOverridesMap()
+		for ext, types := range counts {
+			var best string
+			for t, n := range types {
+				// Break the ties by content type so the result does not depend on the map order.
+				if n > types[best] || (n == types[best] && t < best) {
+					best = t
+				}
+			}
+			ct.addDefault(ext, best)
+		}
+		for name, part := range p.parts {
+			if t, ok := ct.defaults[partExtension(name)]; !ok || t != part.contentType {
+				ct.addOverride(name, part.contentType)
+			}
+		}
+		return ct
+	}
+	return &p.contentTypes
+}
+
 func (p *pkg) deletePart(uri string) {
-	delete(p.parts, strings.ToUpper(uri))
+	delete(p.parts, partKey(uri))
 }
 
 func (p *pkg) checkPrefixCollision(uri string) bool {
@@ -85,11 +169,11 @@ func (p *pkg) checkPrefixCollision(uri string) bool {
 	return false
 }
 
-func (p *pkg) encodeContentTypes(w io.Writer) error {
+func (p *pkg) encodeContentTypes(w io.Writer, s ContentTypeStrategy) error {
 	w.Write(([]byte)(xml.Header))
 	enc := xml.NewEncoder(w)
 	enc.Indent("", "    ")
-	return enc.Encode(p.contentTypes.toXML())
+	return enc.Encode(p.arrangeContentTypes(s).toXML())
 }
 
 func (p *pkg) checkStringsPrefixCollision(s1, s2 string) bool {
@@ -114,6 +198,28 @@ type overrideContentTypeXML struct {
 	ContentType string   `xml:"ContentType,attr"`
 }
 
+// ContentTypeStrategy is an enumerable for the ways of mapping the part content types
+// to the Default and Override elements of the content types stream.
+type ContentTypeStrategy int
+
+const (
+	// ContentTypeAutomatic uses the content type of the first part with a given extension as the Default
+	// of that extension and an Override for the following parts with a different content type.
+	ContentTypeAutomatic ContentTypeStrategy = iota
+	// ContentTypeOverridesOnly uses an Override for every part.
+	ContentTypeOverridesOnly
+	// ContentTypeOptimal uses the most common content type of each extension as its Default,
+	// so the number of Overrides is minimal.
+	ContentTypeOptimal
+)
+
+// ContentTypes is a view of the Default and Override elements of the content types stream,
+// as defined in ISO/IEC 29500-2 §10.1.2.2.
+type ContentTypes struct {
+	Defaults  map[string]string // The content types by lowercase extension, which does not include the dot.
+	Overrides map[string]string // The content types by part name.
+}
+
 type contentTypes struct {
 	defaults  map[string]string // extension:contenttype
 	overrides map[string]string // partname:contenttype
@@ -121,19 +227,25 @@ type contentTypes struct {
 
 func (c *contentTypes) toXML() *contentTypesXML {
 	cx := &contentTypesXML{XML: "http://schemas.openxmlformats.org/package/2006/content-types"}
-	if c.defaults != nil {
-		for e, ct := range c.defaults {
-			cx.Types = append(cx.Types, &defaultContentTypeXML{Extension: e, ContentType: ct})
-		}
+	// Sort the entries so the same content types are always encoded the same way.
+	for _, e := range sortedKeys(c.defaults) {
+		cx.Types = append(cx.Types, &defaultContentTypeXML{Extension: e, ContentType: c.defaults[e]})
 	}
-	if c.overrides != nil {
-		for pn, ct := range c.overrides {
-			cx.Types = append(cx.Types, &overrideContentTypeXML{PartName: pn, ContentType: ct})
-		}
+	for _, pn := range sortedKeys(c.overrides) {
+		cx.Types = append(cx.Types, &overrideContentTypeXML{PartName: pn, ContentType: c.overrides[pn]})
 	}
 	return cx
 }
 
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 func (c *contentTypes) ensureDefaultsMap() {
 	if c.defaults == nil {
 		c.defaults = make(map[string]string, 0)
@@ -146,18 +258,25 @@ func (c *contentTypes) ensureOverridesMap() {
 	}
 }
 
+// partExtension returns the lowercase extension of partName without the dot.
+func partExtension(partName string) string {
+	ext := strings.ToLower(path.Ext(partName))
+	if len(ext) == 0 {
+		return ""
+	}
+	return ext[1:]
+}
+
 // Add needs a valid content type, else the behavior is undefined
 func (c *contentTypes) add(partName, contentType string) error {
 	// Process descrived in ISO/IEC 29500-2 §10.1.2.3
-	t, params, _ := mime.ParseMediaType(contentType)
-	contentType = mime.FormatMediaType(t, params)
+	contentType = normalizeContentType(contentType)
 
-	ext := strings.ToLower(path.Ext(partName))
+	ext := partExtension(partName)
 	if len(ext) == 0 {
 		c.addOverride(partName, contentType)
 		return nil
 	}
-	ext = ext[1:] // remove dot
 	c.ensureDefaultsMap()
 	currentType, ok := c.defaults[ext]
 	if ok {
@@ -183,8 +302,23 @@ func (c *contentTypes) addDefault(extension, contentType string) {
 	c.defaults[extension] = contentType
 }
 
+// view returns the public view of c, using the part names of p when they are known.
+func (c *contentTypes) view(p *pkg) ContentTypes {
+	v := ContentTypes{Defaults: make(map[string]string, len(c.defaults)), Overrides: make(map[string]string, len(c.overrides))}
+	for e, ct := range c.defaults {
+		v.Defaults[e] = ct
+	}
+	for pn, ct := range c.overrides {
+		if part, ok := p.parts[pn]; ok {
+			pn = part.name
+		}
+		v.Overrides[pn] = ct
+	}
+	return v
+}
+
 func (c *contentTypes) findType(name string) (string, error) {
-	if t, ok := c.overrides[strings.ToUpper(name)]; ok {
+	if t, ok := c.overrides[partKey(name)]; ok {
 		return t, nil
 	}
 	ext := path.Ext(name)
@@ -197,48 +331,31 @@ func (c *contentTypes) findType(name string) (string, error) {
 }
 
 type corePropertiesXMLMarshal struct {
-	XMLName        xml.Name    `xml:"coreProperties"`
-	XML            string      `xml:"xmlns,attr"`
-	XMLDCTERMS     string      `xml:"xmlns:dcterms,attr"`
-	XMLDC          string      `xml:"xmlns:dc,attr"`
-	XMLXSI         string      `xml:"xmlns:xsi,attr"`
-	Category       string      `xml:"category,omitempty"`
-	ContentStatus  string      `xml:"contentStatus,omitempty"`
-	Created        w3CDateTime `xml:"dcterms:created,omitempty"`
-	Creator        string      `xml:"dc:creator,omitempty"`
-	Description    string      `xml:"dc:description,omitempty"`
-	Identifier     string      `xml:"dc:identifier,omitempty"`
-	Keywords       string      `xml:"keywords,omitempty"`
-	Language       string      `xml:"dc:language,omitempty"`
-	LastModifiedBy string      `xml:"lastModifiedBy,omitempty"`
-	LastPrinted    w3CDateTime `xml:"lastPrinted,omitempty"`
-	Modified       w3CDateTime `xml:"dcterms:modified,omitempty"`
-	Revision       string      `xml:"revision,omitempty"`
-	Subject        string      `xml:"dc:subject,omitempty"`
-	Title          string      `xml:"dc:title,omitempty"`
-	Version        string      `xml:"version,omitempty"`
-}
-
-type corePropertiesXMLUnmarshal struct {
-	XMLName        xml.Name `xml:"coreProperties"`
-	XML            string   `xml:"xmlns,attr"`
-	XMLDCTERMS     string   `xml:"dcterms,attr"`
-	XMLDC          string   `xml:"dc,attr"`
-	Category       string   `xml:"category,omitempty"`
-	ContentStatus  string   `xml:"contentStatus,omitempty"`
-	Created        string   `xml:"created,omitempty"`
-	Creator        string   `xml:"creator,omitempty"`
-	Description    string   `xml:"description,omitempty"`
-	Identifier     string   `xml:"identifier,omitempty"`
-	Keywords       string   `xml:"keywords,omitempty"`
-	Language       string   `xml:"language,omitempty"`
-	LastModifiedBy string   `xml:"lastModifiedBy,omitempty"`
-	LastPrinted    string   `xml:"lastPrinted,omitempty"`
-	Modified       string   `xml:"modified,omitempty"`
-	Revision       string   `xml:"revision,omitempty"`
-	Subject        string   `xml:"subject,omitempty"`
-	Title          string   `xml:"title,omitempty"`
-	Version        string   `xml:"version,omitempty"`
+	XMLName        xml.Name     `xml:"coreProperties"`
+	XML            string       `xml:"xmlns,attr"`
+	XMLDCTERMS     string       `xml:"xmlns:dcterms,attr"`
+	XMLDC          string       `xml:"xmlns:dc,attr"`
+	XMLXSI         string       `xml:"xmlns:xsi,attr"`
+	Category       string       `xml:"category,omitempty"`
+	ContentStatus  string       `xml:"contentStatus,omitempty"`
+	Created        w3CDateTime  `xml:"dcterms:created,omitempty"`
+	Creator        string       `xml:"dc:creator,omitempty"`
+	Description    string       `xml:"dc:description,omitempty"`
+	Identifier     string       `xml:"dc:identifier,omitempty"`
+	Keywords       *keywordsXML `xml:"keywords,omitempty"`
+	Language       string       `xml:"dc:language,omitempty"`
+	LastModifiedBy string       `xml:"lastModifiedBy,omitempty"`
+	LastPrinted    string       `xml:"lastPrinted,omitempty"`
+	Modified       w3CDateTime  `xml:"dcterms:modified,omitempty"`
+	Revision       string       `xml:"revision,omitempty"`
+	Subject        string       `xml:"dc:subject,omitempty"`
+	Title          string       `xml:"dc:title,omitempty"`
+	Version        string       `xml:"version,omitempty"`
+}
+
+// keywordsXML holds the mixed content of the keywords, which cannot be indented.
+type keywordsXML struct {
+	Content string `xml:",innerxml"`
 }
 
 type w3CDateTime string
@@ -252,63 +369,542 @@ func (s w3CDateTime) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 }
 
 // CoreProperties enable users to get and set well-known and common sets of property metadata within packages.
+// When a package is copied with NewWriterFromReader, its core properties part is written as it was read,
+// including the content not represented by CoreProperties, with only the updated values replaced.
 type CoreProperties struct {
 	PartName       string // Won't be written to the package, only used to indicate the location of the CoreProperties part. If empty the default location is "/props/core.xml".
 	RelationshipID string // Won't be written to the package, only used to indicate the relationship ID for target "/props/core.xml".
 	Category       string // A categorization of the content of this package.
 	ContentStatus  string // The status of the content.
-	Created        string // Date of creation of the resource.
+	Created        string // Date of creation of the resource, formatted as a W3CDTF date. See CreatedTime and SetCreatedTime.
 	Creator        string // An entity primarily responsible for making the content of the resource.
 	Description    string // An explanation of the content of the resource.
 	Identifier     string // An unambiguous reference to the resource within a given context.
-	Keywords       string // A delimited set of keywords to support searching and indexing.
+	Keywords       string // A delimited set of keywords to support searching and indexing. See KeywordValues for the keywords in a given language.
 	Language       string // The language of the intellectual content of the resource.
 	LastModifiedBy string // The user who performed the last modification.
-	LastPrinted    string // The date and time of the last printing.
-	Modified       string // Date on which the resource was changed.
+	LastPrinted    string // The date and time of the last printing, formatted as a W3CDTF date. See LastPrintedTime and SetLastPrintedTime.
+	Modified       string // Date on which the resource was changed, formatted as a W3CDTF date. See ModifiedTime and SetModifiedTime.
 	Revision       string // The revision number.
 	Subject        string // The topic of the content of the resource.
 	Title          string // The name given to the resource.
 	Version        string // The version number.
+	extra          *corePropertiesExtra
+}
+
+// corePropertiesExtra holds the core properties that cannot be stored in a comparable field.
+// It is never modified once created, so copies of a CoreProperties can share it.
+type corePropertiesExtra struct {
+	keywordValues []Keyword
+}
+
+// Keyword is a set of keywords in a given language.
+type Keyword struct {
+	Lang  string // The language of the value, as defined in RFC 3066. Can be empty.
+	Value string // A delimited set of keywords.
+}
+
+// KeywordValues returns the keywords stored in a value element per language,
+// which complement the Keywords stored as plain text.
+func (c *CoreProperties) KeywordValues() []Keyword {
+	if c.extra == nil {
+		return nil
+	}
+	return append([]Keyword(nil), c.extra.keywordValues...)
+}
+
+// SetKeywordValues sets the keywords stored in a value element per language.
+func (c *CoreProperties) SetKeywordValues(values ...Keyword) {
+	if len(values) == 0 {
+		c.extra = nil
+		return
+	}
+	c.extra = &corePropertiesExtra{append([]Keyword(nil), values...)}
+}
+
+// decodeKeywordValues returns the value elements of the keywords element e.
+func decodeKeywordValues(e *xmlElement) []Keyword {
+	var values []Keyword
+	for _, v := range e.elements(corePropsNamespace, "value") {
+		k := Keyword{Value: v.text()}
+		for _, a := range v.Attr {
+			if a.Name.Space == "xml" && a.Name.Local == "lang" {
+				k.Lang = a.Value
+			}
+		}
+		values = append(values, k)
+	}
+	return values
+}
+
+// propertyText returns the value of the property element e.
+// The whitespace that indents the value elements of the keywords is not part of its value.
+func propertyText(e *xmlElement) string {
+	text := e.text()
+	if len(e.elements(corePropsNamespace, "value")) > 0 && strings.TrimSpace(text) == "" {
+		return ""
+	}
+	return text
+}
+
+// setKeywordValues replaces the content of the keywords element e with text and values.
+func setKeywordValues(e *xmlElement, text string, values []Keyword) {
+	e.Children = []xml.Token{xml.CharData(text)}
+	for _, k := range values {
+		v := &xmlElement{Name: xml.Name{Space: e.Name.Space, Local: "value"}, parent: e}
+		if k.Lang != "" {
+			v.Attr = []xml.Attr{{Name: xml.Name{Space: "xml", Local: "lang"}, Value: k.Lang}}
+		}
+		v.Children = []xml.Token{xml.CharData(k.Value)}
+		e.Children = append(e.Children, v)
+	}
+}
+
+func keywordsEqual(a, b []Keyword) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// coreProperty is a core property element and the CoreProperties field that holds its value.
+type coreProperty struct {
+	space, local string
+	value        *string
+}
+
+func (c *CoreProperties) properties() []coreProperty {
+	return []coreProperty{
+		{corePropsNamespace, "category", &c.Category},
+		{corePropsNamespace, "contentStatus", &c.ContentStatus},
+		{dctermsNamespace, "created", &c.Created},
+		{dcNamespace, "creator", &c.Creator},
+		{dcNamespace, "description", &c.Description},
+		{dcNamespace, "identifier", &c.Identifier},
+		{corePropsNamespace, "keywords", &c.Keywords},
+		{dcNamespace, "language", &c.Language},
+		{corePropsNamespace, "lastModifiedBy", &c.LastModifiedBy},
+		{corePropsNamespace, "lastPrinted", &c.LastPrinted},
+		{dctermsNamespace, "modified", &c.Modified},
+		{corePropsNamespace, "revision", &c.Revision},
+		{dcNamespace, "subject", &c.Subject},
+		{dcNamespace, "title", &c.Title},
+		{corePropsNamespace, "version", &c.Version},
+	}
+}
+
+// findProperty returns the index of the property held by e, or -1 if e is not a known property.
+func findProperty(props []coreProperty, e *xmlElement) int {
+	for i, p := range props {
+		if e.is(p.space, p.local) {
+			return i
+		}
+	}
+	return -1
+}
+
+// CreatedTime returns the Created date, or the zero time if it is empty.
+func (c *CoreProperties) CreatedTime() (time.Time, error) {
+	return parseW3CDTF(c.Created)
+}
+
+// SetCreatedTime sets the Created date formatted with precision p.
+func (c *CoreProperties) SetCreatedTime(t time.Time, p W3CDTFPrecision) {
+	c.Created = FormatW3CDTF(t, p)
+}
+
+// ModifiedTime returns the Modified date, or the zero time if it is empty.
+func (c *CoreProperties) ModifiedTime() (time.Time, error) {
+	return parseW3CDTF(c.Modified)
+}
+
+// SetModifiedTime sets the Modified date formatted with precision p.
+func (c *CoreProperties) SetModifiedTime(t time.Time, p W3CDTFPrecision) {
+	c.Modified = FormatW3CDTF(t, p)
+}
+
+// LastPrintedTime returns the LastPrinted date, or the zero time if it is empty.
+func (c *CoreProperties) LastPrintedTime() (time.Time, error) {
+	return parseW3CDTF(c.LastPrinted)
+}
+
+// SetLastPrintedTime sets the LastPrinted date formatted with precision p.
+func (c *CoreProperties) SetLastPrintedTime(t time.Time, p W3CDTFPrecision) {
+	c.LastPrinted = FormatW3CDTF(t, p)
+}
+
+// partName returns the absolute name of the core properties part.
+func (c *CoreProperties) partName() string {
+	if c.PartName == "" {
+		return corePropsDefaultName
+	}
+	return ResolveRelationship("/", c.PartName)
+}
+
+// validateDates checks that the date properties are empty or W3CDTF dates.
+func (c *CoreProperties) validateDates() error {
+	for _, value := range []string{c.Created, c.LastPrinted, c.Modified} {
+		if _, err := parseW3CDTF(value); err != nil {
+			return newError(406, c.partName())
+		}
+	}
+	return nil
 }
 
 func (c *CoreProperties) encode(w io.Writer) error {
-	w.Write(([]byte)(xml.Header))
-	enc := xml.NewEncoder(w)
+	if err := c.validateDates(); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	b.Write(([]byte)(xml.Header))
+	var keywords *keywordsXML
+	if c.Keywords != "" || c.extra != nil {
+		var kb strings.Builder
+		xml.EscapeText(&kb, []byte(c.Keywords))
+		for _, k := range c.KeywordValues() {
+			kb.WriteString("<value")
+			if k.Lang != "" {
+				kb.WriteString(` xml:lang="`)
+				xml.EscapeText(&kb, []byte(k.Lang))
+				kb.WriteString(`"`)
+			}
+			kb.WriteString(">")
+			xml.EscapeText(&kb, []byte(k.Value))
+			kb.WriteString("</value>")
+		}
+		keywords = &keywordsXML{kb.String()}
+	}
+	enc := xml.NewEncoder(&b)
 	enc.Indent("", "    ")
-	return enc.Encode(&corePropertiesXMLMarshal{
+	err := enc.Encode(&corePropertiesXMLMarshal{
 		xml.Name{Local: "coreProperties"},
-		"http://schemas.openxmlformats.org/package/2006/metadata/core-properties",
-		"http://purl.org/dc/terms/",
-		"http://purl.org/dc/elements/1.1/",
-		"http://www.w3.org/2001/XMLSchema-instance",
+		corePropsNamespace,
+		dctermsNamespace,
+		dcNamespace,
+		xsiNamespace,
 		c.Category, c.ContentStatus, w3CDateTime(c.Created),
 		c.Creator, c.Description, c.Identifier,
-		c.Keywords, c.Language, c.LastModifiedBy,
-		w3CDateTime(c.LastPrinted), w3CDateTime(c.Modified), c.Revision,
+		keywords, c.Language, c.LastModifiedBy,
+		c.LastPrinted, w3CDateTime(c.Modified), c.Revision,
 		c.Subject, c.Title, c.Version,
 	})
+	if err != nil {
+		return err
+	}
+	if err = validateCoreProperties(b.Bytes(), c.partName()); err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// encodeDocument writes doc, a core properties part read from a package, updated with the values of c.
+// The part is written byte for byte as it was read except for the properties whose value has changed:
+// their content is replaced, they are removed if they are now empty,
+// and the new ones are appended to the root element.
+func (c *CoreProperties) encodeDocument(w io.Writer, doc []byte) error {
+	if err := c.validateDates(); err != nil {
+		return err
+	}
+	tree, err := parseXMLTree(bytes.NewReader(doc))
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be encoded: %v", c.partName(), err)
+	}
+	rootSpan, spans, err := scanXMLElements(doc)
+	root := tree.root()
+	if err != nil || root == nil || len(root.childElements()) != len(spans) {
+		return fmt.Errorf("opc: %s: cannot be encoded: the part cannot be scanned", c.partName())
+	}
+	props := c.properties()
+	elems := root.childElements()
+	present := make([]bool, len(props))
+	for _, e := range elems {
+		if j := findProperty(props, e); j >= 0 {
+			present[j] = true
+		}
+	}
+	hasValue := func(p coreProperty) bool {
+		return *p.value != "" || (p.value == &c.Keywords && c.extra != nil)
+	}
+	// The new properties are created first, as they may declare namespaces in the root element.
+	ndecl := len(root.Attr)
+	var added bytes.Buffer
+	indent := leadingSpace(doc, spans, rootSpan)
+	for i, p := range props {
+		if present[i] || !hasValue(p) {
+			continue
+		}
+		e := newCorePropertyElement(root, p)
+		if p.value == &c.Keywords {
+			setKeywordValues(e, c.Keywords, c.KeywordValues())
+		}
+		added.Write(indent)
+		writeXMLElement(&added, e)
+	}
+
+	var b bytes.Buffer
+	var pos int
+	copyTo := func(off int) {
+		b.Write(doc[pos:off])
+		pos = off
+	}
+	declEnd := rootSpan.contentStart - 1
+	if rootSpan.selfClosing() {
+		declEnd--
+	}
+	copyTo(declEnd)
+	for _, a := range root.Attr[ndecl:] {
+		b.WriteByte(' ')
+		writeXMLAttr(&b, a)
+	}
+	written := make([]bool, len(props))
+	for i, e := range elems {
+		j := findProperty(props, e)
+		if j < 0 || written[j] {
+			continue
+		}
+		written[j] = true
+		p, s := props[j], spans[i]
+		isKeywords := p.value == &c.Keywords
+		if *p.value == propertyText(e) && (!isKeywords || keywordsEqual(decodeKeywordValues(e), c.KeywordValues())) {
+			continue
+		}
+		if !hasValue(p) {
+			// Remove the element along with its indentation.
+			start := s.start
+			for start > pos && isXMLSpace(doc[start-1]) {
+				start--
+			}
+			copyTo(start)
+			pos = s.end
+			continue
+		}
+		content := &xmlElement{Name: e.Name}
+		content.Children = []xml.Token{xml.CharData(*p.value)}
+		if isKeywords {
+			setKeywordValues(content, c.Keywords, c.KeywordValues())
+		}
+		if s.selfClosing() {
+			copyTo(s.start)
+			b.Write(bytes.TrimRight(bytes.TrimSuffix(doc[s.start:s.contentStart], []byte("/>")), " \t\r\n"))
+			b.WriteByte('>')
+			writeXMLContent(&b, content)
+			b.WriteString("</" + qualifiedName(e.Name) + ">")
+		} else {
+			copyTo(s.contentStart)
+			writeXMLContent(&b, content)
+		}
+		pos = s.contentEnd
+		if s.selfClosing() {
+			pos = s.end
+		}
+	}
+	if added.Len() > 0 {
+		if rootSpan.selfClosing() {
+			copyTo(rootSpan.contentStart - 2)
+			b.WriteByte('>')
+			b.Write(added.Bytes())
+			b.WriteString("</" + qualifiedName(root.Name) + ">")
+			pos = rootSpan.end
+		} else {
+			end := rootSpan.contentEnd
+			for end > pos && isXMLSpace(doc[end-1]) {
+				end--
+			}
+			copyTo(end)
+			b.Write(added.Bytes())
+		}
+	}
+	copyTo(len(doc))
+	if err := validateCoreProperties(b.Bytes(), c.partName()); err != nil {
+		return err
+	}
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// leadingSpace returns the whitespace that indents the first child element of the root,
+// which is used to indent the new ones.
+func leadingSpace(doc []byte, spans []xmlSpan, root xmlSpan) []byte {
+	if len(spans) == 0 {
+		return nil
+	}
+	start := spans[0].start
+	for start > root.contentStart && isXMLSpace(doc[start-1]) {
+		start--
+	}
+	return doc[start:spans[0].start]
+}
+
+// newCorePropertyElement returns a child element of root holding the property p.
+func newCorePropertyElement(root *xmlElement, p coreProperty) *xmlElement {
+	e := &xmlElement{Name: xml.Name{Local: p.local}, parent: root}
+	switch p.space {
+	case corePropsNamespace:
+		e.Name.Space = namespacePrefix(root, p.space, "cp", true)
+	case dcNamespace:
+		e.Name.Space = namespacePrefix(root, p.space, "dc", true)
+	case dctermsNamespace:
+		// ISO/IEC 29500-2 M4.5
+		e.Name.Space = namespacePrefix(root, p.space, "dcterms", false)
+		xsi := namespacePrefix(root, xsiNamespace, "xsi", false)
+		e.Attr = []xml.Attr{{Name: xml.Name{Space: xsi, Local: "type"}, Value: e.Name.Space + ":W3CDTF"}}
+	}
+	e.Children = []xml.Token{xml.CharData(*p.value)}
+	return e
+}
+
+// namespacePrefix returns a prefix bound to the namespace space in the scope of root,
+// declaring it in root if there is none. The default namespace is only used if allowDefault is true.
+func namespacePrefix(root *xmlElement, space, name string, allowDefault bool) string {
+	ns := root.inScopeNamespaces()
+	var prefixes []string
+	for prefix, uri := range ns {
+		if uri == space && (prefix != "" || allowDefault) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) > 0 {
+		sort.Strings(prefixes)
+		return prefixes[0]
+	}
+	prefix := name
+	for i := 1; ns[prefix] != ""; i++ {
+		prefix = fmt.Sprintf("%s%d", name, i)
+	}
+	root.Attr = append(root.Attr, xml.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: space})
+	return prefix
 }
 
 func decodeCoreProperties(r io.Reader, props *CoreProperties) error {
-	propDecode := new(corePropertiesXMLUnmarshal)
-	if err := xml.NewDecoder(r).Decode(propDecode); err != nil {
-		return fmt.Errorf("opc: %s: cannot be decoded: %v", contentTypesName, err)
-	}
-	props.Category = propDecode.Category
-	props.ContentStatus = propDecode.ContentStatus
-	props.Created = propDecode.Created
-	props.Creator = propDecode.Creator
-	props.Description = propDecode.Description
-	props.Identifier = propDecode.Identifier
-	props.Keywords = propDecode.Keywords
-	props.Language = propDecode.Language
-	props.LastModifiedBy = propDecode.LastModifiedBy
-	props.LastPrinted = propDecode.LastPrinted
-	props.Modified = propDecode.Modified
-	props.Revision = propDecode.Revision
-	props.Subject = propDecode.Subject
-	props.Title = propDecode.Title
-	props.Version = propDecode.Version
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be read: %v", props.partName(), err)
+	}
+	if err := validateCoreProperties(b, props.partName()); err != nil {
+		return err
+	}
+	doc, err := parseXMLTree(bytes.NewReader(b))
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be decoded: %v", props.partName(), err)
+	}
+	root := doc.root()
+	if root == nil || !root.is(corePropsNamespace, "coreProperties") {
+		return fmt.Errorf("opc: %s: cannot be decoded: expected a core properties document", props.partName())
+	}
+	fields := props.properties()
+	found := make([]bool, len(fields))
+	for _, e := range root.Children {
+		if e, ok := e.(*xmlElement); ok {
+			if i := findProperty(fields, e); i >= 0 && !found[i] {
+				*fields[i].value = propertyText(e)
+				found[i] = true
+				if fields[i].value == &props.Keywords {
+					props.SetKeywordValues(decodeKeywordValues(e)...)
+				}
+			}
+		}
+	}
+	return props.validateDates()
+}
+
+// validateCoreProperties checks the core properties part content b
+// against the requirements of ISO/IEC 29500-2 §11.
+func validateCoreProperties(b []byte, partName string) error {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	var (
+		scopes []map[string]string // The namespace prefixes declared by each open element.
+		names  []xml.Name          // The open elements.
+	)
+	lookup := func(prefix string) string {
+		for i := len(scopes) - 1; i >= 0; i-- {
+			if ns, ok := scopes[i][prefix]; ok {
+				return ns
+			}
+		}
+		return ""
+	}
+	for {
+		t, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("opc: %s: cannot be decoded: %v", partName, err)
+		}
+		switch t := t.(type) {
+		case xml.StartElement:
+			scope := make(map[string]string)
+			for _, a := range t.Attr {
+				if a.Name.Space == "xmlns" {
+					scope[a.Name.Local] = a.Value
+				} else if a.Name.Space == "" && a.Name.Local == "xmlns" {
+					scope[""] = a.Value
+				}
+			}
+			scopes = append(scopes, scope)
+			if err := validateCorePropertiesElement(t, names, lookup, partName); err != nil {
+				return err
+			}
+			names = append(names, t.Name)
+		case xml.EndElement:
+			scopes, names = scopes[:len(scopes)-1], names[:len(names)-1]
+		}
+	}
+}
+
+func validateCorePropertiesElement(e xml.StartElement, parents []xml.Name, lookup func(string) string, partName string) error {
+	// ISO/IEC 29500-2 M4.2
+	if e.Name.Space == mcNamespace {
+		return newError(402, partName)
+	}
+	isDate := e.Name.Space == dctermsNamespace && (e.Name.Local == "created" || e.Name.Local == "modified")
+	// ISO/IEC 29500-2 M4.3
+	if e.Name.Space == dctermsNamespace && !isDate {
+		return newError(403, partName)
+	}
+	var hasType bool
+	for _, a := range e.Attr {
+		switch {
+		case a.Name.Space == mcNamespace || ((a.Name.Space == "xmlns" || a.Name.Local == "xmlns") && a.Value == mcNamespace):
+			return newError(402, partName)
+		case a.Name.Space == xmlNamespace && a.Name.Local == "lang":
+			// ISO/IEC 29500-2 M4.4
+			if !langAllowed(e.Name, parents) {
+				return newError(404, partName)
+			}
+		case a.Name.Space == xsiNamespace && a.Name.Local == "type":
+			// ISO/IEC 29500-2 M4.5
+			i := strings.Index(a.Value, ":")
+			if !isDate || i < 0 || a.Value[i+1:] != "W3CDTF" || lookup(a.Value[:i]) != dctermsNamespace {
+				return newError(405, partName)
+			}
+			hasType = true
+		}
+	}
+	if isDate && !hasType {
+		return newError(405, partName)
+	}
 	return nil
 }
+
+// langAllowed reports whether the xml:lang attribute can be used in the element called name.
+// It is only allowed in the Dublin Core elements and in the keywords, which can hold a value per language.
+func langAllowed(name xml.Name, parents []xml.Name) bool {
+	if len(parents) == 0 {
+		return false
+	}
+	if name.Space == dcNamespace {
+		return true
+	}
+	if name.Space != corePropsNamespace {
+		return false
+	}
+	parent := parents[len(parents)-1]
+	return name.Local == "keywords" || (name.Local == "value" && parent.Space == corePropsNamespace && parent.Local == "keywords")
+}
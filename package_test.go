@@ -8,9 +8,9 @@ import (
 )
 
 func createFakePackage(m ...string) *pkg {
-	parts := make(map[string]struct{}, len(m))
+	parts := make(map[string]packagePart, len(m))
 	for _, s := range m {
-		parts[strings.ToUpper(s)] = struct{}{}
+		parts[strings.ToUpper(s)] = packagePart{name: s}
 	}
 	return &pkg{
 		parts: parts,
@@ -23,7 +23,7 @@ func Test_newPackage(t *testing.T) {
 		want *pkg
 	}{
 		{"base", &pkg{
-			parts: make(map[string]struct{}, 0),
+			parts: make(map[string]packagePart, 0),
 		}},
 	}
 	for _, tt := range tests {
@@ -104,23 +104,31 @@ func TestCoreProperties_encode(t *testing.T) {
 		wantErr bool
 	}{
 		{"empty", &CoreProperties{}, buildCoreString(""), false},
-		{"some", &CoreProperties{Category: "A", LastPrinted: "b"}, buildCoreString(`
+		{"some", &CoreProperties{Category: "A", LastPrinted: "2019-01"}, buildCoreString(`
     <category>A</category>
-    <lastPrinted xsi:type="dcterms:W3CDTF">b</lastPrinted>
+    <lastPrinted>2019-01</lastPrinted>
 `), false},
-		{"all", &CoreProperties{"partName", "rId1", "a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o"},
+		{"invalidDate", &CoreProperties{Category: "A", LastPrinted: "b"}, "", true},
+		{"keywordValues", func() *CoreProperties {
+			c := &CoreProperties{Keywords: "a"}
+			c.SetKeywordValues(Keyword{"en-US", "b"}, Keyword{"", "c"})
+			return c
+		}(), buildCoreString(`
+    <keywords>a<value xml:lang="en-US">b</value><value>c</value></keywords>
+`), false},
+		{"all", &CoreProperties{"partName", "rId1", "a", "b", "2015", "d", "e", "f", "g", "h", "i", "2016-03-04T05:06Z", "2017-03-04T05:06:07.5+01:00", "l", "m", "n", "o", nil},
 			buildCoreString(`
     <category>a</category>
     <contentStatus>b</contentStatus>
-    <dcterms:created xsi:type="dcterms:W3CDTF">c</dcterms:created>
+    <dcterms:created xsi:type="dcterms:W3CDTF">2015</dcterms:created>
     <dc:creator>d</dc:creator>
     <dc:description>e</dc:description>
     <dc:identifier>f</dc:identifier>
     <keywords>g</keywords>
     <dc:language>h</dc:language>
     <lastModifiedBy>i</lastModifiedBy>
-    <lastPrinted xsi:type="dcterms:W3CDTF">j</lastPrinted>
-    <dcterms:modified xsi:type="dcterms:W3CDTF">k</dcterms:modified>
+    <lastPrinted>2016-03-04T05:06Z</lastPrinted>
+    <dcterms:modified xsi:type="dcterms:W3CDTF">2017-03-04T05:06:07.5+01:00</dcterms:modified>
     <revision>l</revision>
     <dc:subject>m</dc:subject>
     <dc:title>n</dc:title>
@@ -141,3 +149,206 @@ func TestCoreProperties_encode(t *testing.T) {
 		})
 	}
 }
+
+func TestCoreProperties_encodeDocument(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties b="1" a="2" xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:x="urn:x">
+  <dc:title xml:lang="en">a</dc:title>
+  <!-- comment -->
+  <x:custom x:a="1">b</x:custom>
+  <cp:category/>
+  <x:foo/>
+  <cp:keywords>c &amp; d</cp:keywords>
+</cp:coreProperties>`
+	tests := []struct {
+		name   string
+		update func(*CoreProperties)
+		want   string
+	}{
+		{"unchanged", func(*CoreProperties) {}, content},
+		{"updated", func(c *CoreProperties) {
+			c.Title = "d<"
+			c.Keywords = ""
+		}, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties b="1" a="2" xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:x="urn:x">
+  <dc:title xml:lang="en">d&lt;</dc:title>
+  <!-- comment -->
+  <x:custom x:a="1">b</x:custom>
+  <cp:category/>
+  <x:foo/>
+</cp:coreProperties>`},
+		{"emptyUpdated", func(c *CoreProperties) {
+			c.Category = "e"
+		}, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties b="1" a="2" xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:x="urn:x">
+  <dc:title xml:lang="en">a</dc:title>
+  <!-- comment -->
+  <x:custom x:a="1">b</x:custom>
+  <cp:category>e</cp:category>
+  <x:foo/>
+  <cp:keywords>c &amp; d</cp:keywords>
+</cp:coreProperties>`},
+		{"added", func(c *CoreProperties) {
+			c.Creator = "e"
+			c.Modified = "2020"
+		}, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<cp:coreProperties b="1" a="2" xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:x="urn:x"` +
+			` xmlns:dcterms="http://purl.org/dc/terms/" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+  <dc:title xml:lang="en">a</dc:title>
+  <!-- comment -->
+  <x:custom x:a="1">b</x:custom>
+  <cp:category/>
+  <x:foo/>
+  <cp:keywords>c &amp; d</cp:keywords>
+  <dc:creator>e</dc:creator>
+  <dcterms:modified xsi:type="dcterms:W3CDTF">2020</dcterms:modified>
+</cp:coreProperties>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c CoreProperties
+			if err := decodeCoreProperties(strings.NewReader(content), &c); err != nil {
+				t.Fatalf("decodeCoreProperties() error = %v", err)
+			}
+			tt.update(&c)
+			w := new(bytes.Buffer)
+			if err := c.encodeDocument(w, []byte(content)); err != nil {
+				t.Fatalf("CoreProperties.encodeDocument() error = %v", err)
+			}
+			if got := w.String(); got != tt.want {
+				t.Errorf("CoreProperties.encodeDocument() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoreProperties_KeywordValues(t *testing.T) {
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties">
+  <cp:keywords xml:lang="en-US">
+    <cp:value xml:lang="en-GB">colour</cp:value>
+    <cp:value>color</cp:value>
+  </cp:keywords>
+</cp:coreProperties>`
+	var c CoreProperties
+	if err := decodeCoreProperties(strings.NewReader(content), &c); err != nil {
+		t.Fatalf("decodeCoreProperties() error = %v", err)
+	}
+	want := []Keyword{{"en-GB", "colour"}, {"", "color"}}
+	if got := c.KeywordValues(); c.Keywords != "" || !reflect.DeepEqual(got, want) {
+		t.Errorf("CoreProperties.KeywordValues() = (%q, %v), want %v", c.Keywords, got, want)
+	}
+	cp := c
+	cp.KeywordValues()[0].Value = "x"
+	if cp != c || !reflect.DeepEqual(c.KeywordValues(), want) {
+		t.Errorf("CoreProperties.KeywordValues() = %v, want %v", c.KeywordValues(), want)
+	}
+	w := new(bytes.Buffer)
+	c.encodeDocument(w, []byte(content))
+	if got := w.String(); got != content {
+		t.Errorf("CoreProperties.encodeDocument() = %v, want it unchanged", got)
+	}
+
+	c.SetKeywordValues(Keyword{"es-ES", "color"})
+	c.Keywords = "paint"
+	w.Reset()
+	if err := c.encodeDocument(w, []byte(content)); err != nil {
+		t.Fatalf("CoreProperties.encodeDocument() error = %v", err)
+	}
+	wantXML := `<cp:keywords xml:lang="en-US">paint<cp:value xml:lang="es-ES">color</cp:value></cp:keywords>`
+	if got := w.String(); !strings.Contains(got, wantXML) {
+		t.Errorf("CoreProperties.encodeDocument() = %v, want it to contain %v", got, wantXML)
+	}
+
+	c.SetKeywordValues()
+	c.Keywords = ""
+	w.Reset()
+	c.encodeDocument(w, []byte(content))
+	if got := w.String(); strings.Contains(got, "keywords") || c.KeywordValues() != nil {
+		t.Errorf("CoreProperties.encodeDocument() = %v, want no keywords", got)
+	}
+}
+
+func Test_decodeCoreProperties(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    CoreProperties
+		wantErr bool
+	}{
+		{"dates", `<dcterms:created xsi:type="dcterms:W3CDTF">2015-06</dcterms:created><dcterms:modified xsi:type="dcterms:W3CDTF">2019-01-24T19:58:26.5Z</dcterms:modified>`,
+			CoreProperties{Created: "2015-06", Modified: "2019-01-24T19:58:26.5Z"}, false},
+		{"invalidCreated", `<dcterms:created xsi:type="dcterms:W3CDTF">2015/06/05</dcterms:created>`, CoreProperties{}, true},
+		{"invalidModified", `<dcterms:modified xsi:type="dcterms:W3CDTF">2019-01-24T19:58:26</dcterms:modified>`, CoreProperties{}, true},
+		{"invalidLastPrinted", `<lastPrinted>24/01/2019</lastPrinted>`, CoreProperties{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got CoreProperties
+			err := decodeCoreProperties(strings.NewReader(buildCoreString(tt.content)), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decodeCoreProperties() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeCoreProperties() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_validateCoreProperties(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		wantCode int
+	}{
+		{"empty", "", 0},
+		{"dates", `<dcterms:created xsi:type="dcterms:W3CDTF">2015</dcterms:created><dcterms:modified xsi:type="dcterms:W3CDTF">2016</dcterms:modified>`, 0},
+		{"otherPrefix", `<t:created xmlns:t="http://purl.org/dc/terms/" xsi:type="t:W3CDTF">2015</t:created>`, 0},
+		{"lang", `<dc:title xml:lang="en">a</dc:title><keywords xml:lang="en"><value xml:lang="es">b</value></keywords>`, 0},
+		{"mcNamespace", `<mc:AlternateContent xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006"/>`, 402},
+		{"mcAttribute", `<dc:title xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006" mc:Ignorable="a">a</dc:title>`, 402},
+		{"mcDeclaration", `<dc:title xmlns:mc="http://schemas.openxmlformats.org/markup-compatibility/2006">a</dc:title>`, 402},
+		{"refinement", `<dcterms:abstract>a</dcterms:abstract>`, 403},
+		{"langInCoreElement", `<category xml:lang="en">a</category>`, 404},
+		{"langInValue", `<value xml:lang="en">a</value>`, 404},
+		{"missingType", `<dcterms:created>2015</dcterms:created>`, 405},
+		{"wrongType", `<dcterms:created xsi:type="dcterms:Date">2015</dcterms:created>`, 405},
+		{"wrongTypePrefix", `<dcterms:created xsi:type="dc:W3CDTF">2015</dcterms:created>`, 405},
+		{"typeInOtherElement", `<lastPrinted xsi:type="dcterms:W3CDTF">2015</lastPrinted>`, 405},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateCoreProperties([]byte(buildCoreString(tt.content)), "/props/core.xml")
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("validateCoreProperties() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("validateCoreProperties() want error")
+			}
+			if got := err.(*Error).Code(); got != tt.wantCode {
+				t.Errorf("validateCoreProperties() error code = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+	var c CoreProperties
+	err := decodeCoreProperties(strings.NewReader(buildCoreString(`<category xml:lang="en">a</category>`)), &c)
+	if err == nil || err.(*Error).Code() != 404 {
+		t.Errorf("decodeCoreProperties() error = %v, want 404", err)
+	}
+}
+
+func TestCoreProperties_invalidDates(t *testing.T) {
+	err := decodeCoreProperties(strings.NewReader(buildCoreString(`<dcterms:modified xsi:type="dcterms:W3CDTF">yesterday</dcterms:modified>`)), new(CoreProperties))
+	if e, ok := err.(*Error); !ok || e.Code() != 406 {
+		t.Errorf("decodeCoreProperties() error = %v, want code 406", err)
+	}
+	c := CoreProperties{Modified: "yesterday"}
+	err = c.encode(new(bytes.Buffer))
+	if e, ok := err.(*Error); !ok || e.Code() != 406 {
+		t.Errorf("CoreProperties.encode() error = %v, want code 406", err)
+	}
+}
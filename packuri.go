@@ -0,0 +1,167 @@
+package opc
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+const packURIPrefix = "pack://"
+
+// PackURI is a pack URI, as defined in ISO/IEC 29500-2 Annex A,
+// which addresses a package or one of its parts.
+// Its authority holds the URI of the package, which can be another pack URI for nested packages.
+type PackURI struct {
+	Package  string // The absolute URI of the package.
+	Part     string // The name of the part, or an empty string if the pack URI addresses the package itself.
+	Fragment string // The fragment, without the leading number sign.
+}
+
+// NewPackURI returns the PackURI of the part called partName, or of the package if it is empty,
+// within the package identified by packageURI.
+func NewPackURI(packageURI, partName string) (*PackURI, error) {
+	pkgURI, fragment := split(packageURI, '#')
+	u := &PackURI{Package: pkgURI, Part: partName}
+	if fragment != "" {
+		return nil, newError(702, u.String())
+	}
+	if err := u.validate(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// ParsePackURI parses a pack URI, as defined in ISO/IEC 29500-2 Annex A.4.
+func ParsePackURI(s string) (*PackURI, error) {
+	if !isPackURI(s) {
+		return nil, newError(701, s)
+	}
+	rest, fragment := split(s[len(packURIPrefix):], '#')
+	if strings.IndexByte(rest, '?') >= 0 {
+		return nil, newError(703, s)
+	}
+	authority, partName := split(rest, '/')
+	if authority == "" {
+		return nil, newError(701, s)
+	}
+	pkgURI, err := url.PathUnescape(strings.Replace(authority, ",", "/", -1))
+	if err != nil {
+		return nil, newError(702, s)
+	}
+	if partName == "/" {
+		partName = ""
+	}
+	u := &PackURI{Package: pkgURI, Part: partName, Fragment: strings.TrimPrefix(fragment, "#")}
+	if err := u.validate(); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// String returns the pack URI composed as defined in ISO/IEC 29500-2 Annex A.3.
+// The characters of the package URI that are not ASCII are percent-encoded, converting it to a URI.
+func (u *PackURI) String() string {
+	var b strings.Builder
+	b.WriteString(packURIPrefix)
+	for i := 0; i < len(u.Package); i++ {
+		switch c := u.Package[i]; {
+		case c == '/':
+			b.WriteByte(',')
+		case c >= 0x80 || c <= ' ':
+			// The IRI is converted to a URI, whose percent signs are encoded again.
+			fmt.Fprintf(&b, "%%25%02X", c)
+		case c == '%', c == '?', c == '@', c == ':', c == ',':
+			fmt.Fprintf(&b, "%%%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	if u.Part == "" {
+		b.WriteByte('/')
+	} else {
+		b.WriteString(u.Part)
+	}
+	if u.Fragment != "" {
+		b.WriteString("#" + u.Fragment)
+	}
+	return b.String()
+}
+
+// Container returns the pack URI of the part holding the package addressed by u,
+// and false if the package is not nested in another package.
+func (u *PackURI) Container() (*PackURI, bool) {
+	if !isPackURI(u.Package) {
+		return nil, false
+	}
+	c, err := ParsePackURI(u.Package)
+	return c, err == nil
+}
+
+// Equal reports whether u and v are equivalent as defined in ISO/IEC 29500-2 Annex A.5.
+// The part names are compared without regard to case and the fragments are ignored.
+func (u *PackURI) Equal(v *PackURI) bool {
+	if !PartName(u.Part).Equal(PartName(v.Part)) {
+		return false
+	}
+	cu, nestedU := u.Container()
+	cv, nestedV := v.Container()
+	if nestedU || nestedV {
+		return nestedU && nestedV && cu.Equal(cv)
+	}
+	return packageURIEqual(u.Package, v.Package)
+}
+
+func (u *PackURI) validate() error {
+	// ISO/IEC 29500-2 A.3
+	if isPackURI(u.Package) {
+		if _, err := ParsePackURI(u.Package); err != nil {
+			return newError(702, u.String())
+		}
+	} else if pu, err := url.Parse(u.Package); err != nil || !pu.IsAbs() || pu.Fragment != "" {
+		return newError(702, u.String())
+	}
+	if u.Part != "" {
+		return validatePartName(u.Part)
+	}
+	return nil
+}
+
+// resolvePackURI resolves rel against the pack URI source.
+func resolvePackURI(source *PackURI, rel string) string {
+	if !isInternal(rel) {
+		return rel
+	}
+	base := source.Part
+	if base == "" {
+		base = "/"
+	}
+	name, fragment := split(rel, '#')
+	u := &PackURI{Package: source.Package, Fragment: strings.TrimPrefix(fragment, "#")}
+	if name == "" {
+		u.Part = source.Part
+	} else if u.Part = path.Clean(ResolveRelationship(base, name)); u.Part == "/" {
+		u.Part = ""
+	}
+	return u.String()
+}
+
+// packageURIEqual reports whether the package URIs a and b are equivalent,
+// comparing their scheme and host without regard to case.
+func packageURIEqual(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	for _, u := range []*url.URL{ua, ub} {
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		u.Fragment = ""
+	}
+	return ua.String() == ub.String()
+}
+
+func isPackURI(s string) bool {
+	return len(s) >= len(packURIPrefix) && strings.EqualFold(s[:len(packURIPrefix)], packURIPrefix)
+}
@@ -0,0 +1,150 @@
+package opc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePackURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		want     *PackURI
+		wantCode int
+	}{
+		{"package", "pack://http%3a,,www.my.com,packages.aspx%3fmy.package/", &PackURI{Package: "http://www.my.com/packages.aspx?my.package"}, 0},
+		{"packageNoSlash", "pack://http%3a,,www.my.com,my.docx", &PackURI{Package: "http://www.my.com/my.docx"}, 0},
+		{"part", "PACK://http%3a,,www.my.com,my.docx/a/b/foo.xml", &PackURI{Package: "http://www.my.com/my.docx", Part: "/a/b/foo.xml"}, 0},
+		{"fragment", "pack://file%3a,,,c%3a,my.docx/a.xml#frag", &PackURI{Package: "file:///c:/my.docx", Part: "/a.xml", Fragment: "frag"}, 0},
+		{"escaped", "pack://http%3a,,a.com,a%2cb%2520c.docx/a.xml", &PackURI{Package: "http://a.com/a,b%20c.docx", Part: "/a.xml"}, 0},
+		{"nested", "pack://pack%3a,,http%253a%2c%2ca.com%2cb.docx,c.zip/d.xml", &PackURI{Package: "pack://http%3a,,a.com,b.docx/c.zip", Part: "/d.xml"}, 0},
+		{"scheme", "http://a.com/a.xml", nil, 701},
+		{"noAuthority", "pack:///a.xml", nil, 701},
+		{"query", "pack://http%3a,,a.com,b.docx/a.xml?a=b", nil, 703},
+		{"relativePackage", "pack://a.docx/a.xml", nil, 702},
+		{"invalidEscape", "pack://http%3a,,a.com,b%zz.docx/a.xml", nil, 702},
+		{"packageFragment", "pack://http%3a,,a.com,b.docx%23c/a.xml", nil, 702},
+		{"invalidPart", "pack://http%3a,,a.com,b.docx/a/", nil, 105},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePackURI(tt.s)
+			if tt.wantCode != 0 {
+				if e, ok := err.(*Error); !ok || e.Code() != tt.wantCode {
+					t.Errorf("ParsePackURI() error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePackURI() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParsePackURI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackURI_String(t *testing.T) {
+	tests := []struct {
+		name string
+		u    *PackURI
+		want string
+	}{
+		{"package", &PackURI{Package: "http://www.my.com/packages.aspx?my.package"}, "pack://http%3a,,www.my.com,packages.aspx%3fmy.package/"},
+		{"part", &PackURI{Package: "http://www.my.com/my.docx", Part: "/a/b/foo.xml"}, "pack://http%3a,,www.my.com,my.docx/a/b/foo.xml"},
+		{"fragment", &PackURI{Package: "file:///c:/my.docx", Part: "/a.xml", Fragment: "frag"}, "pack://file%3a,,,c%3a,my.docx/a.xml#frag"},
+		{"escaped", &PackURI{Package: "http://user@a.com/a,b%20c.docx"}, "pack://http%3a,,user%40a.com,a%2cb%2520c.docx/"},
+		{"iri", &PackURI{Package: "http://a.com/é.docx"}, "pack://http%3a,,a.com,%25C3%25A9.docx/"},
+		{"nested", &PackURI{Package: "pack://http%3a,,a.com,b.docx/c.zip", Part: "/d.xml"}, "pack://pack%3a,,http%253a%2c%2ca.com%2cb.docx,c.zip/d.xml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.u.String()
+			if got != tt.want {
+				t.Errorf("PackURI.String() = %v, want %v", got, tt.want)
+			}
+			if u, err := ParsePackURI(got); err != nil || !u.Equal(tt.u) {
+				t.Errorf("ParsePackURI() = %v, %v, want %v", u, err, tt.u)
+			}
+		})
+	}
+}
+
+func TestNewPackURI(t *testing.T) {
+	tests := []struct {
+		name       string
+		packageURI string
+		partName   string
+		want       string
+		wantCode   int
+	}{
+		{"package", "http://a.com/b.docx", "", "pack://http%3a,,a.com,b.docx/", 0},
+		{"part", "http://a.com/b.docx", "/word/document.xml", "pack://http%3a,,a.com,b.docx/word/document.xml", 0},
+		{"relative", "b.docx", "/a.xml", "", 702},
+		{"fragment", "http://a.com/b.docx#c", "/a.xml", "", 702},
+		{"invalidPart", "http://a.com/b.docx", "a.xml", "", 104},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPackURI(tt.packageURI, tt.partName)
+			if tt.wantCode != 0 {
+				if e, ok := err.(*Error); !ok || e.Code() != tt.wantCode {
+					t.Errorf("NewPackURI() error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPackURI() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("NewPackURI() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPackURI_Container(t *testing.T) {
+	u := &PackURI{Package: "pack://http%3a,,a.com,b.docx/c.zip", Part: "/d.xml"}
+	got, ok := u.Container()
+	if want := (&PackURI{Package: "http://a.com/b.docx", Part: "/c.zip"}); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("PackURI.Container() = %v, %v, want %v", got, ok, want)
+	}
+	if _, ok := got.Container(); ok {
+		t.Error("PackURI.Container() = true, want false")
+	}
+}
+
+func TestPackURI_Equal(t *testing.T) {
+	tests := []struct {
+		name string
+		u, v string
+		want bool
+	}{
+		{"same", "pack://http%3a,,a.com,b.docx/a.xml", "pack://http%3a,,a.com,b.docx/a.xml", true},
+		{"partCase", "pack://http%3a,,a.com,b.docx/a.xml", "pack://http%3a,,a.com,b.docx/A.XML", true},
+		{"hostCase", "pack://http%3a,,a.com,b.docx/a.xml", "pack://HTTP%3a,,A.COM,b.docx/a.xml", true},
+		{"escapeCase", "pack://http%3a,,a.com,b.docx/a.xml", "pack://http%3A,,a.com,b.docx/a.xml", true},
+		{"fragment", "pack://http%3a,,a.com,b.docx/a.xml#a", "pack://http%3a,,a.com,b.docx/a.xml#b", true},
+		{"package", "pack://http%3a,,a.com,b.docx/", "pack://http%3a,,a.com,b.docx", true},
+		{"nested", "pack://pack%3a,,http%253a%2c%2ca.com%2cb.docx,c.zip/d.xml", "pack://pack%3a,,http%253a%2c%2cA.COM%2cb.docx,C.ZIP/D.xml", true},
+		{"pathCase", "pack://http%3a,,a.com,b.docx/a.xml", "pack://http%3a,,a.com,B.docx/a.xml", false},
+		{"part", "pack://http%3a,,a.com,b.docx/a.xml", "pack://http%3a,,a.com,b.docx/b.xml", false},
+		{"nestedNotNested", "pack://pack%3a,,http%253a%2c%2ca.com%2cb.docx,c.zip/d.xml", "pack://http%3a,,a.com,b.docx/d.xml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, err := ParsePackURI(tt.u)
+			if err != nil {
+				t.Fatalf("ParsePackURI() error = %v", err)
+			}
+			v, err := ParsePackURI(tt.v)
+			if err != nil {
+				t.Fatalf("ParsePackURI() error = %v", err)
+			}
+			if got := u.Equal(v); got != tt.want {
+				t.Errorf("PackURI.Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -32,7 +32,13 @@ func (p *Part) validate() error {
 // This method should be used in places where we have a target relationship URI and we want to get the
 // name of the part it targets with respect to the source part.
 // The source can be a valid part URI, for part relationships, or "/", for package relationships.
+// If source is a pack URI, the return value is the pack URI of the target, whose part name is cleaned.
 func ResolveRelationship(source string, rel string) string {
+	if isPackURI(source) {
+		if u, err := ParsePackURI(source); err == nil {
+			return resolvePackURI(u, rel)
+		}
+	}
 	source = strings.Replace(source, "\\", "/", -1)
 	rel = strings.Replace(rel, "\\", "/", -1)
 	if source == "/" && !strings.HasPrefix(rel, "/") {
@@ -44,6 +50,40 @@ func ResolveRelationship(source string, rel string) string {
 	return rel
 }
 
+// RelativeRelationship returns the relative URI of the target part, which may have a fragment,
+// with respect to the source part, so ResolveRelationship(source, RelativeRelationship(source, target))
+// names the target part. It is the inverse of ResolveRelationship.
+// The source can be a valid part URI, for part relationships, or "/", for package relationships.
+// The part names are normalized with NormalizePartName, and if target can't be normalized the return value is empty.
+func RelativeRelationship(source, target string) string {
+	target, fragment := split(strings.TrimSpace(target), '#')
+	target = NormalizePartName(target)
+	if target == "" {
+		return ""
+	}
+	var from []string
+	if source = NormalizePartName(source); source != "" {
+		if dir := path.Dir(source); dir != "/" {
+			from = strings.Split(strings.TrimPrefix(dir, "/"), "/")
+		}
+	}
+	to := strings.Split(strings.TrimPrefix(target, "/"), "/")
+	i := 0
+	for i < len(from) && i < len(to)-1 && strings.EqualFold(from[i], to[i]) {
+		i++
+	}
+	segments := make([]string, 0, len(from)-i+len(to)-i)
+	for range from[i:] {
+		segments = append(segments, "..")
+	}
+	segments = append(segments, to[i:]...)
+	if strings.Contains(segments[0], ":") {
+		// The first segment of a relative-path reference shall not look like a scheme.
+		segments = append([]string{"."}, segments...)
+	}
+	return strings.Join(segments, "/") + fragment
+}
+
 // NormalizePartName transforms the input name as an URI string
 // so it follows the constrains specified in the ISO/IEC 29500-2 §9.1.1.
 // This method is recommended to be used before adding a new Part to a package to avoid errors.
@@ -1,6 +1,8 @@
 package opc
 
 import (
+	"path"
+	"strings"
 	"testing"
 )
 
@@ -127,6 +129,10 @@ func TestResolveRelationship(t *testing.T) {
 		{"rel", args{"/3D/3dmodel.model", "/3D/box1.model"}, "/3D/box1.model"},
 		{"rel", args{"/3D/box3.model", "/2D/2dmodel.model"}, "/2D/2dmodel.model"},
 		{"relChild", args{"/3D/box3.model", "2D/2dmodel.model"}, "/3D/2D/2dmodel.model"},
+		{"pack", args{"pack://http%3a,,a.com,b.3mf/3D/box3.model", "../2D/2dmodel.model#frag"}, "pack://http%3a,,a.com,b.3mf/2D/2dmodel.model#frag"},
+		{"packRoot", args{"pack://http%3a,,a.com,b.3mf/", "3D/box3.model"}, "pack://http%3a,,a.com,b.3mf/3D/box3.model"},
+		{"packAbs", args{"pack://http%3a,,a.com,b.3mf/3D/box3.model", "/c.xml"}, "pack://http%3a,,a.com,b.3mf/c.xml"},
+		{"packExternal", args{"pack://http%3a,,a.com,b.3mf/3D/box3.model", "http://a.com/c.xml"}, "http://a.com/c.xml"},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -136,3 +142,47 @@ func TestResolveRelationship(t *testing.T) {
 		})
 	}
 }
+
+func TestRelativeRelationship(t *testing.T) {
+	type args struct {
+		source string
+		target string
+	}
+	tests := []struct {
+		name string
+		args args
+		want string
+	}{
+		{"package", args{"/", "/c.xml"}, "c.xml"},
+		{"packageChild", args{"/", "/3D/3dmodel.model"}, "3D/3dmodel.model"},
+		{"packageWin", args{"\\", "\\3D\\c.xml"}, "3D/c.xml"},
+		{"sibling", args{"/3D/3dmodel.model", "/3D/box1.model"}, "box1.model"},
+		{"siblingCase", args{"/3d/3dmodel.model", "/3D/box1.model"}, "box1.model"},
+		{"self", args{"/3D/3dmodel.model", "/3D/3dmodel.model"}, "3dmodel.model"},
+		{"child", args{"/3D/box3.model", "/3D/2D/2dmodel.model"}, "2D/2dmodel.model"},
+		{"parent", args{"/3D/2D/box3.model", "/c.xml"}, "../../c.xml"},
+		{"cousin", args{"/3D/box3.model", "/2D/2dmodel.model"}, "../2D/2dmodel.model"},
+		{"dirName", args{"/a/b.xml", "/a"}, "../a"},
+		{"rootSource", args{"/b.xml", "/a/c.xml"}, "a/c.xml"},
+		{"fragment", args{"/3D/box3.model", "/2D/2dmodel.model#frag"}, "../2D/2dmodel.model#frag"},
+		{"encoded", args{"/a%20b/c.xml", "/a b/d%41.xml"}, "dA.xml"},
+		{"escaped", args{"/", "/a b/c.xml"}, "a%20b/c.xml"},
+		{"colon", args{"/a.xml", "/b:c.xml"}, "./b:c.xml"},
+		{"invalid", args{"/a.xml", "/"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RelativeRelationship(tt.args.source, tt.args.target)
+			if got != tt.want {
+				t.Errorf("RelativeRelationship() = %v, want %v", got, tt.want)
+			}
+			if got == "" {
+				return
+			}
+			name, _ := split(got, '#')
+			if resolved := NormalizePartName(path.Clean(ResolveRelationship(tt.args.source, name))); !strings.EqualFold(resolved, NormalizePartName(tt.args.target)) {
+				t.Errorf("ResolveRelationship() = %v, want %v", resolved, NormalizePartName(tt.args.target))
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package opc
+
+import (
+	"path"
+	"strings"
+)
+
+// PartName is the name of a part, as defined in ISO/IEC 29500-2 §9.1.1.
+// The package itself, which is the source of the package relationships, is named "/".
+type PartName string
+
+// NewPartName returns the PartName of name normalized with NormalizePartName.
+// It returns an error if the normalized name is not a valid part name.
+func NewPartName(name string) (PartName, error) {
+	n := NormalizePartName(name)
+	if err := validatePartName(n); err != nil {
+		return "", err
+	}
+	return PartName(n), nil
+}
+
+// Equal reports whether n and m are equivalent part names, as defined in ISO/IEC 29500-2 §9.1.1.1,
+// comparing them as case-insensitive ASCII strings once their percent-encoding is normalized.
+func (n PartName) Equal(m PartName) bool {
+	return n.normalizedKey() == m.normalizedKey()
+}
+
+// Less reports whether n sorts before m.
+// The names are ordered by their equivalence, so equivalent names are sorted next to each other,
+// and then by their bytes, so the order is stable.
+func (n PartName) Less(m PartName) bool {
+	if kn, km := n.normalizedKey(), m.normalizedKey(); kn != km {
+		return kn < km
+	}
+	return n < m
+}
+
+// Segments returns the segments of n, which are separated by forward slashes.
+func (n PartName) Segments() []string {
+	name := strings.TrimPrefix(string(n), "/")
+	if name == "" {
+		return nil
+	}
+	return strings.Split(name, "/")
+}
+
+// Ext returns the extension of n, which is the suffix of its last segment
+// beginning at the final dot, or an empty string if there is no dot.
+func (n PartName) Ext() string {
+	return path.Ext(string(n))
+}
+
+// Parent returns the name of the directory holding n, which is "/" for the parts in the package root.
+func (n PartName) Parent() PartName {
+	return PartName(path.Dir(string(n)))
+}
+
+// RelationshipsPartName returns the name of the relationships part of n,
+// as defined in ISO/IEC 29500-2 §9.3.2.
+// If n is "/" or empty it returns the name of the package relationships part.
+// A relative name is resolved against the package root, as ResolveRelationship does.
+func (n PartName) RelationshipsPartName() PartName {
+	if n == "/" || n == "" {
+		return packageRelName
+	}
+	name := ResolveRelationship("/", string(n))
+	i := strings.LastIndex(name, "/")
+	return PartName(name[:i] + "/_rels/" + name[i+1:] + ".rels")
+}
+
+// SourcePartName returns the name of the part whose relationships are stored in n, which is "/"
+// for the package relationships part, and false if n is not the name of a relationships part.
+// It is the inverse of RelationshipsPartName.
+func (n PartName) SourcePartName() (PartName, bool) {
+	name := string(n)
+	if !isRelationshipURI(name) {
+		return "", false
+	}
+	if n.Equal(packageRelName) {
+		return "/", true
+	}
+	base := path.Base(name)
+	return PartName(path.Join(path.Dir(path.Dir(name)), strings.TrimSuffix(base, path.Ext(base)))), true
+}
+
+// key returns n with its ASCII letters in upper case,
+// which is the same for the equivalent names that are already normalized.
+func (n PartName) key() string {
+	b := []byte(n)
+	for i, c := range b {
+		if 'a' <= c && c <= 'z' {
+			b[i] = c - 'a' + 'A'
+		}
+	}
+	return string(b)
+}
+
+func (n PartName) normalizedKey() string {
+	return PartName(NormalizePartName(string(n))).key()
+}
+
+// partKey returns the key that indexes the part called name, which is the same
+// for all the names equivalent to it as reported by PartName.Equal.
+func partKey(name string) string {
+	return PartName(name).normalizedKey()
+}
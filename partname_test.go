@@ -0,0 +1,144 @@
+package opc
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestNewPartName(t *testing.T) {
+	tests := []struct {
+		name     string
+		want     PartName
+		wantCode int
+	}{
+		{"/a/b.xml", "/a/b.xml", 0},
+		{"a\\b.xml", "/a/b.xml", 0},
+		{"/a b/%41.xml", "/a%20b/A.xml", 0},
+		{"/a/b.", "", 109},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewPartName(tt.name)
+			if tt.wantCode != 0 {
+				if e, ok := err.(*Error); !ok || e.Code() != tt.wantCode {
+					t.Errorf("NewPartName() error = %v, want code %d", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPartName() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("NewPartName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartName_Equal(t *testing.T) {
+	tests := []struct {
+		name string
+		n, m PartName
+		want bool
+	}{
+		{"same", "/a/b.xml", "/a/b.xml", true},
+		{"case", "/a/b.xml", "/A/B.XML", true},
+		{"encoding", "/a b/c.xml", "/a%20b/c.xml", true},
+		{"unreserved", "/a/%62.xml", "/a/b.xml", true},
+		{"nonASCIICase", "/a/é.xml", "/a/É.xml", false},
+		{"different", "/a/b.xml", "/a/c.xml", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.n.Equal(tt.m); got != tt.want {
+				t.Errorf("PartName.Equal() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPartName_Less(t *testing.T) {
+	names := []PartName{"/b.xml", "/A.xml", "/a.xml", "/a/c.xml", "/B.xml"}
+	sort.Slice(names, func(i, j int) bool { return names[i].Less(names[j]) })
+	want := []PartName{"/A.xml", "/a.xml", "/a/c.xml", "/B.xml", "/b.xml"}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("PartName.Less() sorted %v, want %v", names, want)
+	}
+}
+
+func TestPartName_Segments(t *testing.T) {
+	tests := []struct {
+		name         PartName
+		wantSegments []string
+		wantExt      string
+		wantParent   PartName
+	}{
+		{"/a.xml", []string{"a.xml"}, ".xml", "/"},
+		{"/a/b/c.tar.gz", []string{"a", "b", "c.tar.gz"}, ".gz", "/a/b"},
+		{"/a/b", []string{"a", "b"}, "", "/a"},
+		{"/", nil, "", "/"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.name), func(t *testing.T) {
+			if got := tt.name.Segments(); !reflect.DeepEqual(got, tt.wantSegments) {
+				t.Errorf("PartName.Segments() = %v, want %v", got, tt.wantSegments)
+			}
+			if got := tt.name.Ext(); got != tt.wantExt {
+				t.Errorf("PartName.Ext() = %v, want %v", got, tt.wantExt)
+			}
+			if got := tt.name.Parent(); got != tt.wantParent {
+				t.Errorf("PartName.Parent() = %v, want %v", got, tt.wantParent)
+			}
+		})
+	}
+}
+
+func TestPartName_RelationshipsPartName(t *testing.T) {
+	tests := []struct {
+		name       PartName
+		want       PartName
+		wantSource PartName
+	}{
+		{"/", "/_rels/.rels", "/"},
+		{"", "/_rels/.rels", "/"},
+		{"/a.xml", "/_rels/a.xml.rels", "/a.xml"},
+		{"/a/b/c.xml", "/a/b/_rels/c.xml.rels", "/a/b/c.xml"},
+		{"/a/b", "/a/_rels/b.rels", "/a/b"},
+		{"a.xml", "/_rels/a.xml.rels", "/a.xml"},
+		{"a/b.xml", "/a/_rels/b.xml.rels", "/a/b.xml"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.name), func(t *testing.T) {
+			got := tt.name.RelationshipsPartName()
+			if got != tt.want {
+				t.Errorf("PartName.RelationshipsPartName() = %v, want %v", got, tt.want)
+			}
+			if source, ok := got.SourcePartName(); !ok || source != tt.wantSource {
+				t.Errorf("PartName.SourcePartName() = %v, %v, want %v", source, ok, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestPartName_SourcePartName(t *testing.T) {
+	tests := []struct {
+		name   PartName
+		want   PartName
+		wantOk bool
+	}{
+		{"/_RELS/.RELS", "/", true},
+		{"/a/_rels/b.xml.RELS", "/a/b.xml", true},
+		{"/a/b.xml", "", false},
+		{"/a/_rels/.rels", "", false},
+		{"/a/rels/b.xml.rels", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.name), func(t *testing.T) {
+			got, ok := tt.name.SourcePartName()
+			if got != tt.want || ok != tt.wantOk {
+				t.Errorf("PartName.SourcePartName() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOk)
+			}
+		})
+	}
+}
@@ -0,0 +1,221 @@
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// piece is a ZIP item holding a piece of an interleaved part, as defined in ISO/IEC 29500-2 §10.2.4.
+type piece struct {
+	file  archiveFile
+	index int
+	last  bool
+}
+
+// parsePieceName reports whether the last segment of the ZIP item name is a piece name.
+// If it is, it returns the name of the part and the piece index.
+// A segment enclosed in square brackets followed by the piece extension with an invalid index
+// is reported as a piece with a negative index.
+func parsePieceName(name string) (partName string, index int, last, ok bool) {
+	i := strings.LastIndex(name, "/")
+	if i <= 0 {
+		return "", 0, false, false
+	}
+	segment := strings.ToLower(name[i+1:])
+	if !strings.HasPrefix(segment, "[") || !strings.HasSuffix(segment, ".piece") {
+		return "", 0, false, false
+	}
+	segment = strings.TrimSuffix(segment, ".piece")
+	if strings.HasSuffix(segment, ".last") {
+		last = true
+		segment = strings.TrimSuffix(segment, ".last")
+	}
+	index = -1
+	if strings.HasSuffix(segment, "]") {
+		// The index is a decimal number without leading zeros.
+		digits := segment[1 : len(segment)-1]
+		if strings.Trim(digits, "0123456789") == "" && (digits == "0" || !strings.HasPrefix(digits, "0")) {
+			if n, err := strconv.Atoi(digits); err == nil {
+				index = n
+			}
+		}
+	}
+	return name[:i], index, last, true
+}
+
+// groupPieces replaces the pieces of the interleaved parts found in files
+// by a single file, located where the first piece was, that reads them in order.
+func groupPieces(files []archiveFile) ([]archiveFile, error) {
+	pieces := make(map[string][]piece)
+	grouped := make([]archiveFile, 0, len(files))
+	for _, f := range files {
+		name, index, last, ok := parsePieceName(f.Name())
+		if !ok {
+			grouped = append(grouped, f)
+			continue
+		}
+		if index < 0 {
+			return nil, newError(311, "/"+f.Name())
+		}
+		key := partKey(name)
+		if _, ok := pieces[key]; !ok {
+			grouped = append(grouped, &pieceFile{name: name})
+		}
+		pieces[key] = append(pieces[key], piece{f, index, last})
+	}
+	for _, f := range grouped {
+		pf, ok := f.(*pieceFile)
+		if !ok {
+			continue
+		}
+		ps := pieces[partKey(pf.name)]
+		sort.Slice(ps, func(i, j int) bool { return ps[i].index < ps[j].index })
+		for i, p := range ps {
+			if p.index != i {
+				return nil, newError(312, "/"+pf.name)
+			}
+			if p.last != (i == len(ps)-1) {
+				return nil, newError(313, "/"+pf.name)
+			}
+			pf.pieces = append(pf.pieces, p.file)
+		}
+	}
+	return grouped, nil
+}
+
+// pieceFile is an interleaved part whose content is the concatenation of its pieces.
+type pieceFile struct {
+	name   string
+	pieces []archiveFile
+}
+
+func (pf *pieceFile) Open() (io.ReadCloser, error) {
+	return &pieceReader{pieces: pf.pieces}, nil
+}
+
+func (pf *pieceFile) Name() string {
+	return pf.name
+}
+
+func (pf *pieceFile) Size() int {
+	var size int
+	for _, p := range pf.pieces {
+		size += p.Size()
+	}
+	return size
+}
+
+// pieceReader reads the pieces sequentially, opening each one when the previous is exhausted.
+type pieceReader struct {
+	pieces []archiveFile
+	cur    io.ReadCloser
+}
+
+func (r *pieceReader) Read(b []byte) (int, error) {
+	for {
+		if r.cur == nil {
+			if len(r.pieces) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := r.pieces[0].Open()
+			if err != nil {
+				return 0, err
+			}
+			r.cur, r.pieces = rc, r.pieces[1:]
+		}
+		n, err := r.cur.Read(b)
+		if err == io.EOF {
+			r.cur.Close()
+			r.cur = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *pieceReader) Close() error {
+	if r.cur == nil {
+		return nil
+	}
+	err := r.cur.Close()
+	r.cur = nil
+	return err
+}
+
+// PartWriter writes the contents of an interleaved part created by Writer.CreateInterleaved.
+type PartWriter struct {
+	w           *Writer
+	part        *Part
+	compression CompressionOption
+	buf         bytes.Buffer
+	dst         io.Writer
+	pieces      int
+	closed      bool
+}
+
+// Write buffers b until the next call to Flush or Close.
+func (pw *PartWriter) Write(b []byte) (int, error) {
+	if pw.closed {
+		return 0, fmt.Errorf("opc: %s: cannot be written: the part is closed", pw.part.Name)
+	}
+	return pw.dst.Write(b)
+}
+
+// Flush writes the buffered contents as a new piece of the part.
+// It does nothing if there are no buffered contents.
+func (pw *PartWriter) Flush() error {
+	if pw.closed {
+		return fmt.Errorf("opc: %s: cannot be flushed: the part is closed", pw.part.Name)
+	}
+	if pw.buf.Len() == 0 {
+		return nil
+	}
+	return pw.writePiece(false)
+}
+
+// Close writes the buffered contents as the last piece of the part,
+// followed by the part relationships.
+// Closing a PartWriter more than once has no effect.
+func (pw *PartWriter) Close() error {
+	if pw.closed {
+		return nil
+	}
+	if err := pw.writePiece(true); err != nil {
+		return err
+	}
+	pw.closed = true
+	return pw.w.createPartRelationships(pw.part)
+}
+
+func (pw *PartWriter) writePiece(last bool) error {
+	if err := pw.w.createPending(); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s/[%d].piece", zipName(pw.part.Name), pw.pieces)
+	if last {
+		name = fmt.Sprintf("%s/[%d].last.piece", zipName(pw.part.Name), pw.pieces)
+	}
+	fh := &zip.FileHeader{
+		Name:     name,
+		Modified: pw.w.now(),
+	}
+	pw.w.setCompressor(fh, pw.compression)
+	fw, err := pw.w.w.CreateHeader(fh)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be created: %v", pw.part.Name, err)
+	}
+	if _, err = fw.Write(pw.buf.Bytes()); err != nil {
+		return fmt.Errorf("opc: %s: cannot be written: %v", pw.part.Name, err)
+	}
+	pw.buf.Reset()
+	pw.pieces++
+	return nil
+}
@@ -0,0 +1,229 @@
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+type zipItem struct {
+	name    string
+	content string
+}
+
+func newTestZip(t *testing.T, items ...zipItem) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, item := range items {
+		fw, err := zw.Create(item.name)
+		if err != nil {
+			t.Fatalf("cannot create zip item: %v", err)
+		}
+		fw.Write([]byte(item.content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func Test_parsePieceName(t *testing.T) {
+	tests := []struct {
+		name      string
+		wantPart  string
+		wantIndex int
+		wantLast  bool
+		wantOk    bool
+	}{
+		{"a.xml", "", 0, false, false},
+		{"dir/a.xml", "", 0, false, false},
+		{"a.xml/[0].piece", "a.xml", 0, false, true},
+		{"dir/a.xml/[12].last.piece", "dir/a.xml", 12, true, true},
+		{"a.xml/[3].LAST.PIECE", "a.xml", 3, true, true},
+		{"a.xml/[01].piece", "a.xml", -1, false, true},
+		{"a.xml/[-0].piece", "a.xml", -1, false, true},
+		{"a.xml/[].piece", "a.xml", -1, false, true},
+		{"a.xml/[a].piece", "a.xml", -1, false, true},
+		{"[0].piece", "", 0, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			part, index, last, ok := parsePieceName(tt.name)
+			if ok != tt.wantOk {
+				t.Fatalf("parsePieceName() ok = %v, want %v", ok, tt.wantOk)
+			}
+			if !ok {
+				return
+			}
+			if part != tt.wantPart || index != tt.wantIndex || last != tt.wantLast {
+				t.Errorf("parsePieceName() = (%s, %d, %v), want (%s, %d, %v)", part, index, last, tt.wantPart, tt.wantIndex, tt.wantLast)
+			}
+		})
+	}
+}
+
+func TestNewReader_Pieces(t *testing.T) {
+	ct := zipItem{"[Content_Types].xml", new(cTypeBuilder).withDefault("application/xml", "xml").withDefault(relationshipContentType, "rels").String()}
+	rels := new(relsBuilder).withRel("rId1", "main", "/docs/a.xml").String()
+	tests := []struct {
+		name     string
+		items    []zipItem
+		want     string
+		wantCode int
+	}{
+		{"interleaved", []zipItem{
+			ct,
+			{"docs/a.xml/[1].piece", "<b/>"},
+			{"_rels/.rels/[0].last.piece", rels},
+			{"b.xml", "<c/>"},
+			{"docs/a.xml/[0].piece", "<a>"},
+			{"docs/a.xml/[2].last.piece", "</a>"},
+		}, "<a><b/></a>", 0},
+		{"emptyPieces", []zipItem{
+			ct,
+			{"docs/a.xml/[0].piece", ""},
+			{"docs/a.xml/[1].piece", "<a/>"},
+			{"docs/a.xml/[2].last.piece", ""},
+		}, "<a/>", 0},
+		{"invalidName", []zipItem{ct, {"docs/a.xml/[00].last.piece", "<a/>"}}, "", 311},
+		{"gap", []zipItem{ct, {"docs/a.xml/[0].piece", "<a>"}, {"docs/a.xml/[2].last.piece", "</a>"}}, "", 312},
+		{"notStartingWithZero", []zipItem{ct, {"docs/a.xml/[1].last.piece", "<a/>"}}, "", 312},
+		{"duplicated", []zipItem{ct, {"docs/a.xml/[0].piece", "<a/>"}, {"DOCS/A.XML/[0].last.piece", "<a/>"}}, "", 312},
+		{"noLast", []zipItem{ct, {"docs/a.xml/[0].piece", "<a>"}, {"docs/a.xml/[1].piece", "</a>"}}, "", 313},
+		{"lastNotHighest", []zipItem{ct, {"docs/a.xml/[0].last.piece", "<a>"}, {"docs/a.xml/[1].piece", "</a>"}}, "", 313},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestZip(t, tt.items...)
+			r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+			if tt.wantCode != 0 {
+				if err == nil {
+					t.Fatal("NewReader() want error")
+				}
+				if got := err.(*Error).Code(); got != tt.wantCode {
+					t.Errorf("NewReader() error code = %d, want %d", got, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			var f *File
+			for _, file := range r.Files {
+				if file.Name == "/docs/a.xml" {
+					f = file
+				}
+			}
+			if f == nil {
+				t.Fatalf("NewReader() files = %v, want /docs/a.xml", r.Files)
+			}
+			if f.Size != len(tt.want) {
+				t.Errorf("File.Size = %d, want %d", f.Size, len(tt.want))
+			}
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("File.Open() error = %v", err)
+			}
+			got, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			if string(got) != tt.want {
+				t.Errorf("File.Open() content = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriter_CreateInterleaved(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	key := newTestRSAKey(t)
+	if err := w.Sign(key, []*x509.Certificate{newTestCertificate(t, key)}, nil); err != nil {
+		t.Fatalf("Writer.Sign() error = %v", err)
+	}
+	sheet, err := w.CreateInterleaved(&Part{Name: "/xl/sheet.xml", ContentType: "application/xml", Relationships: []*Relationship{
+		{ID: "rId1", Type: "sharedStrings", TargetURI: "/xl/strings.xml"},
+	}}, CompressionNormal)
+	if err != nil {
+		t.Fatalf("Writer.CreateInterleaved() error = %v", err)
+	}
+	strs, err := w.CreateInterleaved(&Part{Name: "/xl/strings.xml", ContentType: "application/xml"}, CompressionFast)
+	if err != nil {
+		t.Fatalf("Writer.CreateInterleaved() error = %v", err)
+	}
+	if _, err = w.CreateInterleaved(&Part{Name: "/XL/SHEET.XML", ContentType: "application/xml"}, CompressionNormal); err == nil {
+		t.Error("Writer.CreateInterleaved() want error for a duplicated part")
+	}
+	var wantSheet, wantStrs bytes.Buffer
+	for i := 0; i < 3; i++ {
+		row := fmt.Sprintf("<row r='%d'/>", i)
+		str := fmt.Sprintf("<si>%d</si>", i)
+		sheet.Write([]byte(row))
+		strs.Write([]byte(str))
+		wantSheet.WriteString(row)
+		wantStrs.WriteString(str)
+		if err = sheet.Flush(); err != nil {
+			t.Fatalf("PartWriter.Flush() error = %v", err)
+		}
+		if err = strs.Flush(); err != nil {
+			t.Fatalf("PartWriter.Flush() error = %v", err)
+		}
+	}
+	pw, _ := w.Create("/a.xml", "application/xml")
+	pw.Write([]byte("<a/>"))
+	sheet.Write([]byte("<end/>"))
+	wantSheet.WriteString("<end/>")
+	if err = sheet.Close(); err != nil {
+		t.Fatalf("PartWriter.Close() error = %v", err)
+	}
+	if _, err = sheet.Write([]byte("<a/>")); err == nil {
+		t.Error("PartWriter.Write() want error after Close")
+	}
+	// strs is closed by the Writer.
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+
+	zr, _ := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	wantNames := []string{
+		"xl/sheet.xml/[0].piece", "xl/strings.xml/[0].piece", "xl/sheet.xml/[1].piece", "xl/strings.xml/[1].piece",
+		"xl/sheet.xml/[2].piece", "xl/strings.xml/[2].piece", "a.xml", "xl/sheet.xml/[3].last.piece", "xl/_rels/sheet.xml.rels",
+		"xl/strings.xml/[3].last.piece",
+	}
+	if !reflect.DeepEqual(names[:len(wantNames)], wantNames) {
+		t.Errorf("Writer.CreateInterleaved() items = %v, want %v", names, wantNames)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	for _, f := range r.Files {
+		var want string
+		switch f.Name {
+		case "/xl/sheet.xml":
+			want = wantSheet.String()
+			if len(f.Relationships) != 1 {
+				t.Errorf("File.Relationships = %v, want 1 relationship", f.Relationships)
+			}
+		case "/xl/strings.xml":
+			want = wantStrs.String()
+		default:
+			continue
+		}
+		rc, _ := f.Open()
+		got, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		if string(got) != want {
+			t.Errorf("File.Open() %s content = %s, want %s", f.Name, got, want)
+		}
+	}
+	if s := r.Signatures[0]; !s.Valid() || len(s.Uncovered) != 0 {
+		t.Errorf("Signature.Valid() error = %v, uncovered = %v", s.Err, s.Uncovered)
+	}
+}
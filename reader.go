@@ -2,11 +2,12 @@ package opc
 
 import (
 	"archive/zip"
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
-	"path"
 	"strings"
 )
 
@@ -29,6 +30,12 @@ type ReadCloser struct {
 
 // OpenReader will open the OPC file specified by name and return a ReadCloser.
 func OpenReader(name string) (*ReadCloser, error) {
+	return OpenReaderWithOptions(name, ReaderOptions{})
+}
+
+// OpenReaderWithOptions will open the OPC file specified by name and return a ReadCloser,
+// checking the package as requested by opts like NewReaderWithOptions does.
+func OpenReaderWithOptions(name string, opts ReaderOptions) (*ReadCloser, error) {
 	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
@@ -38,7 +45,7 @@ func OpenReader(name string) (*ReadCloser, error) {
 		f.Close()
 		return nil, err
 	}
-	r, err := NewReader(f, fi.Size())
+	r, err := NewReaderWithOptions(f, fi.Size(), opts)
 	return &ReadCloser{f: f, Reader: r}, err
 }
 
@@ -62,11 +69,19 @@ func (f *File) Open() (io.ReadCloser, error) {
 
 // Reader implements a OPC file reader.
 type Reader struct {
-	Files         []*File
-	Relationships []*Relationship
-	Properties    CoreProperties
-	p             *pkg
-	r             archive
+	Files              []*File
+	Relationships      []*Relationship
+	Properties         CoreProperties
+	ExtendedProperties ExtendedProperties // The extended properties, if the package has an extended properties part that can be decoded. The part is also listed in Files.
+	CustomProperties   CustomProperties   // The custom properties, if the package has a custom properties part that can be decoded. The part is also listed in Files.
+	Signatures         []*Signature       // The package digital signatures. The parts implementing them are not listed in Files.
+	p                  *pkg
+	r                  archive
+	files              []archiveFile // The archive files with the interleaved parts already merged.
+	sigParts           *signatureParts
+	propParts          []string              // The name of the core properties part, which is not listed in Files.
+	hiddenRels         []SourcedRelationship // The relationships of the parts not listed in Files.
+	coreDoc            []byte                // The core properties part as read, which NewWriterFromReader preserves.
 }
 
 // NewReader returns a new Reader reading an OPC file to r.
@@ -78,15 +93,48 @@ func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
 	return newReader(zr)
 }
 
+// ReaderOptions defines how NewReaderWithOptions reads an OPC file.
+type ReaderOptions struct {
+	StrictRelationships bool // If true the internal relationships shall target parts of the package.
+}
+
+// NewReaderWithOptions returns a new Reader reading an OPC file to r, as NewReader does,
+// which also checks the package as requested by opts.
+// If opts.StrictRelationships is true and a relationship of any part, including the ones
+// not listed in Files, targets a part that does not exist,
+// it returns an *Error holding the source part and the relationship ID of the first one.
+// Reader.DanglingRelationships lists all of them.
+func NewReaderWithOptions(r io.ReaderAt, size int64, opts ReaderOptions) (*Reader, error) {
+	rd, err := NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if opts.StrictRelationships {
+		if err := validateRelationshipTargets(rd.loadedRelationships(), rd.partExists); err != nil {
+			return nil, err
+		}
+	}
+	return rd, nil
+}
+
 // newReader returns a new Reader reading an OPC file to r.
 func newReader(a archive) (*Reader, error) {
-	r := &Reader{p: newPackage(), r: a}
+	files, err := groupPieces(a.Files())
+	if err != nil {
+		return nil, err
+	}
+	r := &Reader{p: newPackage(), r: a, files: files}
 	if err := r.loadPackage(); err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
+// ContentTypes returns the Default and Override elements of the package content types stream.
+func (r *Reader) ContentTypes() ContentTypes {
+	return r.p.contentTypes.view(r.p)
+}
+
 // SetDecompressor sets or overrides a custom decompressor for the DEFLATE.
 func (r *Reader) SetDecompressor(dcomp func(r io.Reader) io.ReadCloser) {
 	r.r.RegisterDecompressor(zip.Deflate, dcomp)
@@ -97,16 +145,24 @@ func (r *Reader) loadPackage() error {
 	if err != nil {
 		return err
 	}
-	files := r.r.Files()
+	sigParts, err := r.findSignatureParts(rels)
+	if err != nil {
+		return err
+	}
+	files := r.files
 	r.Files = make([]*File, 0, len(files)-1) // -1 is for [Content_Types].xml
+	archiveFiles := make(map[string]archiveFile, len(files))
 
 	for _, file := range files {
 		fileName := "/" + file.Name()
+		archiveFiles[partKey(fileName)] = file
 		// skip content types part, relationship parts and directories
 		if strings.EqualFold(fileName, contentTypesName) || isRelationshipURI(fileName) || strings.HasSuffix(fileName, "/") {
 			continue
 		}
-		if strings.EqualFold(fileName, ResolveRelationship("/", r.Properties.PartName)) {
+		if PartName(fileName).Equal(PartName(ResolveRelationship("/", r.Properties.PartName))) {
+			r.propParts = append(r.propParts, fileName)
+			r.addHiddenRelationships(fileName, rels.findRelationship(fileName))
 			err := r.loadCoreProperties(file)
 			if err != nil {
 				return err
@@ -117,26 +173,83 @@ func (r *Reader) loadPackage() error {
 				return err
 			}
 			part := &Part{Name: fileName, ContentType: cType, Relationships: rels.findRelationship(fileName)}
-			r.Files = append(r.Files, &File{part, file.Size(), file})
 			if err = r.p.add(part); err != nil {
 				return err
 			}
+			if !sigParts.contains(fileName) {
+				r.Files = append(r.Files, &File{part, file.Size(), file})
+			} else {
+				r.addHiddenRelationships(fileName, part.Relationships)
+			}
+			if r.ExtendedProperties.PartName != "" && PartName(fileName).Equal(PartName(ResolveRelationship("/", r.ExtendedProperties.PartName))) {
+				r.loadExtendedProperties(file)
+			} else if r.CustomProperties.PartName != "" && PartName(fileName).Equal(PartName(ResolveRelationship("/", r.CustomProperties.PartName))) {
+				r.loadCustomProperties(file)
+			}
 		}
 	}
+	if err := r.validateThumbnails(ct); err != nil {
+		return err
+	}
 	r.p.contentTypes = *ct
+	r.sigParts = sigParts
+	r.loadSignatures(&signatureVerifier{files: archiveFiles, ct: ct, parts: sigParts})
 	return nil
 }
 
+func (r *Reader) addHiddenRelationships(source string, rels []*Relationship) {
+	for _, rel := range rels {
+		r.hiddenRels = append(r.hiddenRels, SourcedRelationship{source, rel})
+	}
+}
+
+// findSignatureParts returns the Digital Signature Origin part and the parts it targets
+// as defined in ISO/IEC 29500-2 §13.2.
+func (r *Reader) findSignatureParts(rels *relationshipsPart) (*signatureParts, error) {
+	sp := &signatureParts{certificates: make(map[string][]string)}
+	for _, rel := range r.Relationships {
+		if strings.EqualFold(rel.Type, signatureOriginRel) && rel.TargetMode == ModeInternal {
+			if sp.origin != "" {
+				return nil, newErrorRelationship(601, "/", rel.ID)
+			}
+			sp.origin = NormalizePartName(ResolveRelationship("/", rel.TargetURI))
+		}
+	}
+	if sp.origin == "" {
+		return sp, nil
+	}
+	sp.originRels = rels.findRelationship(sp.origin)
+	for _, rel := range sp.originRels {
+		if !strings.EqualFold(rel.Type, signatureRel) || rel.TargetMode != ModeInternal {
+			continue
+		}
+		name := NormalizePartName(ResolveRelationship(sp.origin, rel.TargetURI))
+		sp.signatures = append(sp.signatures, name)
+		for _, crel := range rels.findRelationship(name) {
+			if strings.EqualFold(crel.Type, signatureCertificateRel) && crel.TargetMode == ModeInternal {
+				sp.certificates[name] = append(sp.certificates[name], NormalizePartName(ResolveRelationship(name, crel.TargetURI)))
+			}
+		}
+	}
+	return sp, nil
+}
+
+func (r *Reader) loadSignatures(v *signatureVerifier) {
+	for _, name := range v.parts.signatures {
+		r.Signatures = append(r.Signatures, v.verify(name))
+	}
+}
+
 func (r *Reader) loadPartProperties() (*contentTypes, *relationshipsPart, error) {
 	var ct *contentTypes
 	rels := new(relationshipsPart)
-	for _, file := range r.r.Files() {
+	for _, file := range r.files {
 		var err error
 		name := "/" + file.Name()
 		if strings.EqualFold(name, contentTypesName) {
 			ct, err = r.loadContentType(file)
 		} else if isRelationshipURI(name) {
-			if strings.EqualFold(name, packageRelName) {
+			if PartName(name).Equal(packageRelName) {
 				err = r.loadPackageRelationships(file)
 			} else {
 				err = loadRelationships(file, rels)
@@ -166,7 +279,44 @@ func (r *Reader) loadCoreProperties(file archiveFile) error {
 	if err != nil {
 		return fmt.Errorf("opc: %s: cannot be opened: %v", r.Properties.PartName, err)
 	}
-	return decodeCoreProperties(reader, &r.Properties)
+	defer reader.Close()
+	b, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be read: %v", r.Properties.PartName, err)
+	}
+	if err = decodeCoreProperties(bytes.NewReader(b), &r.Properties); err != nil {
+		return err
+	}
+	r.coreDoc = b
+	return nil
+}
+
+// loadExtendedProperties decodes the extended properties part.
+// The extended properties are optional metadata, so a part that cannot be decoded
+// leaves them empty instead of making the package unreadable; its content is still available in Files.
+func (r *Reader) loadExtendedProperties(file archiveFile) {
+	reader, err := file.Open()
+	if err == nil {
+		defer reader.Close()
+		err = decodeExtendedProperties(reader, &r.ExtendedProperties)
+	}
+	if err != nil {
+		r.ExtendedProperties = ExtendedProperties{PartName: r.ExtendedProperties.PartName, RelationshipID: r.ExtendedProperties.RelationshipID}
+	}
+}
+
+// loadCustomProperties decodes the custom properties part.
+// A part that cannot be decoded leaves the custom properties empty, including their PartName,
+// so the part is copied as is by NewWriterFromReader; its content is still available in Files.
+func (r *Reader) loadCustomProperties(file archiveFile) {
+	reader, err := file.Open()
+	if err == nil {
+		defer reader.Close()
+		err = decodeCustomProperties(reader, &r.CustomProperties)
+	}
+	if err != nil {
+		r.CustomProperties = CustomProperties{}
+	}
 }
 
 func loadRelationships(file archiveFile, rels *relationshipsPart) error {
@@ -179,11 +329,8 @@ func loadRelationships(file archiveFile, rels *relationshipsPart) error {
 		return err
 	}
 
-	// get part name from rels parts
-	name := path.Dir(path.Dir(file.Name()))
-	pname := "/" + name + "/" + strings.TrimSuffix(path.Base(file.Name()), path.Ext(file.Name()))
-	pname = NormalizePartName(pname)
-	rels.addRelationship(pname, rls)
+	source, _ := PartName("/" + file.Name()).SourcePartName()
+	rels.addRelationship(NormalizePartName(string(source)), rls)
 	return nil
 }
 
@@ -199,9 +346,18 @@ func (r *Reader) loadPackageRelationships(file archiveFile) error {
 	r.Relationships = rls
 	for _, rel := range rls {
 		if strings.EqualFold(rel.Type, corePropsRel) {
+			// ISO/IEC 29500-2 M4.1
+			if r.Properties.RelationshipID != "" {
+				return newErrorRelationship(401, "/", rel.ID)
+			}
 			r.Properties.PartName = rel.TargetURI
 			r.Properties.RelationshipID = rel.ID
-			break
+		} else if strings.EqualFold(rel.Type, extendedPropsRel) && rel.TargetMode == ModeInternal && r.ExtendedProperties.RelationshipID == "" {
+			r.ExtendedProperties.PartName = rel.TargetURI
+			r.ExtendedProperties.RelationshipID = rel.ID
+		} else if strings.EqualFold(rel.Type, customPropsRel) && rel.TargetMode == ModeInternal && r.CustomProperties.RelationshipID == "" {
+			r.CustomProperties.PartName = rel.TargetURI
+			r.CustomProperties.RelationshipID = rel.ID
 		}
 	}
 	return nil
@@ -252,7 +408,7 @@ func decodeContentTypes(r io.Reader) (*contentTypes, error) {
 			}
 			ct.addDefault(ext, cDefault.ContentType)
 		} else if cOverride, ok := c.Value.(overrideContentTypeXML); ok {
-			partName := strings.ToUpper(NormalizePartName(cOverride.PartName))
+			partName := partKey(cOverride.PartName)
 			if _, ok := ct.overrides[partName]; ok {
 				return nil, newError(205, partName)
 			}
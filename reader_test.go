@@ -17,17 +17,17 @@ import (
 
 func Test_newReader(t *testing.T) {
 	p1 := newPackage()
-	p1.parts["/DOCPROPS/APP.XML"] = struct{}{}
-	p1.parts["/PICTURES/PHOTO.PNG"] = struct{}{}
-	p1.parts["/FILES.XML"] = struct{}{}
+	p1.parts["/DOCPROPS/APP.XML"] = packagePart{"/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml"}
+	p1.parts["/PICTURES/PHOTO.PNG"] = packagePart{"/pictures/photo.png", "image/png"}
+	p1.parts["/FILES.XML"] = packagePart{"/files.xml", "application/xml"}
 	p1.contentTypes.addOverride("/DOCPROPS/APP.XML", "application/vnd.openxmlformats-officedocument.extended-properties+xml")
 	p1.contentTypes.addDefault("png", "image/png")
 	p1.contentTypes.addDefault("xml", "application/xml")
 
 	p2 := newPackage()
-	p2.parts["/DOCPROPS/APP.XML"] = struct{}{}
-	p2.parts["/PICTURES/PHOTO.PNG"] = struct{}{}
-	p2.parts["/FILES.XML"] = struct{}{}
+	p2.parts["/DOCPROPS/APP.XML"] = packagePart{"/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml"}
+	p2.parts["/PICTURES/PHOTO.PNG"] = packagePart{"/pictures/photo.png", "image/png"}
+	p2.parts["/FILES.XML"] = packagePart{"/files.xml", "application/xml"}
 	p2.contentTypes.addOverride("/DOCPROPS/APP.XML", "application/vnd.openxmlformats-officedocument.extended-properties+xml")
 	p2.contentTypes.addDefault("xml", "application/xml")
 	p2.contentTypes.addDefault("png", "image/png")
@@ -166,9 +166,9 @@ func Test_newReader_ContentType(t *testing.T) {
 </Types>`
 
 	p := newPackage()
-	p.parts["/DOCPROPS/APP.XML"] = struct{}{}
-	p.parts["/PICTURES/PHOTO.PNG"] = struct{}{}
-	p.parts["/FILES.XML"] = struct{}{}
+	p.parts["/DOCPROPS/APP.XML"] = packagePart{"/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml"}
+	p.parts["/PICTURES/PHOTO.PNG"] = packagePart{"/pictures/photo.PNG", "image/png"}
+	p.parts["/FILES.XML"] = packagePart{"/files.xml", "application/xml"}
 	p.contentTypes.addOverride("/DOCPROPS/APP.XML", "application/vnd.openxmlformats-officedocument.extended-properties+xml")
 	p.contentTypes.addDefault("png", "image/png")
 	p.contentTypes.addDefault("xml", "application/xml")
@@ -275,18 +275,18 @@ func Test_newReader_ContentType(t *testing.T) {
 
 func Test_newReader_PartRelationships(t *testing.T) {
 	p3 := newPackage()
-	p3.parts["/DOCPROPS/APP.XML"] = struct{}{}
-	p3.parts["/PICTURES/PHOTO.PNG"] = struct{}{}
-	p3.parts["/FILES.XML"] = struct{}{}
+	p3.parts["/DOCPROPS/APP.XML"] = packagePart{"/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml"}
+	p3.parts["/PICTURES/PHOTO.PNG"] = packagePart{"/pictures/photo.png", "image/png"}
+	p3.parts["/FILES.XML"] = packagePart{"/files.xml", "application/xml"}
 	p3.contentTypes.addOverride("/DOCPROPS/APP.XML", "application/vnd.openxmlformats-officedocument.extended-properties+xml")
 	p3.contentTypes.addDefault("xml", "application/xml")
 	p3.contentTypes.addDefault("png", "image/png")
 
 	p4 := newPackage()
-	p4.parts["/DOCPROPS/APP.XML"] = struct{}{}
-	p4.parts["/PICTURES/SEASON/SUMMER/PHOTO.PNG"] = struct{}{}
-	p4.parts["/PICTURES/SUMMER/PHOTO2.PNG"] = struct{}{}
-	p4.parts["/FILES.XML"] = struct{}{}
+	p4.parts["/DOCPROPS/APP.XML"] = packagePart{"/docProps/app.xml", "application/vnd.openxmlformats-officedocument.extended-properties+xml"}
+	p4.parts["/PICTURES/SEASON/SUMMER/PHOTO.PNG"] = packagePart{"/pictures/season/summer/photo.png", "image/png"}
+	p4.parts["/PICTURES/SUMMER/PHOTO2.PNG"] = packagePart{"/pictures/summer/photo2.png", "image/png"}
+	p4.parts["/FILES.XML"] = packagePart{"/files.xml", "application/xml"}
 	p4.contentTypes.addOverride("/DOCPROPS/APP.XML", "application/vnd.openxmlformats-officedocument.extended-properties+xml")
 	p4.contentTypes.addDefault("xml", "application/xml")
 	p4.contentTypes.addDefault("png", "image/png")
@@ -456,6 +456,22 @@ func Test_newReader_CoreProperties(t *testing.T) {
 			newMockFile("docProps/core.xml", ioutil.NopCloser(nil), errors.New("")),
 			newMockFile("docProps/app.xml", ioutil.NopCloser(bytes.NewBufferString("")), nil),
 		}, *cp, true},
+		{"duplicatedRelationship", []archiveFile{
+			newMockFile(
+				"[Content_Types].xml",
+				ioutil.NopCloser(bytes.NewBufferString(new(cTypeBuilder).
+					withOverride("application/vnd.openxmlformats-package.core-properties+xml", "/docProps/core.xml").String())),
+				nil,
+			),
+			newMockFile(
+				"_rels/.rels",
+				ioutil.NopCloser(bytes.NewBufferString(new(relsBuilder).
+					withRel("rId2", "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties", "docProps/core.xml").
+					withRel("rId3", "http://schemas.openxmlformats.org/package/2006/relationships/metadata/core-properties", "docProps/core.xml").String())),
+				nil,
+			),
+			newMockFile("docProps/core.xml", ioutil.NopCloser(bytes.NewBufferString(coreFile)), nil),
+		}, *cp, true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -659,3 +675,62 @@ func TestOpenReader(t *testing.T) {
 		})
 	}
 }
+
+func TestNewReaderWithOptions(t *testing.T) {
+	b := newGraphTestPackage(t)
+	if _, err := NewReaderWithOptions(bytes.NewReader(b), int64(len(b)), ReaderOptions{}); err != nil {
+		t.Fatalf("NewReaderWithOptions() error = %v", err)
+	}
+	_, err := NewReaderWithOptions(bytes.NewReader(b), int64(len(b)), ReaderOptions{StrictRelationships: true})
+	e, ok := err.(*Error)
+	if !ok || e.Code() != 130 || e.PartName() != "/docs/a.xml" || e.RelationshipID() != "rId3" {
+		t.Errorf("NewReaderWithOptions() error = %v, want 130 for /docs/a.xml#rId3", err)
+	}
+}
+
+func TestNewReaderWithOptions_hiddenParts(t *testing.T) {
+	tests := []struct {
+		name   string
+		files  map[string]string
+		source string
+	}{
+		{"coreProperties", map[string]string{
+			"_rels/.rels":                  new(relsBuilder).withRel("rId1", corePropsRel, "/docProps/core.xml").String(),
+			"docProps/core.xml":            buildCoreString(""),
+			"docProps/_rels/core.xml.rels": new(relsBuilder).withRel("rId2", "other", "/missing.xml").String(),
+		}, "/docProps/core.xml"},
+		{"signatureOrigin", map[string]string{
+			"_rels/.rels":                           new(relsBuilder).withRel("rId1", signatureOriginRel, "/_xmlsignatures/origin.sigs").String(),
+			"_xmlsignatures/origin.sigs":            "",
+			"_xmlsignatures/_rels/origin.sigs.rels": new(relsBuilder).withRel("rId2", "other", "/missing.xml").String(),
+		}, "/_xmlsignatures/origin.sigs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			tt.files["[Content_Types].xml"] = new(cTypeBuilder).withDefault(relationshipContentType, "rels").
+				withOverride(corePropsContentType, "/docProps/core.xml").withOverride(signatureOriginContentType, "/_xmlsignatures/origin.sigs").String()
+			for name, content := range tt.files {
+				fw, _ := zw.Create(name)
+				fw.Write([]byte(content))
+			}
+			zw.Close()
+			_, err := NewReaderWithOptions(bytes.NewReader(buf.Bytes()), int64(buf.Len()), ReaderOptions{StrictRelationships: true})
+			if e, ok := err.(*Error); !ok || e.Code() != 130 || e.PartName() != tt.source || e.RelationshipID() != "rId2" {
+				t.Errorf("NewReaderWithOptions() error = %v, want 130 for %s#rId2", err, tt.source)
+			}
+		})
+	}
+}
+
+func TestOpenReaderWithOptions(t *testing.T) {
+	r, err := OpenReaderWithOptions("testdata/office.docx", ReaderOptions{StrictRelationships: true})
+	if err != nil {
+		t.Fatalf("OpenReaderWithOptions() error = %v", err)
+	}
+	r.Close()
+	if _, err = OpenReaderWithOptions("", ReaderOptions{StrictRelationships: true}); err == nil {
+		t.Error("OpenReaderWithOptions() want error for a missing file")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"sort"
 	"strings"
 )
 
@@ -19,7 +20,10 @@ const (
 	ModeExternal
 )
 
-const externalMode = "External"
+const (
+	externalMode           = "External"
+	relationshipsNamespace = "http://schemas.openxmlformats.org/package/2006/relationships"
+)
 
 // Relationship is used to express a relationship between a source and a target part.
 // If the ID is not specified a unique ID will be generated following the pattern rIdN.
@@ -62,6 +66,40 @@ func newRelationshipID(rels []*Relationship) string {
 	return id
 }
 
+// setRelationshipIDs assigns a new ID to the relationships of rels that do not have one.
+// If sorted is true the IDs are assigned by type, target and target mode
+// instead of by the order of rels.
+func setRelationshipIDs(rels []*Relationship, sorted bool) {
+	var missing []*Relationship
+	for _, r := range rels {
+		if r.ID == "" {
+			missing = append(missing, r)
+		}
+	}
+	if sorted {
+		sort.SliceStable(missing, func(i, j int) bool {
+			a, b := missing[i], missing[j]
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+			if a.TargetURI != b.TargetURI {
+				return a.TargetURI < b.TargetURI
+			}
+			return a.TargetMode < b.TargetMode
+		})
+	}
+	for _, r := range missing {
+		r.ID = newRelationshipID(rels)
+	}
+}
+
+// sortRelationshipsByID returns a copy of rels sorted by ID.
+func sortRelationshipsByID(rels []*Relationship) []*Relationship {
+	sorted := append([]*Relationship(nil), rels...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
+
 func isValueInList(value string, list []string) bool {
 	for _, v := range list {
 		if v == value {
@@ -114,7 +152,8 @@ func isRelationshipURI(uri string) bool {
 
 // validateRelationshipTarget checks that a relationship target follows the constrains specified in the ISO/IEC 29500-2 §9.3.
 func (r *Relationship) validateRelationshipTarget(sourceURI string) error {
-	if !validEncoded(r.TargetURI) {
+	target, fragment := split(r.TargetURI, '#')
+	if !validEncoded(target) || !validEncoded(strings.TrimPrefix(fragment, "#")) {
 		return newErrorRelationship(128, sourceURI, r.ID)
 	}
 	// ISO/IEC 29500-2 M1.29
@@ -123,7 +162,7 @@ func (r *Relationship) validateRelationshipTarget(sourceURI string) error {
 			return newErrorRelationship(129, sourceURI, r.ID)
 		}
 		source := strings.TrimSpace(sourceURI)
-		if source == "" || isRelationshipURI(ResolveRelationship(sourceURI, r.TargetURI)) {
+		if source == "" || isRelationshipURI(ResolveRelationship(sourceURI, target)) {
 			return newErrorRelationship(125, sourceURI, r.ID)
 		}
 	}
@@ -144,11 +183,28 @@ func validateRelationships(sourceURI string, rs []*Relationship) error {
 		}
 		ids[r.ID] = s
 	}
+	if _, err := findThumbnailRelationship(sourceURI, rs); err != nil {
+		return err
+	}
+	return nil
+}
+
+// validateRelationshipTargets returns an error for the first internal relationship of rels
+// whose target, ignoring the fragment, is not a part for which exists returns true.
+func validateRelationshipTargets(rels []SourcedRelationship, exists func(name string) bool) error {
+	for _, rel := range rels {
+		if rel.TargetMode != ModeInternal {
+			continue
+		}
+		if name := rel.TargetName(); name == "" || !exists(name) {
+			return newErrorRelationship(130, rel.Source, rel.ID)
+		}
+	}
 	return nil
 }
 
 func encodeRelationships(w io.Writer, rs []*Relationship) error {
-	re := &relationshipsXML{XML: "http://schemas.openxmlformats.org/package/2006/relationships"}
+	re := &relationshipsXML{XML: relationshipsNamespace}
 	for _, r := range rs {
 		re.RelsXML = append(re.RelsXML, r.toXML())
 	}
@@ -184,14 +240,14 @@ func (rp *relationshipsPart) findRelationship(name string) []*Relationship {
 	if rp.relation == nil {
 		rp.relation = make(map[string][]*Relationship)
 	}
-	return rp.relation[strings.ToUpper(name)]
+	return rp.relation[partKey(name)]
 }
 
 func (rp *relationshipsPart) addRelationship(name string, r []*Relationship) {
 	if rp.relation == nil {
 		rp.relation = make(map[string][]*Relationship)
 	}
-	rp.relation[strings.ToUpper(name)] = r
+	rp.relation[partKey(name)] = r
 }
 
 func isInternal(rawurl string) bool {
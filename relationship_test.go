@@ -46,6 +46,9 @@ func TestRelationship_validate(t *testing.T) {
 		{"new", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "fakeTarget", TargetMode: ModeExternal}, args{""}, false},
 		{"external", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "http://a.com/b", TargetMode: ModeExternal}, args{""}, false},
 		{"external1", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "://a.com/b", TargetMode: ModeInternal}, args{"/"}, false},
+		{"fragment", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "/two.xml#frag", TargetMode: ModeInternal}, args{"/one.txt"}, false},
+		{"fragmentRelRel", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "/_rels/.rels#frag", TargetMode: ModeInternal}, args{"/"}, true},
+		{"fragmentInvalid", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "/two.xml#a#b", TargetMode: ModeInternal}, args{"/one.txt"}, true},
 		{"externalErr", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "http://a.com/b", TargetMode: ModeInternal}, args{"/"}, true},
 		{"internalRelRel", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "/_rels/.rels", TargetMode: ModeInternal}, args{"/"}, true},
 		{"internalRelNoSource", &Relationship{ID: "fakeId", Type: "fakeType", TargetURI: "/fakeTarget", TargetMode: ModeInternal}, args{""}, true},
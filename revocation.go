@@ -0,0 +1,154 @@
+package opc
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"time"
+)
+
+// CertificateOptions defines how the certificate of a package signature is validated by Signature.VerifyCertificate.
+// The validation does not access the network, so the revocation information shall be
+// embedded in the signature XAdES revocation values or supplied in the options.
+type CertificateOptions struct {
+	Roots                  *x509.CertPool      // The trusted root certificates. If nil the system roots are used.
+	Intermediates          []*x509.Certificate // Intermediate certificates used along with the ones embedded in the signature.
+	Time                   time.Time           // Time at which the certificates are validated. If zero the time of a valid signature timestamp is used, or the SigningTime if there is none.
+	CRLs                   [][]byte            // DER encoded CRLs used along with the ones embedded in the signature.
+	OCSPResponses          [][]byte            // DER encoded OCSP responses used along with the ones embedded in the signature.
+	AllowUnknownRevocation bool                // If true the certificates whose revocation status cannot be determined are accepted.
+}
+
+// VerifyCertificate validates the certificate chain of the signer at the signing time,
+// including the revocation status of every certificate but the root, and returns the chain
+// starting with the signer certificate.
+// If the signature is not valid its Err is returned.
+func (s *Signature) VerifyCertificate(opts CertificateOptions) ([]*x509.Certificate, error) {
+	if s.Err != nil {
+		return nil, s.Err
+	}
+	if s.Certificate == nil {
+		return nil, newError(610, s.PartName)
+	}
+	t := opts.Time
+	if t.IsZero() {
+		t = s.SigningTime
+		if s.XAdES != nil && s.XAdES.Timestamp != nil && s.XAdES.Timestamp.Valid() {
+			t = s.XAdES.Timestamp.Time
+		}
+	}
+	intermediates := x509.NewCertPool()
+	for _, certs := range [][]*x509.Certificate{s.Certificates, opts.Intermediates} {
+		for _, c := range certs {
+			intermediates.AddCert(c)
+		}
+	}
+	crls, ocsps := opts.CRLs, opts.OCSPResponses
+	if s.XAdES != nil {
+		for _, c := range s.XAdES.CertificateValues {
+			intermediates.AddCert(c)
+		}
+		crls = append(append([][]byte(nil), crls...), s.XAdES.CRLValues...)
+		ocsps = append(append([][]byte(nil), ocsps...), s.XAdES.OCSPValues...)
+	}
+	chains, err := s.Certificate.Verify(x509.VerifyOptions{
+		Roots:         opts.Roots,
+		Intermediates: intermediates,
+		CurrentTime:   t,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return nil, newError(619, s.PartName)
+	}
+	rc := newRevocationChecker(crls, ocsps)
+	for _, chain := range chains {
+		if err = rc.checkChain(s.PartName, chain, t, opts.AllowUnknownRevocation); err == nil {
+			return chain, nil
+		}
+	}
+	return nil, err
+}
+
+type revocationStatus int
+
+const (
+	revocationUnknown revocationStatus = iota
+	revocationGood
+	revocationRevoked
+)
+
+// revocationChecker determines the revocation status of certificates
+// using the CRLs and OCSP responses that can be parsed.
+type revocationChecker struct {
+	crls  []*pkix.CertificateList
+	ocsps []*basicOCSP
+}
+
+func newRevocationChecker(crls, ocsps [][]byte) *revocationChecker {
+	rc := new(revocationChecker)
+	for _, der := range crls {
+		if crl, err := x509.ParseCRL(der); err == nil {
+			rc.crls = append(rc.crls, crl)
+		}
+	}
+	for _, der := range ocsps {
+		if resp, err := parseOCSPResponse(der); err == nil {
+			rc.ocsps = append(rc.ocsps, resp)
+		}
+	}
+	return rc
+}
+
+// checkChain checks the revocation status at t of all the certificates of chain but the root.
+func (rc *revocationChecker) checkChain(partName string, chain []*x509.Certificate, t time.Time, allowUnknown bool) error {
+	for i := 0; i+1 < len(chain); i++ {
+		switch rc.status(chain[i], chain[i+1], t) {
+		case revocationRevoked:
+			return newError(620, partName)
+		case revocationUnknown:
+			if !allowUnknown {
+				return newError(621, partName)
+			}
+		}
+	}
+	return nil
+}
+
+// status returns the revocation status at t of cert, which is issued by issuer.
+// A revocation after t does not affect the status.
+func (rc *revocationChecker) status(cert, issuer *x509.Certificate, t time.Time) revocationStatus {
+	for _, resp := range rc.ocsps {
+		r := resp.find(cert, issuer)
+		if r == nil || !coversTime(r.ThisUpdate, r.NextUpdate, t) || resp.verify(issuer) != nil {
+			continue
+		}
+		switch {
+		case bool(r.Good):
+			return revocationGood
+		case bool(r.Unknown):
+			continue
+		case r.Revoked.RevocationTime.After(t):
+			return revocationGood
+		default:
+			return revocationRevoked
+		}
+	}
+	for _, crl := range rc.crls {
+		tbs := crl.TBSCertList
+		if !coversTime(tbs.ThisUpdate, tbs.NextUpdate, t) || issuer.CheckCRLSignature(crl) != nil {
+			continue
+		}
+		for _, rev := range tbs.RevokedCertificates {
+			if rev.SerialNumber.Cmp(cert.SerialNumber) == 0 && !rev.RevocationTime.After(t) {
+				return revocationRevoked
+			}
+		}
+		return revocationGood
+	}
+	return revocationUnknown
+}
+
+// coversTime reports whether revocation information produced at thisUpdate,
+// and valid until nextUpdate if not zero, states the revocation status at t.
+func coversTime(thisUpdate, nextUpdate time.Time, t time.Time) bool {
+	return !thisUpdate.Before(t) || (!nextUpdate.IsZero() && !t.After(nextUpdate))
+}
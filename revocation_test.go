@@ -0,0 +1,182 @@
+package opc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA is a root certification authority which issues the signer certificates.
+type testCA struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key := newTestRSAKey(t)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "opc test root"},
+		NotBefore:             time.Now().Add(-2 * time.Hour),
+		NotAfter:              time.Now().Add(2 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return &testCA{key: key, cert: cert}
+}
+
+func (ca *testCA) issue(t *testing.T, signer crypto.Signer, serial int64) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "opc test signer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, signer.Public(), ca.key)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	return cert
+}
+
+func (ca *testCA) crl(t *testing.T, revoked ...pkix.RevokedCertificate) []byte {
+	t.Helper()
+	der, err := ca.cert.CreateCRL(rand.Reader, ca.key, revoked, time.Now(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("cannot create CRL: %v", err)
+	}
+	return der
+}
+
+// ocsp returns an OCSP response about cert signed by key.
+// If revokedAt is zero the certificate status is good.
+func (ca *testCA) ocsp(t *testing.T, key *rsa.PrivateKey, cert *x509.Certificate, revokedAt time.Time) []byte {
+	t.Helper()
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	asn1.Unmarshal(ca.cert.RawSubjectPublicKeyInfo, &spki)
+	single := ocspSingleResponse{
+		CertID: ocspCertID{
+			HashAlgorithm:  pkix.AlgorithmIdentifier{Algorithm: hashOIDs[crypto.SHA1], Parameters: asn1.NullRawValue},
+			IssuerNameHash: digest(crypto.SHA1, ca.cert.RawSubject),
+			IssuerKeyHash:  digest(crypto.SHA1, spki.PublicKey.RightAlign()),
+			SerialNumber:   cert.SerialNumber,
+		},
+		ThisUpdate: time.Now(),
+	}
+	if revokedAt.IsZero() {
+		single.Good = true
+	} else {
+		single.Revoked.RevocationTime = revokedAt
+	}
+	tbs, err := asn1.Marshal(ocspResponseData{
+		ResponderID: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: ca.cert.RawSubject},
+		ProducedAt:  time.Now().UTC(),
+		Responses:   []ocspSingleResponse{single},
+	})
+	if err != nil {
+		t.Fatalf("cannot marshal OCSP response data: %v", err)
+	}
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest(crypto.SHA256, tbs))
+	basic, _ := asn1.Marshal(basicOCSPResponse{
+		TBSResponseData:    asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: signatureAlgorithmOIDs[x509.SHA256WithRSA]},
+		Signature:          asn1.BitString{Bytes: sig, BitLength: 8 * len(sig)},
+	})
+	resp, _ := asn1.Marshal(ocspResponse{ResponseBytes: ocspResponseBytes{ResponseType: oidOCSPBasic, Response: basic}})
+	return resp
+}
+
+// embedRevocationValues adds the crls to the XAdES unsigned properties of the signature.
+func embedRevocationValues(t *testing.T, b []byte, crls ...[]byte) []byte {
+	t.Helper()
+	var values string
+	for _, crl := range crls {
+		values += "<xd:EncapsulatedCRLValue>" + base64.StdEncoding.EncodeToString(crl) + "</xd:EncapsulatedCRLValue>"
+	}
+	unsigned := "</xd:SignedProperties><xd:UnsignedProperties><xd:UnsignedSignatureProperties><xd:RevocationValues><xd:CRLValues>" +
+		values + "</xd:CRLValues></xd:RevocationValues></xd:UnsignedSignatureProperties></xd:UnsignedProperties>"
+	return rewriteZip(t, b, func(name string, content []byte) []byte {
+		if name == "_xmlsignatures/sig1.xml" {
+			return []byte(strings.Replace(string(content), "</xd:SignedProperties>", unsigned, 1))
+		}
+		return content
+	})
+}
+
+func TestSignature_VerifyCertificate(t *testing.T) {
+	ca := newTestCA(t)
+	key := newTestRSAKey(t)
+	cert := ca.issue(t, key, 2)
+	signingTime := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	b := newSignedPackageWithCerts(t, key, []*x509.Certificate{cert}, &SignatureOptions{SigningTime: signingTime, XAdES: &XAdESOptions{}})
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	revokedBefore := pkix.RevokedCertificate{SerialNumber: cert.SerialNumber, RevocationTime: signingTime.Add(-time.Minute)}
+	revokedAfter := pkix.RevokedCertificate{SerialNumber: cert.SerialNumber, RevocationTime: time.Now()}
+	tests := []struct {
+		name     string
+		pkg      []byte
+		opts     CertificateOptions
+		wantCode int
+	}{
+		{"untrusted", b, CertificateOptions{Roots: x509.NewCertPool()}, 619},
+		{"expired", b, CertificateOptions{Roots: roots, Time: time.Now().Add(3 * time.Hour)}, 619},
+		{"unknownRevocation", b, CertificateOptions{Roots: roots}, 621},
+		{"allowUnknownRevocation", b, CertificateOptions{Roots: roots, AllowUnknownRevocation: true}, 0},
+		{"crlGood", b, CertificateOptions{Roots: roots, CRLs: [][]byte{ca.crl(t)}}, 0},
+		{"crlRevoked", b, CertificateOptions{Roots: roots, CRLs: [][]byte{ca.crl(t, revokedBefore)}}, 620},
+		{"crlRevokedAfterSigning", b, CertificateOptions{Roots: roots, CRLs: [][]byte{ca.crl(t, revokedAfter)}}, 0},
+		{"crlOtherIssuer", b, CertificateOptions{Roots: roots, CRLs: [][]byte{newTestCA(t).crl(t)}}, 621},
+		{"ocspGood", b, CertificateOptions{Roots: roots, OCSPResponses: [][]byte{ca.ocsp(t, ca.key, cert, time.Time{})}}, 0},
+		{"ocspRevoked", b, CertificateOptions{Roots: roots, OCSPResponses: [][]byte{ca.ocsp(t, ca.key, cert, revokedBefore.RevocationTime)}}, 620},
+		{"ocspWrongSigner", b, CertificateOptions{Roots: roots, OCSPResponses: [][]byte{ca.ocsp(t, key, cert, time.Time{})}}, 621},
+		{"embeddedCRL", embedRevocationValues(t, b, ca.crl(t)), CertificateOptions{Roots: roots}, 0},
+		{"embeddedRevokedCRL", embedRevocationValues(t, b, ca.crl(t, revokedBefore)), CertificateOptions{Roots: roots}, 620},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := NewReader(bytes.NewReader(tt.pkg), int64(len(tt.pkg)))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			chain, err := r.Signatures[0].VerifyCertificate(tt.opts)
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Fatalf("Signature.VerifyCertificate() error = %v", err)
+				}
+				if len(chain) != 2 || !chain[0].Equal(cert) || !chain[1].Equal(ca.cert) {
+					t.Errorf("Signature.VerifyCertificate() chain = %v", chain)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Signature.VerifyCertificate() want error")
+			}
+			if got := err.(*Error).Code(); got != tt.wantCode {
+				t.Errorf("Signature.VerifyCertificate() error code = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
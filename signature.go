@@ -0,0 +1,311 @@
+package opc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"mime"
+	"time"
+
+	"github.com/qmuntal/opc/c14n"
+)
+
+const (
+	signatureOriginRel         = "http://schemas.openxmlformats.org/package/2006/relationships/digital-signature/origin"
+	signatureRel               = "http://schemas.openxmlformats.org/package/2006/relationships/digital-signature/signature"
+	signatureOriginContentType = "application/vnd.openxmlformats-package.digital-signature-origin"
+	signatureContentType       = "application/vnd.openxmlformats-package.digital-signature-xmlsignature+xml"
+	signatureOriginDefaultName = "/_xmlsignatures/origin.sigs"
+	signatureDefaultDir        = "/_xmlsignatures"
+
+	xmldsigNamespace   = "http://www.w3.org/2000/09/xmldsig#"
+	opcSigNamespace    = "http://schemas.openxmlformats.org/package/2006/digital-signature"
+	c14nAlgorithm      = "http://www.w3.org/TR/2001/REC-xml-c14n-20010315"
+	objectReferenceURI = "http://www.w3.org/2000/09/xmldsig#Object"
+	signatureTimeFmt   = "YYYY-MM-DDThh:mm:ssTZD"
+
+	packageSignatureID = "idPackageSignature"
+	packageObjectID    = "idPackageObject"
+	signatureTimeID    = "idSignatureTime"
+)
+
+var digestAlgorithms = map[crypto.Hash]string{
+	crypto.SHA1:   "http://www.w3.org/2000/09/xmldsig#sha1",
+	crypto.SHA256: "http://www.w3.org/2001/04/xmlenc#sha256",
+	crypto.SHA384: "http://www.w3.org/2001/04/xmldsig-more#sha384",
+	crypto.SHA512: "http://www.w3.org/2001/04/xmlenc#sha512",
+}
+
+var rsaSignatureAlgorithms = map[crypto.Hash]string{
+	crypto.SHA1:   "http://www.w3.org/2000/09/xmldsig#rsa-sha1",
+	crypto.SHA256: "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256",
+	crypto.SHA384: "http://www.w3.org/2001/04/xmldsig-more#rsa-sha384",
+	crypto.SHA512: "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512",
+}
+
+var ecdsaSignatureAlgorithms = map[crypto.Hash]string{
+	crypto.SHA1:   "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha1",
+	crypto.SHA256: "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256",
+	crypto.SHA384: "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha384",
+	crypto.SHA512: "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512",
+}
+
+// SignatureOptions defines how a package digital signature is created by Writer.Sign.
+// Defined in ISO/IEC 29500-2 §13.
+type SignatureOptions struct {
+	PartName      string                // Name of the XML signature part. If empty a unique name inside "/_xmlsignatures" is generated.
+	Hash          crypto.Hash           // Algorithm used to digest the references and to compute the signature value. If zero SHA-256 is used.
+	Parts         []string              // Names of the parts signed. If empty all the parts written after calling Sign or copied by NewWriterFromReader are signed.
+	Relationships []SignedRelationships // Relationships signed using the Relationships Transform. If empty and Parts is empty all the relationships written after calling Sign or copied by NewWriterFromReader are signed.
+	SigningTime   time.Time             // Time stored in the SignatureTime property. If zero the time when the Writer is closed is used.
+	XAdES         *XAdESOptions         // If not nil the signature includes the XAdES qualifying properties.
+	Office        *OfficeOptions        // If not nil the signature uses the layout expected by Microsoft Office, which implies XAdES. If Parts and Relationships are empty the Office reference set is signed.
+}
+
+type signatureRequest struct {
+	signer crypto.Signer
+	certs  []*x509.Certificate
+	opts   SignatureOptions
+}
+
+func (s *signatureRequest) signatureMethod() string {
+	switch s.signer.Public().(type) {
+	case *rsa.PublicKey:
+		return rsaSignatureAlgorithms[s.opts.Hash]
+	case *ecdsa.PublicKey:
+		return ecdsaSignatureAlgorithms[s.opts.Hash]
+	}
+	return ""
+}
+
+func (s *signatureRequest) validate() error {
+	if len(s.certs) == 0 || s.certs[0] == nil {
+		return newError(610, s.opts.PartName)
+	}
+	if _, ok := digestAlgorithms[s.opts.Hash]; !ok || !s.opts.Hash.Available() || s.signatureMethod() == "" {
+		return newError(605, s.opts.PartName)
+	}
+	pub, err := x509.MarshalPKIXPublicKey(s.signer.Public())
+	if err != nil {
+		return newError(605, s.opts.PartName)
+	}
+	if !bytes.Equal(pub, s.certs[0].RawSubjectPublicKeyInfo) {
+		return fmt.Errorf("opc: %s: the signer does not match the certificate public key", s.opts.PartName)
+	}
+	return nil
+}
+
+// sign computes the signature value of the canonicalized SignedInfo.
+func (s *signatureRequest) sign(signedInfo []byte) ([]byte, error) {
+	h := s.opts.Hash.New()
+	h.Write(signedInfo)
+	sig, err := s.signer.Sign(rand.Reader, h.Sum(nil), s.opts.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot be signed: %v", s.opts.PartName, err)
+	}
+	if pub, ok := s.signer.Public().(*ecdsa.PublicKey); ok {
+		// XMLDSig expects the raw r||s concatenation instead of the ASN.1 structure.
+		var esig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(sig, &esig); err != nil {
+			return nil, fmt.Errorf("opc: %s: cannot be signed: %v", s.opts.PartName, err)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		raw := make([]byte, 2*size)
+		r, ss := esig.R.Bytes(), esig.S.Bytes()
+		copy(raw[size-len(r):size], r)
+		copy(raw[2*size-len(ss):], ss)
+		sig = raw
+	}
+	return sig, nil
+}
+
+// signedReference holds the information needed to create a Reference element of a signed part.
+type signedReference struct {
+	partName    string
+	contentType string
+	digest      []byte
+	selector    *relationshipSelector // Not nil for relationships parts signed with the Relationships Transform.
+}
+
+func (r *signedReference) uri() string {
+	return fmt.Sprintf("%s?ContentType=%s", r.partName, r.contentType)
+}
+
+// xmlNode is a minimal XML element tree which is serialized in canonical form,
+// as defined in Canonical XML 1.0, so the same bytes can be stored and digested.
+// Attribute names are not qualified except for namespace declarations.
+type xmlNode struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     string
+}
+
+func newXMLNode(name string, attrs ...string) *xmlNode {
+	n := &xmlNode{name: name}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		n.attrs = append(n.attrs, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	return n
+}
+
+func newXMLTextNode(name, text string, attrs ...string) *xmlNode {
+	n := newXMLNode(name, attrs...)
+	n.text = text
+	return n
+}
+
+func (n *xmlNode) add(children ...*xmlNode) *xmlNode {
+	n.children = append(n.children, children...)
+	return n
+}
+
+// canonical returns the canonical form of n as the apex of a document subset,
+// where ns are the namespace declarations inherited from its ancestors.
+func (n *xmlNode) canonical(ns ...xml.Attr) []byte {
+	var b bytes.Buffer
+	enc := c14n.NewEncoder(&b, c14n.Canonical)
+	context := make([]xml.Attr, len(ns))
+	for i, a := range ns {
+		context[i] = xml.Attr{Name: splitQName(a.Name.Local), Value: a.Value}
+	}
+	enc.SetContext(context)
+	// The nodes are built by this package, so they are always well-formed.
+	n.encode(enc)
+	enc.Close()
+	return b.Bytes()
+}
+
+func (n *xmlNode) encode(enc *c14n.Encoder) {
+	start := xml.StartElement{Name: splitQName(n.name)}
+	for _, a := range n.attrs {
+		start.Attr = append(start.Attr, xml.Attr{Name: splitQName(a.Name.Local), Value: a.Value})
+	}
+	enc.EncodeToken(start)
+	enc.EncodeToken(xml.CharData(n.text))
+	for _, c := range n.children {
+		c.encode(enc)
+	}
+	enc.EncodeToken(start.End())
+}
+
+func xmlnsAttr(prefix, uri string) xml.Attr {
+	name := "xmlns"
+	if prefix != "" {
+		name += ":" + prefix
+	}
+	return xml.Attr{Name: xml.Name{Local: name}, Value: uri}
+}
+
+func newDigestNodes(hash crypto.Hash, digest []byte) []*xmlNode {
+	return []*xmlNode{
+		newXMLNode("DigestMethod", "Algorithm", digestAlgorithms[hash]),
+		newXMLTextNode("DigestValue", base64.StdEncoding.EncodeToString(digest)),
+	}
+}
+
+func newPartReferenceNode(hash crypto.Hash, ref *signedReference) *xmlNode {
+	n := newXMLNode("Reference", "URI", ref.uri())
+	if ref.selector != nil {
+		n.add(ref.selector.transformsNode())
+	}
+	return n.add(newDigestNodes(hash, ref.digest)...)
+}
+
+func newSignatureTimeNode(t time.Time) *xmlNode {
+	return newXMLNode("SignatureProperties").add(
+		newXMLNode("SignatureProperty", "Id", signatureTimeID, "Target", "#"+packageSignatureID).add(
+			newXMLNode("mdssi:SignatureTime", "xmlns:mdssi", opcSigNamespace).add(
+				newXMLTextNode("mdssi:Format", signatureTimeFmt),
+				newXMLTextNode("mdssi:Value", t.UTC().Format("2006-01-02T15:04:05Z07:00")),
+			),
+		),
+	)
+}
+
+// buildSignature creates the XML digital signature defined in ISO/IEC 29500-2 §13.2.4
+// covering refs, which shall be ordered as they will appear in the Manifest.
+func (s *signatureRequest) buildSignature(refs []*signedReference, signingTime time.Time) (*xmlNode, error) {
+	manifest := newXMLNode("Manifest")
+	for _, ref := range refs {
+		manifest.add(newPartReferenceNode(s.opts.Hash, ref))
+	}
+	object := newXMLNode("Object", "Id", packageObjectID).add(manifest, newSignatureTimeNode(signingTime))
+
+	dsigNS := xmlnsAttr("", xmldsigNamespace)
+	signedInfo := newXMLNode("SignedInfo").add(
+		newXMLNode("CanonicalizationMethod", "Algorithm", c14nAlgorithm),
+		newXMLNode("SignatureMethod", "Algorithm", s.signatureMethod()),
+		newXMLNode("Reference", "Type", objectReferenceURI, "URI", "#"+packageObjectID).add(
+			newDigestNodes(s.opts.Hash, digest(s.opts.Hash, object.canonical(dsigNS)))...,
+		),
+	)
+	var officeObject *xmlNode
+	if s.opts.Office != nil {
+		officeObject = newOfficeObjectNode(s.opts.Office)
+		signedInfo.add(newXMLNode("Reference", "Type", objectReferenceURI, "URI", "#"+officeObjectID).add(
+			newDigestNodes(s.opts.Hash, digest(s.opts.Hash, officeObject.canonical(dsigNS)))...,
+		))
+	}
+	var qualifyingProps *xmlNode
+	if s.opts.XAdES != nil {
+		var err error
+		if qualifyingProps, err = s.newQualifyingPropertiesNode(signingTime); err != nil {
+			return nil, err
+		}
+		signedProps := qualifyingProps.children[0]
+		signedInfo.add(newXMLNode("Reference", "Type", signedPropertiesType, "URI", "#"+signedPropertiesID).add(
+			newXMLNode("Transforms").add(newXMLNode("Transform", "Algorithm", c14nAlgorithm)),
+		).add(newDigestNodes(s.opts.Hash, digest(s.opts.Hash, signedProps.canonical(dsigNS, xmlnsAttr("xd", xadesNamespace))))...))
+	}
+	value, err := s.sign(signedInfo.canonical(dsigNS))
+	if err != nil {
+		return nil, err
+	}
+	sigValue := newXMLTextNode("SignatureValue", base64.StdEncoding.EncodeToString(value))
+	if qualifyingProps != nil && s.opts.XAdES.TimestampAuthority != nil {
+		if err = s.timestamp(qualifyingProps, sigValue, dsigNS); err != nil {
+			return nil, err
+		}
+	}
+
+	x509Data := newXMLNode("X509Data")
+	for _, cert := range s.certs {
+		x509Data.add(newXMLTextNode("X509Certificate", base64.StdEncoding.EncodeToString(cert.Raw)))
+	}
+	sig := newXMLNode("Signature", "xmlns", xmldsigNamespace, "Id", packageSignatureID)
+	sig.add(
+		signedInfo,
+		sigValue,
+		newXMLNode("KeyInfo").add(x509Data),
+		object,
+	)
+	if officeObject != nil {
+		sig.add(officeObject)
+	}
+	if qualifyingProps != nil {
+		sig.add(newXMLNode("Object").add(qualifyingProps))
+	}
+	return sig, nil
+}
+
+// normalizeContentType applies the process described in ISO/IEC 29500-2 §10.1.2.3
+// so the content type matches the one stored in the content types stream.
+func normalizeContentType(contentType string) string {
+	t, params, _ := mime.ParseMediaType(contentType)
+	return mime.FormatMediaType(t, params)
+}
+
+// relationshipsPartName returns the name of the relationships part
+// associated to the source part, which is "/" for the package relationships.
+func relationshipsPartName(source string) string {
+	return string(PartName(source).RelationshipsPartName())
+}
@@ -0,0 +1,306 @@
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestCertificate(t *testing.T, signer crypto.Signer) *x509.Certificate {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "opc test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, signer.Public(), signer)
+	if err != nil {
+		t.Fatalf("cannot create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("cannot parse certificate: %v", err)
+	}
+	return cert
+}
+
+func newTestRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	return key
+}
+
+func newTestECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("cannot generate key: %v", err)
+	}
+	return key
+}
+
+type testSignatureXML struct {
+	SignedInfo struct {
+		Reference struct {
+			DigestValue string `xml:"DigestValue"`
+		} `xml:"Reference"`
+	} `xml:"SignedInfo"`
+	SignatureValue string `xml:"SignatureValue"`
+	Object         struct {
+		References []struct {
+			URI         string `xml:"URI,attr"`
+			DigestValue string `xml:"DigestValue"`
+		} `xml:"Manifest>Reference"`
+		SignatureTime string `xml:"SignatureProperties>SignatureProperty>SignatureTime>Value"`
+	} `xml:"Object"`
+}
+
+func canonicalSubset(t *testing.T, doc, name string) []byte {
+	t.Helper()
+	start := strings.Index(doc, "<"+name)
+	end := strings.Index(doc, "</"+name+">")
+	if start < 0 || end < 0 {
+		t.Fatalf("element %s not found", name)
+	}
+	subset := doc[start : end+len(name)+3]
+	return []byte(strings.Replace(subset, "<"+name, "<"+name+` xmlns="`+xmldsigNamespace+`"`, 1))
+}
+
+func readZipFile(t *testing.T, zr *zip.Reader, name string) []byte {
+	t.Helper()
+	for _, f := range zr.File {
+		if f.Name == name {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("cannot open %s: %v", name, err)
+			}
+			defer rc.Close()
+			b, _ := ioutil.ReadAll(rc)
+			return b
+		}
+	}
+	t.Fatalf("file %s not found", name)
+	return nil
+}
+
+func TestWriter_Sign(t *testing.T) {
+	rsaKey := newTestRSAKey(t)
+	ecKey := newTestECDSAKey(t)
+	signingTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name   string
+		signer crypto.Signer
+		opts   *SignatureOptions
+		want   []string
+	}{
+		{"rsaAll", rsaKey, nil, []string{
+			"/a.xml?ContentType=a/b", "/b/c.txt?ContentType=text/plain",
+			"/_rels/a.xml.rels?ContentType=" + relationshipContentType, "/_rels/.rels?ContentType=" + relationshipContentType,
+		}},
+		{"ecdsaSelected", ecKey, &SignatureOptions{Hash: crypto.SHA384, Parts: []string{"/a.xml"}, Relationships: []SignedRelationships{{Source: "/"}}}, []string{
+			"/a.xml?ContentType=a/b", "/_rels/.rels?ContentType=" + relationshipContentType,
+		}},
+		{"rsaSha1WithName", rsaKey, &SignatureOptions{Hash: crypto.SHA1, PartName: "/sig/s.xml", Parts: []string{"/b/c.txt"}}, []string{
+			"/b/c.txt?ContentType=text/plain",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			cert := newTestCertificate(t, tt.signer)
+			opts := tt.opts
+			if opts == nil {
+				opts = new(SignatureOptions)
+			}
+			opts.SigningTime = signingTime
+			if err := w.Sign(tt.signer, []*x509.Certificate{cert}, opts); err != nil {
+				t.Fatalf("Writer.Sign() error = %v", err)
+			}
+			pw, _ := w.CreatePart(&Part{Name: "/a.xml", ContentType: "a/b", Relationships: []*Relationship{
+				{ID: "rId1", Type: "text", TargetURI: "/b/c.txt"},
+			}}, CompressionNormal)
+			pw.Write([]byte("<a/>"))
+			pw, _ = w.Create("/b/c.txt", "text/plain")
+			pw.Write([]byte("hello"))
+			w.Relationships = append(w.Relationships, &Relationship{ID: "rId1", Type: "main", TargetURI: "/a.xml"})
+			if err := w.Close(); err != nil {
+				t.Fatalf("Writer.Close() error = %v", err)
+			}
+
+			zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			sigName := "_xmlsignatures/sig1.xml"
+			if opts.PartName != "" {
+				sigName = opts.PartName[1:]
+			}
+			rels := string(readZipFile(t, zr, "_rels/.rels"))
+			if !strings.Contains(rels, signatureOriginRel) {
+				t.Error("Writer.Sign() package relationships without the origin relationship")
+			}
+			if origin := readZipFile(t, zr, "_xmlsignatures/origin.sigs"); len(origin) != 0 {
+				t.Error("Writer.Sign() origin part shall be empty")
+			}
+			if originRels := string(readZipFile(t, zr, "_xmlsignatures/_rels/origin.sigs.rels")); !strings.Contains(originRels, "/"+sigName) {
+				t.Error("Writer.Sign() origin part does not target the signature part")
+			}
+			if ct := string(readZipFile(t, zr, "[Content_Types].xml")); !strings.Contains(ct, signatureContentType) || !strings.Contains(ct, signatureOriginContentType) {
+				t.Error("Writer.Sign() signature content types not registered")
+			}
+
+			doc := string(readZipFile(t, zr, sigName))
+			var sig testSignatureXML
+			if err := xml.Unmarshal([]byte(doc), &sig); err != nil {
+				t.Fatalf("signature cannot be decoded: %v", err)
+			}
+			if sig.Object.SignatureTime != "2020-01-02T03:04:05Z" {
+				t.Errorf("Writer.Sign() signing time = %s", sig.Object.SignatureTime)
+			}
+			hash := opts.Hash
+			if hash == 0 {
+				hash = crypto.SHA256
+			}
+			if len(sig.Object.References) != len(tt.want) {
+				t.Fatalf("Writer.Sign() references = %d, want %d", len(sig.Object.References), len(tt.want))
+			}
+			for i, ref := range sig.Object.References {
+				if ref.URI != tt.want[i] {
+					t.Errorf("Writer.Sign() reference = %s, want %s", ref.URI, tt.want[i])
+				}
+				content := readZipFile(t, zr, strings.SplitN(ref.URI, "?", 2)[0][1:])
+				if strings.Contains(ref.URI, "_rels") {
+					rels, _ := decodeRelationships(bytes.NewReader(content), ref.URI)
+					sel := new(relationshipSelector)
+					for _, r := range rels {
+						sel.ids = append(sel.ids, r.ID)
+					}
+					content = sel.transform(rels)
+				}
+				h := hash.New()
+				h.Write(content)
+				if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != ref.DigestValue {
+					t.Errorf("Writer.Sign() reference %s digest = %s, want %s", ref.URI, ref.DigestValue, got)
+				}
+			}
+			h := hash.New()
+			h.Write(canonicalSubset(t, doc, "Object"))
+			if got := base64.StdEncoding.EncodeToString(h.Sum(nil)); got != sig.SignedInfo.Reference.DigestValue {
+				t.Errorf("Writer.Sign() object digest = %s, want %s", sig.SignedInfo.Reference.DigestValue, got)
+			}
+			h = hash.New()
+			h.Write(canonicalSubset(t, doc, "SignedInfo"))
+			value, _ := base64.StdEncoding.DecodeString(sig.SignatureValue)
+			switch pub := tt.signer.Public().(type) {
+			case *rsa.PublicKey:
+				if err := rsa.VerifyPKCS1v15(pub, hash, h.Sum(nil), value); err != nil {
+					t.Errorf("Writer.Sign() invalid signature value: %v", err)
+				}
+			case *ecdsa.PublicKey:
+				r, s := new(big.Int).SetBytes(value[:len(value)/2]), new(big.Int).SetBytes(value[len(value)/2:])
+				if !ecdsa.Verify(pub, h.Sum(nil), r, s) {
+					t.Error("Writer.Sign() invalid signature value")
+				}
+			}
+		})
+	}
+}
+
+func TestWriter_Sign_Error(t *testing.T) {
+	rsaKey := newTestRSAKey(t)
+	cert := newTestCertificate(t, rsaKey)
+	otherCert := newTestCertificate(t, newTestECDSAKey(t))
+	tests := []struct {
+		name     string
+		certs    []*x509.Certificate
+		opts     *SignatureOptions
+		wantCode int
+	}{
+		{"noCertificate", nil, nil, 610},
+		{"unsupportedHash", []*x509.Certificate{cert}, &SignatureOptions{Hash: crypto.MD5}, 605},
+		{"invalidName", []*x509.Certificate{cert}, &SignatureOptions{PartName: "sig.xml"}, 104},
+		{"certificateMismatch", []*x509.Certificate{otherCert}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := NewWriter(&bytes.Buffer{}).Sign(rsaKey, tt.certs, tt.opts)
+			if err == nil {
+				t.Fatal("Writer.Sign() want error")
+			}
+			if tt.wantCode == 0 {
+				return
+			}
+			if got := err.(*Error).Code(); got != tt.wantCode {
+				t.Errorf("Writer.Sign() error code = %v, want %v", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestWriter_Close_Signature(t *testing.T) {
+	rsaKey := newTestRSAKey(t)
+	cert := newTestCertificate(t, rsaKey)
+	tests := []struct {
+		name     string
+		opts     *SignatureOptions
+		wantCode int
+	}{
+		{"missingPart", &SignatureOptions{Parts: []string{"/missing.xml"}}, 609},
+		{"partBeforeSign", &SignatureOptions{Parts: []string{"/before.xml"}}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWriter(&bytes.Buffer{})
+			w.Create("/before.xml", "a/b")
+			if err := w.Sign(rsaKey, []*x509.Certificate{cert}, tt.opts); err != nil {
+				t.Fatalf("Writer.Sign() error = %v", err)
+			}
+			err := w.Close()
+			if err == nil {
+				t.Fatal("Writer.Close() want error")
+			}
+			if tt.wantCode == 0 {
+				return
+			}
+			if got := err.(*Error).Code(); got != tt.wantCode {
+				t.Errorf("Writer.Close() error code = %v, want %v", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func Test_relationshipsPartName(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"/", "/_rels/.rels"},
+		{"/a.xml", "/_rels/a.xml.rels"},
+		{"/b/c/a.xml", "/b/c/_rels/a.xml.rels"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			if got := relationshipsPartName(tt.source); got != tt.want {
+				t.Errorf("relationshipsPartName() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
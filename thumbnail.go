@@ -0,0 +1,183 @@
+package opc
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+)
+
+const (
+	thumbnailRel             = "http://schemas.openxmlformats.org/package/2006/relationships/metadata/thumbnail"
+	thumbnailDefaultName     = "/props/thumbnail"
+	partThumbnailsDefaultDir = "/props/thumbnails"
+)
+
+var thumbnailExtensions = map[string]string{
+	"image/bmp":     ".bmp",
+	"image/gif":     ".gif",
+	"image/jpeg":    ".jpeg",
+	"image/png":     ".png",
+	"image/svg+xml": ".svg",
+	"image/tiff":    ".tiff",
+	"image/webp":    ".webp",
+	"image/x-emf":   ".emf",
+	"image/x-wmf":   ".wmf",
+}
+
+func isImageContentType(contentType string) bool {
+	t, _, err := mime.ParseMediaType(contentType)
+	return err == nil && strings.HasPrefix(t, "image/")
+}
+
+// findThumbnailRelationship returns the thumbnail relationship of rs, if any.
+// ISO/IEC 29500-2 M12.1 only allows one thumbnail relationship per source.
+func findThumbnailRelationship(source string, rs []*Relationship) (*Relationship, error) {
+	var thumb *Relationship
+	for _, r := range rs {
+		if !strings.EqualFold(r.Type, thumbnailRel) {
+			continue
+		}
+		if thumb != nil {
+			return nil, newErrorRelationship(502, source, r.ID)
+		}
+		thumb = r
+	}
+	return thumb, nil
+}
+
+// SetThumbnail creates a part holding the image read from r and relates it to the package
+// as the package thumbnail, as defined in ISO/IEC 29500-2 §12.
+// The contentType shall be an image content type and the package shall not have another thumbnail.
+// The thumbnail part is named "/props/thumbnail" followed by the extension of the content type.
+func (w *Writer) SetThumbnail(contentType string, r io.Reader) error {
+	rel, err := w.createThumbnail("/", w.Relationships, thumbnailDefaultName, contentType, r)
+	if err != nil {
+		return err
+	}
+	w.Relationships = append(w.Relationships, rel)
+	return nil
+}
+
+// SetPartThumbnail creates a part holding the image read from r and relates it to the part called name
+// as its thumbnail, as defined in ISO/IEC 29500-2 §12.
+// The contentType shall be an image content type and the part shall not have another thumbnail.
+// As the thumbnail is added to the part relationships, name shall be the last part created with
+// Create or CreatePart, or a part created with CreateInterleaved whose PartWriter is not closed.
+// The thumbnail part is named after the part, inside the "/props/thumbnails" folder.
+func (w *Writer) SetPartThumbnail(name, contentType string, r io.Reader) error {
+	part := w.openPart(name)
+	if part == nil {
+		return fmt.Errorf("opc: %s: cannot set the thumbnail: the part relationships have already been written", name)
+	}
+	rel, err := w.createThumbnail(part.Name, part.Relationships, partThumbnailsDefaultDir+part.Name, contentType, r)
+	if err != nil {
+		return err
+	}
+	part.Relationships = append(part.Relationships, rel)
+	return nil
+}
+
+// openPart returns the part called name if its relationships have not been written yet.
+func (w *Writer) openPart(name string) *Part {
+	if w.last != nil && PartName(w.last.Name).Equal(PartName(name)) {
+		return w.last
+	}
+	for _, pw := range w.interleaved {
+		if !pw.closed && PartName(pw.part.Name).Equal(PartName(name)) {
+			return pw.part
+		}
+	}
+	return nil
+}
+
+// createThumbnail writes the thumbnail part of source and returns the relationship that targets it.
+func (w *Writer) createThumbnail(source string, rels []*Relationship, name, contentType string, r io.Reader) (*Relationship, error) {
+	if !isImageContentType(contentType) {
+		return nil, newError(501, source)
+	}
+	thumb, err := findThumbnailRelationship(source, rels)
+	if err != nil {
+		return nil, err
+	}
+	if thumb != nil {
+		return nil, newErrorRelationship(502, source, thumb.ID)
+	}
+	t, _, _ := mime.ParseMediaType(contentType)
+	part := &Part{Name: name + thumbnailExtensions[t], ContentType: contentType}
+	tw, err := w.addToPackage(part, CompressionNone)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(tw, r); err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot be written: %v", part.Name, err)
+	}
+	return &Relationship{ID: newRelationshipID(rels), Type: thumbnailRel, TargetURI: part.Name}, nil
+}
+
+// addThumbnailSource records the relationships of source if they contain a thumbnail,
+// so its target is validated when the Writer is closed.
+func (w *Writer) addThumbnailSource(source string, rels []*Relationship) {
+	if rel, _ := findThumbnailRelationship(source, rels); rel != nil {
+		w.thumbnails = append(w.thumbnails, &Part{Name: source, Relationships: rels})
+	}
+}
+
+// validateThumbnails checks the thumbnail relationships written to the package
+// once the content types of all the parts are known.
+func (w *Writer) validateThumbnails() error {
+	for _, t := range w.thumbnails {
+		if err := validateThumbnails(t.Name, t.Relationships, &w.p.contentTypes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Thumbnail returns the package thumbnail, or nil if the package does not have one.
+func (r *Reader) Thumbnail() *File {
+	return r.thumbnail("/", r.Relationships)
+}
+
+// PartThumbnail returns the thumbnail of the part called name, or nil if the part does not have one.
+func (r *Reader) PartThumbnail(name string) *File {
+	if f := r.file(name); f != nil {
+		return r.thumbnail(f.Name, f.Relationships)
+	}
+	return nil
+}
+
+func (r *Reader) thumbnail(source string, rels []*Relationship) *File {
+	rel, _ := findThumbnailRelationship(source, rels)
+	if rel == nil {
+		return nil
+	}
+	return r.Target(source, rel)
+}
+
+// validateThumbnails checks the thumbnail relationships of the package and its parts.
+func (r *Reader) validateThumbnails(ct *contentTypes) error {
+	if err := validateThumbnails("/", r.Relationships, ct); err != nil {
+		return err
+	}
+	for _, f := range r.Files {
+		if err := validateThumbnails(f.Name, f.Relationships, ct); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateThumbnails checks that source has at most one thumbnail relationship
+// and that it targets a part with an image content type.
+func validateThumbnails(source string, rels []*Relationship, ct *contentTypes) error {
+	rel, err := findThumbnailRelationship(source, rels)
+	if err != nil || rel == nil || rel.TargetMode != ModeInternal {
+		return err
+	}
+	name := NormalizePartName(ResolveRelationship(source, rel.TargetURI))
+	if t, err := ct.findType(name); err == nil && !isImageContentType(t) {
+		return newErrorRelationship(501, source, rel.ID)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package opc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestWriter_SetThumbnail(t *testing.T) {
+	tests := []struct {
+		name     string
+		write    func(w *Writer) error
+		wantCode int
+	}{
+		{"package", func(w *Writer) error {
+			return w.SetThumbnail("image/png", strings.NewReader("png"))
+		}, 0},
+		{"part", func(w *Writer) error {
+			w.Create("/a.xml", "application/xml")
+			return w.SetPartThumbnail("/A.XML", "image/jpeg", strings.NewReader("jpeg"))
+		}, 0},
+		{"interleaved", func(w *Writer) error {
+			pw, _ := w.CreateInterleaved(&Part{Name: "/a.xml", ContentType: "application/xml"}, CompressionNormal)
+			w.Create("/b.xml", "application/xml")
+			if err := w.SetPartThumbnail("/a.xml", "image/gif", strings.NewReader("gif")); err != nil {
+				return err
+			}
+			return pw.Close()
+		}, 0},
+		{"notImage", func(w *Writer) error {
+			return w.SetThumbnail("application/xml", strings.NewReader("<a/>"))
+		}, 501},
+		{"twice", func(w *Writer) error {
+			w.SetThumbnail("image/png", strings.NewReader("png"))
+			return w.SetThumbnail("image/jpeg", strings.NewReader("jpeg"))
+		}, 502},
+		{"twicePart", func(w *Writer) error {
+			w.Create("/a.xml", "application/xml")
+			w.SetPartThumbnail("/a.xml", "image/png", strings.NewReader("png"))
+			return w.SetPartThumbnail("/a.xml", "image/jpeg", strings.NewReader("jpeg"))
+		}, 502},
+		{"manualNotImage", func(w *Writer) error {
+			w.Create("/a.xml", "application/xml")
+			w.Relationships = append(w.Relationships, &Relationship{ID: "rId1", Type: thumbnailRel, TargetURI: "/a.xml"})
+			return nil
+		}, 501},
+		{"manualTwice", func(w *Writer) error {
+			w.SetThumbnail("image/png", strings.NewReader("png"))
+			w.Relationships = append(w.Relationships, &Relationship{ID: "rId2", Type: thumbnailRel, TargetURI: "/other.png"})
+			return nil
+		}, 502},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			err := tt.write(w)
+			if err == nil {
+				err = w.Close()
+			}
+			if tt.wantCode != 0 {
+				if err == nil {
+					t.Fatal("Writer.SetThumbnail() want error")
+				}
+				if got := err.(*Error).Code(); got != tt.wantCode {
+					t.Errorf("Writer.SetThumbnail() error code = %d, want %d", got, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Writer.SetThumbnail() error = %v", err)
+			}
+			if _, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len())); err != nil {
+				t.Errorf("NewReader() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestWriter_SetPartThumbnail_Written(t *testing.T) {
+	w := NewWriter(new(bytes.Buffer))
+	w.Create("/a.xml", "application/xml")
+	w.Create("/b.xml", "application/xml")
+	if err := w.SetPartThumbnail("/a.xml", "image/png", strings.NewReader("png")); err == nil {
+		t.Error("Writer.SetPartThumbnail() want error for a part whose relationships are written")
+	}
+}
+
+func TestReader_Thumbnail(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.SetThumbnail("image/png", strings.NewReader("package"))
+	pw, _ := w.Create("/docs/a.xml", "application/xml")
+	pw.Write([]byte("<a/>"))
+	w.SetPartThumbnail("/docs/a.xml", "image/jpeg", strings.NewReader("part"))
+	w.Create("/docs/b.xml", "application/xml")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	tests := []struct {
+		name        string
+		file        *File
+		wantName    string
+		wantType    string
+		wantContent string
+	}{
+		{"package", r.Thumbnail(), "/props/thumbnail.png", "image/png", "package"},
+		{"part", r.PartThumbnail("/DOCS/A.XML"), "/props/thumbnails/docs/a.xml.jpeg", "image/jpeg", "part"},
+		{"none", r.PartThumbnail("/docs/b.xml"), "", "", ""},
+		{"missing", r.PartThumbnail("/docs/c.xml"), "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.wantName == "" {
+				if tt.file != nil {
+					t.Errorf("Reader.Thumbnail() = %v, want nil", tt.file.Name)
+				}
+				return
+			}
+			if tt.file == nil {
+				t.Fatal("Reader.Thumbnail() = nil")
+			}
+			if tt.file.Name != tt.wantName || tt.file.ContentType != tt.wantType {
+				t.Errorf("Reader.Thumbnail() = (%s, %s), want (%s, %s)", tt.file.Name, tt.file.ContentType, tt.wantName, tt.wantType)
+			}
+			rc, _ := tt.file.Open()
+			got, _ := ioutil.ReadAll(rc)
+			rc.Close()
+			if string(got) != tt.wantContent {
+				t.Errorf("File.Open() content = %s, want %s", got, tt.wantContent)
+			}
+		})
+	}
+}
+
+func TestNewReader_Thumbnail(t *testing.T) {
+	ct := zipItem{"[Content_Types].xml", new(cTypeBuilder).withDefault("application/xml", "xml").withDefault("image/png", "png").withDefault(relationshipContentType, "rels").String()}
+	tests := []struct {
+		name     string
+		items    []zipItem
+		wantCode int
+	}{
+		{"valid", []zipItem{ct,
+			{"_rels/.rels", new(relsBuilder).withRel("rId1", thumbnailRel, "/a.png").String()},
+			{"a.png", "png"},
+		}, 0},
+		{"external", []zipItem{ct,
+			{"_rels/.rels", new(relsBuilder).withRelMode("rId1", thumbnailRel, "http://a.com/a.xml", "External").String()},
+		}, 0},
+		{"notImage", []zipItem{ct,
+			{"_rels/.rels", new(relsBuilder).withRel("rId1", thumbnailRel, "/a.xml").String()},
+			{"a.xml", "<a/>"},
+		}, 501},
+		{"twice", []zipItem{ct,
+			{"_rels/.rels", new(relsBuilder).withRel("rId1", thumbnailRel, "/a.png").withRel("rId2", thumbnailRel, "/b.png").String()},
+			{"a.png", "png"},
+			{"b.png", "png"},
+		}, 502},
+		{"partNotImage", []zipItem{ct,
+			{"docs/_rels/a.xml.rels", new(relsBuilder).withRel("rId1", thumbnailRel, "b.xml").String()},
+			{"docs/a.xml", "<a/>"},
+			{"docs/b.xml", "<b/>"},
+		}, 501},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestZip(t, tt.items...)
+			_, err := NewReader(bytes.NewReader(b), int64(len(b)))
+			if tt.wantCode == 0 {
+				if err != nil {
+					t.Errorf("NewReader() error = %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("NewReader() want error")
+			}
+			if got := err.(*Error).Code(); got != tt.wantCode {
+				t.Errorf("NewReader() error code = %d, want %d", got, tt.wantCode)
+			}
+		})
+	}
+}
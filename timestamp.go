@@ -0,0 +1,327 @@
+package opc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// TimestampAuthority issues RFC 3161 time-stamp tokens.
+// It is used to add a XAdES signature timestamp to package signatures.
+type TimestampAuthority interface {
+	// Timestamp returns the DER encoded TimeStampToken whose message imprint
+	// is digest, which has been computed using hash.
+	Timestamp(digest []byte, hash crypto.Hash) ([]byte, error)
+}
+
+// HTTPTimestampAuthority is a TimestampAuthority that requests the tokens to a
+// RFC 3161 time-stamping server using the HTTP transport defined in RFC 3161 §3.4.
+type HTTPTimestampAuthority struct {
+	URL    string       // The URL of the time-stamping service.
+	Client *http.Client // The client used to send the requests. If nil http.DefaultClient is used.
+}
+
+// Timestamp requests a time-stamp token to the server.
+func (t *HTTPTimestampAuthority) Timestamp(digest []byte, hash crypto.Hash) ([]byte, error) {
+	oid, ok := hashOIDs[hash]
+	if !ok {
+		return nil, fmt.Errorf("opc: unsupported timestamp hash %v", hash)
+	}
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	req, err := asn1.Marshal(timeStampReq{
+		Version:        1,
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue}, HashedMessage: digest},
+		Nonce:          nonce,
+		CertReq:        true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	client := t.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(t.URL, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("opc: timestamp request failed: %s", resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var tsResp timeStampResp
+	if _, err = asn1.Unmarshal(body, &tsResp); err != nil {
+		return nil, fmt.Errorf("opc: invalid timestamp response: %v", err)
+	}
+	// 0 is granted and 1 is granted with modifications.
+	if tsResp.Status.Status > 1 || len(tsResp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("opc: timestamp request rejected with status %d", tsResp.Status.Status)
+	}
+	info, err := parseTimeStampToken(tsResp.TimeStampToken.FullBytes)
+	if err != nil {
+		return nil, err
+	}
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, errors.New("opc: timestamp response nonce does not match the request")
+	}
+	return tsResp.TimeStampToken.FullBytes, nil
+}
+
+var (
+	oidSignedData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo           = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidAttrContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidAttrMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+)
+
+var hashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+	crypto.SHA1:   {1, 3, 14, 3, 2, 26},
+	crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+	crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+	crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+}
+
+func hashByOID(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	for h, o := range hashOIDs {
+		if o.Equal(oid) && h.Available() {
+			return h, true
+		}
+	}
+	return 0, false
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     []byte `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time     `asn1:"generalized"`
+	Accuracy       accuracy      `asn1:"optional"`
+	Ordering       bool          `asn1:"optional"`
+	Nonce          *big.Int      `asn1:"optional"`
+	TSA            asn1.RawValue `asn1:"optional,explicit,tag:0"`
+	Extensions     asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+// timeStampToken is a parsed RFC 3161 TimeStampToken.
+type timeStampToken struct {
+	tstInfo
+	certs  []*x509.Certificate
+	signer signerInfo
+	raw    []byte // The DER encoded TSTInfo.
+}
+
+func parseTimeStampToken(der []byte) (*timeStampToken, error) {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err != nil || len(rest) > 0 || !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("opc: the timestamp token is not a CMS SignedData")
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("opc: invalid timestamp token: %v", err)
+	}
+	if !sd.EncapContentInfo.EContentType.Equal(oidTSTInfo) || len(sd.SignerInfos) != 1 {
+		return nil, errors.New("opc: the timestamp token does not contain a signed TSTInfo")
+	}
+	t := &timeStampToken{signer: sd.SignerInfos[0], raw: sd.EncapContentInfo.EContent}
+	if _, err := asn1.Unmarshal(t.raw, &t.tstInfo); err != nil {
+		return nil, fmt.Errorf("opc: invalid timestamp TSTInfo: %v", err)
+	}
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("opc: invalid timestamp certificates: %v", err)
+		}
+		t.certs = certs
+	}
+	return t, nil
+}
+
+// matches reports whether the message imprint of t is the digest of data.
+func (t *timeStampToken) matches(data []byte) bool {
+	hash, ok := hashByOID(t.MessageImprint.HashAlgorithm.Algorithm)
+	if !ok {
+		return false
+	}
+	h := hash.New()
+	h.Write(data)
+	return bytes.Equal(h.Sum(nil), t.MessageImprint.HashedMessage)
+}
+
+// signerCertificate returns the embedded certificate identified by the signer information.
+func (t *timeStampToken) signerCertificate() *x509.Certificate {
+	sid := t.signer.SID
+	for _, c := range t.certs {
+		if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+			if bytes.Equal(sid.Bytes, c.SubjectKeyId) {
+				return c
+			}
+			continue
+		}
+		var ias issuerAndSerialNumber
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err == nil &&
+			bytes.Equal(ias.Issuer.FullBytes, c.RawIssuer) && ias.SerialNumber.Cmp(c.SerialNumber) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+// verify checks the signature of the TSA over the TSTInfo.
+func (t *timeStampToken) verify() error {
+	cert := t.signerCertificate()
+	if cert == nil {
+		return errors.New("opc: the timestamp token does not contain the TSA certificate")
+	}
+	hash, ok := hashByOID(t.signer.DigestAlgorithm.Algorithm)
+	if !ok {
+		return errors.New("opc: unsupported timestamp digest algorithm")
+	}
+	h := hash.New()
+	h.Write(t.raw)
+	signed := t.raw
+	if len(t.signer.SignedAttrs.FullBytes) > 0 {
+		// The signature covers the DER encoding of the signed attributes as a SET OF.
+		if !t.hasMessageDigest(h.Sum(nil)) {
+			return errors.New("opc: the timestamp message digest does not match the TSTInfo")
+		}
+		signed = append([]byte{0x31}, t.signer.SignedAttrs.FullBytes[1:]...)
+	}
+	alg := x509SignatureAlgorithm(cert.PublicKeyAlgorithm, hash)
+	if alg == x509.UnknownSignatureAlgorithm {
+		return errors.New("opc: unsupported timestamp signature algorithm")
+	}
+	if err := cert.CheckSignature(alg, signed, t.signer.Signature); err != nil {
+		return fmt.Errorf("opc: invalid timestamp signature: %v", err)
+	}
+	return nil
+}
+
+func (t *timeStampToken) hasMessageDigest(digest []byte) bool {
+	rest := t.signer.SignedAttrs.Bytes
+	for len(rest) > 0 {
+		var attr attribute
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &attr); err != nil {
+			return false
+		}
+		if attr.Type.Equal(oidAttrMessageDigest) && len(attr.Values) == 1 {
+			var value []byte
+			_, err = asn1.Unmarshal(attr.Values[0].FullBytes, &value)
+			return err == nil && bytes.Equal(value, digest)
+		}
+	}
+	return false
+}
+
+func x509SignatureAlgorithm(pub x509.PublicKeyAlgorithm, hash crypto.Hash) x509.SignatureAlgorithm {
+	switch pub {
+	case x509.RSA:
+		switch hash {
+		case crypto.SHA1:
+			return x509.SHA1WithRSA
+		case crypto.SHA256:
+			return x509.SHA256WithRSA
+		case crypto.SHA384:
+			return x509.SHA384WithRSA
+		case crypto.SHA512:
+			return x509.SHA512WithRSA
+		}
+	case x509.ECDSA:
+		switch hash {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512
+		}
+	}
+	return x509.UnknownSignatureAlgorithm
+}
@@ -0,0 +1,181 @@
+package opc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// testTSA is an in-process RFC 3161 time-stamping authority.
+type testTSA struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+	time time.Time
+}
+
+func newTestTSA(t *testing.T) *testTSA {
+	t.Helper()
+	key := newTestRSAKey(t)
+	return &testTSA{key: key, cert: newTestCertificate(t, key), time: time.Date(2021, 5, 6, 7, 8, 10, 0, time.UTC)}
+}
+
+func (tsa *testTSA) Timestamp(digest []byte, hash crypto.Hash) ([]byte, error) {
+	return tsa.token(digest, hash, nil)
+}
+
+func (tsa *testTSA) token(digest []byte, hash crypto.Hash, nonce *big.Int) ([]byte, error) {
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: messageImprint{HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: hashOIDs[hash]}, HashedMessage: digest},
+		SerialNumber:   big.NewInt(1),
+		GenTime:        tsa.time,
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	infoDigest := sha256.Sum256(info)
+	var attrs []byte
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value interface{}
+	}{{oidAttrContentType, oidTSTInfo}, {oidAttrMessageDigest, infoDigest[:]}} {
+		v, _ := asn1.Marshal(a.value)
+		b, err := asn1.Marshal(attribute{Type: a.oid, Values: []asn1.RawValue{{FullBytes: v}}})
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, b...)
+	}
+	signed, _ := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	signedDigest := sha256.Sum256(signed)
+	sig, err := rsa.SignPKCS1v15(rand.Reader, tsa.key, crypto.SHA256, signedDigest[:])
+	if err != nil {
+		return nil, err
+	}
+	sid, _ := asn1.Marshal(issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: tsa.cert.RawIssuer}, SerialNumber: tsa.cert.SerialNumber})
+	sha256ID := pkix.AlgorithmIdentifier{Algorithm: hashOIDs[crypto.SHA256]}
+	sd, err := asn1.Marshal(signedData{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{sha256ID},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidTSTInfo, EContent: info},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: tsa.cert.Raw},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: sid},
+			DigestAlgorithm:    sha256ID,
+			SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}},
+			Signature:          sig,
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	// The explicit tag of contentInfo is not applied when marshaling raw values.
+	return asn1.Marshal(struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue
+	}{oidSignedData, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd}})
+}
+
+func Test_parseTimeStampToken(t *testing.T) {
+	tsa := newTestTSA(t)
+	data := []byte("<SignatureValue>abc</SignatureValue>")
+	d := sha256.Sum256(data)
+	token, err := tsa.Timestamp(d[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("testTSA.Timestamp() error = %v", err)
+	}
+	got, err := parseTimeStampToken(token)
+	if err != nil {
+		t.Fatalf("parseTimeStampToken() error = %v", err)
+	}
+	if !got.GenTime.Equal(tsa.time) {
+		t.Errorf("parseTimeStampToken() time = %v, want %v", got.GenTime, tsa.time)
+	}
+	if !got.matches(data) || got.matches([]byte("other")) {
+		t.Error("timeStampToken.matches() unexpected result")
+	}
+	if err = got.verify(); err != nil {
+		t.Errorf("timeStampToken.verify() error = %v", err)
+	}
+	got.raw = append([]byte(nil), got.raw...)
+	got.raw[len(got.raw)-1] ^= 0xff
+	if err = got.verify(); err == nil {
+		t.Error("timeStampToken.verify() want error for a modified TSTInfo")
+	}
+	if _, err = parseTimeStampToken([]byte{0x30, 0x00}); err == nil {
+		t.Error("parseTimeStampToken() want error")
+	}
+}
+
+func TestHTTPTimestampAuthority_Timestamp(t *testing.T) {
+	tsa := newTestTSA(t)
+	tests := []struct {
+		name       string
+		status     int
+		tsStatus   int
+		wrongNonce bool
+		wantErr    bool
+	}{
+		{"granted", http.StatusOK, 0, false, false},
+		{"rejected", http.StatusOK, 2, false, true},
+		{"httpError", http.StatusInternalServerError, 0, false, true},
+		{"nonceMismatch", http.StatusOK, 0, true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") != "application/timestamp-query" {
+					t.Errorf("Content-Type = %s", r.Header.Get("Content-Type"))
+				}
+				body, _ := ioutil.ReadAll(r.Body)
+				var req timeStampReq
+				if _, err := asn1.Unmarshal(body, &req); err != nil {
+					t.Errorf("invalid request: %v", err)
+				}
+				if tt.wrongNonce {
+					req.Nonce = new(big.Int).Add(req.Nonce, big.NewInt(1))
+				}
+				resp := timeStampResp{Status: pkiStatusInfo{Status: tt.tsStatus}}
+				if tt.tsStatus == 0 {
+					token, _ := tsa.token(req.MessageImprint.HashedMessage, crypto.SHA256, req.Nonce)
+					resp.TimeStampToken = asn1.RawValue{FullBytes: token}
+				}
+				b, _ := asn1.Marshal(resp)
+				w.WriteHeader(tt.status)
+				w.Write(b)
+			}))
+			defer srv.Close()
+			digest := sha256.Sum256([]byte("data"))
+			a := &HTTPTimestampAuthority{URL: srv.URL}
+			token, err := a.Timestamp(digest[:], crypto.SHA256)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("HTTPTimestampAuthority.Timestamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			info, err := parseTimeStampToken(token)
+			if err != nil {
+				t.Fatalf("parseTimeStampToken() error = %v", err)
+			}
+			if !bytes.Equal(info.MessageImprint.HashedMessage, digest[:]) {
+				t.Error("HTTPTimestampAuthority.Timestamp() message imprint mismatch")
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package opc
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+const relationshipTransformAlgorithm = "http://schemas.openxmlformats.org/package/2006/RelationshipTransform"
+
+// SignedRelationships selects the relationships of a source part that are covered by a signature.
+// The relationships part is signed using the Relationships Transform defined in ISO/IEC 29500-2 §13.2.4.24,
+// so the signature is not invalidated when relationships that are not selected are modified.
+// A relationship is selected if its ID is in IDs or its type is in Types.
+// If both IDs and Types are empty all the relationships of the source are selected.
+type SignedRelationships struct {
+	Source string   // The source part name, "/" for the package relationships.
+	IDs    []string // The identifiers of the selected relationships, stored as SourceId.
+	Types  []string // The types of the selected relationships, stored as SourceType.
+}
+
+// relationshipSelector holds the parameters of a Relationships Transform.
+type relationshipSelector struct {
+	ids   []string
+	types []string
+}
+
+func (s *relationshipSelector) selects(r *Relationship) bool {
+	if isValueInList(r.ID, s.ids) {
+		return true
+	}
+	for _, t := range s.types {
+		if strings.EqualFold(r.Type, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// transform returns the canonical form of the selected relationships sorted by ID,
+// which is the output of the Relationships Transform followed by a canonicalization transform.
+func (s *relationshipSelector) transform(rs []*Relationship) []byte {
+	selected := make([]*Relationship, 0, len(rs))
+	for _, r := range rs {
+		if s.selects(r) {
+			selected = append(selected, r)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool { return selected[i].ID < selected[j].ID })
+	root := newXMLNode("Relationships", "xmlns", relationshipsNamespace)
+	for _, r := range selected {
+		mode := "Internal"
+		if r.TargetMode == ModeExternal {
+			mode = externalMode
+		}
+		root.add(newXMLNode("Relationship", "Id", r.ID, "Type", r.Type, "Target", r.TargetURI, "TargetMode", mode))
+	}
+	return root.canonical()
+}
+
+func (s *relationshipSelector) transformsNode() *xmlNode {
+	t := newXMLNode("Transform", "Algorithm", relationshipTransformAlgorithm)
+	for _, id := range s.ids {
+		t.add(newXMLNode("mdssi:RelationshipReference", "xmlns:mdssi", opcSigNamespace, "SourceId", id))
+	}
+	for _, typ := range s.types {
+		t.add(newXMLNode("mdssi:RelationshipsGroupReference", "xmlns:mdssi", opcSigNamespace, "SourceType", typ))
+	}
+	return newXMLNode("Transforms").add(t, newXMLNode("Transform", "Algorithm", c14nAlgorithm))
+}
+
+// newRelationshipSelector returns the selector defined by the children of a Transform element.
+func newRelationshipSelector(transform *xmlElement) *relationshipSelector {
+	s := new(relationshipSelector)
+	for _, ref := range transform.elements(opcSigNamespace, "RelationshipReference") {
+		if id, ok := ref.attr("SourceId"); ok {
+			s.ids = append(s.ids, id)
+		}
+	}
+	for _, ref := range transform.elements(opcSigNamespace, "RelationshipsGroupReference") {
+		if typ, ok := ref.attr("SourceType"); ok {
+			s.types = append(s.types, typ)
+		}
+	}
+	return s
+}
+
+// transformRelationships applies the Relationships Transform to the relationships part content b.
+func (s *relationshipSelector) transformRelationships(b []byte, partName string) ([]byte, error) {
+	rs, err := decodeRelationships(bytes.NewReader(b), partName)
+	if err != nil {
+		return nil, err
+	}
+	return s.transform(rs), nil
+}
@@ -0,0 +1,116 @@
+package opc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"strings"
+	"testing"
+)
+
+func Test_relationshipSelector_transform(t *testing.T) {
+	rels := []*Relationship{
+		{ID: "rId3", Type: "urn:b", TargetURI: "http://a.com", TargetMode: ModeExternal},
+		{ID: "rId2", Type: "urn:a", TargetURI: "b.xml"},
+		{ID: "rId1", Type: "urn:c", TargetURI: "/c.xml"},
+	}
+	tests := []struct {
+		name string
+		s    *relationshipSelector
+		want string
+	}{
+		{"none", &relationshipSelector{}, `<Relationships xmlns="` + relationshipsNamespace + `"></Relationships>`},
+		{"byID", &relationshipSelector{ids: []string{"rId2"}}, `<Relationships xmlns="` + relationshipsNamespace + `">` +
+			`<Relationship Id="rId2" Target="b.xml" TargetMode="Internal" Type="urn:a"></Relationship></Relationships>`},
+		{"byTypeSorted", &relationshipSelector{ids: []string{"rId1"}, types: []string{"URN:B"}}, `<Relationships xmlns="` + relationshipsNamespace + `">` +
+			`<Relationship Id="rId1" Target="/c.xml" TargetMode="Internal" Type="urn:c"></Relationship>` +
+			`<Relationship Id="rId3" Target="http://a.com" TargetMode="External" Type="urn:b"></Relationship></Relationships>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(tt.s.transform(rels)); got != tt.want {
+				t.Errorf("relationshipSelector.transform() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_relationshipSelector_transformRelationships(t *testing.T) {
+	// Namespace declarations, unknown attributes, character content and
+	// the TargetMode default value shall not change the transform output.
+	in := `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships" xmlns:x="urn:x">
+    <Relationship Id="rId2" Type="urn:a" Target="b.xml" x:extra="1"/>
+    <Relationship Type="urn:c" Target="/c.xml" Id="rId1" TargetMode="Internal"/>
+</Relationships>`
+	want := `<Relationships xmlns="` + relationshipsNamespace + `">` +
+		`<Relationship Id="rId1" Target="/c.xml" TargetMode="Internal" Type="urn:c"></Relationship>` +
+		`<Relationship Id="rId2" Target="b.xml" TargetMode="Internal" Type="urn:a"></Relationship></Relationships>`
+	s := &relationshipSelector{types: []string{"urn:a", "urn:c"}}
+	got, err := s.transformRelationships([]byte(in), "/_rels/.rels")
+	if err != nil {
+		t.Fatalf("relationshipSelector.transformRelationships() error = %v", err)
+	}
+	if string(got) != want {
+		t.Errorf("relationshipSelector.transformRelationships() = %s, want %s", got, want)
+	}
+	if _, err = s.transformRelationships([]byte("<a"), "/_rels/.rels"); err == nil {
+		t.Error("relationshipSelector.transformRelationships() want error")
+	}
+}
+
+func TestReader_Signatures_RelationshipTransform(t *testing.T) {
+	key := newTestRSAKey(t)
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	err := w.Sign(key, []*x509.Certificate{newTestCertificate(t, key)}, &SignatureOptions{
+		Parts:         []string{"/a.xml"},
+		Relationships: []SignedRelationships{{Source: "/", Types: []string{"urn:officeDocument"}}},
+	})
+	if err != nil {
+		t.Fatalf("Writer.Sign() error = %v", err)
+	}
+	pw, _ := w.Create("/a.xml", "application/xml")
+	pw.Write([]byte("<a/>"))
+	w.Relationships = []*Relationship{
+		{ID: "rId1", Type: "urn:officeDocument", TargetURI: "/a.xml"},
+		{ID: "rId2", Type: "urn:thumbnail", TargetURI: "/a.xml"},
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantErr bool
+	}{
+		{"unmodified", "", "", false},
+		{"otherModified", `Id="rId2"`, `Id="rId5"`, false},
+		{"otherAdded", "</Relationships>", `<Relationship Id="rId9" Type="urn:x" Target="/a.xml"/></Relationships>`, false},
+		{"signedModified", `Target="/a.xml" Id="rId1"`, `Target="/b.xml" Id="rId1"`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := rewriteZip(t, buf.Bytes(), func(name string, content []byte) []byte {
+				if name == "_rels/.rels" {
+					// reorder the attributes so the replacements are unambiguous
+					content = []byte(strings.NewReplacer(
+						`Id="rId1" Type="urn:officeDocument" Target="/a.xml"`, `Type="urn:officeDocument" Target="/a.xml" Id="rId1"`,
+					).Replace(string(content)))
+					if tt.old != "" {
+						content = bytes.Replace(content, []byte(tt.old), []byte(tt.new), 1)
+					}
+				}
+				return content
+			})
+			r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			s := r.Signatures[0]
+			if s.Valid() == tt.wantErr {
+				t.Errorf("Signature.Valid() = %v, error = %v", s.Valid(), s.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,463 @@
+package opc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qmuntal/opc/c14n"
+)
+
+const (
+	signatureCertificateRel = "http://schemas.openxmlformats.org/package/2006/relationships/digital-signature/certificate"
+	excC14NNamespace        = "http://www.w3.org/2001/10/xml-exc-c14n#"
+)
+
+// Signature is a package digital signature found by the Reader, as defined in ISO/IEC 29500-2 §13.
+// The signature is verified when the package is opened.
+type Signature struct {
+	PartName     string              // The name of the XML signature part.
+	Certificate  *x509.Certificate   // The certificate of the signer.
+	Certificates []*x509.Certificate // All the certificates embedded in the signature or its certificate parts, starting with the signer one.
+	SigningTime  time.Time           // The signing time stated in the SignatureTime property. It is not a trusted time.
+	Covered      []string            // The names of the parts and relationships parts referenced by the signature.
+	Uncovered    []string            // The names of the parts and relationships parts of the package not referenced by the signature.
+	XAdES        *XAdESProperties    // The XAdES qualifying properties. Nil if the signature is not a XAdES signature.
+	Err          error               // The reason why the signature is not valid. Nil if the signature is valid.
+}
+
+// Valid reports whether the signature value and all its references have been successfully verified.
+func (s *Signature) Valid() bool {
+	return s.Err == nil
+}
+
+// signatureParts holds the parts of a package that implement the digital signatures.
+type signatureParts struct {
+	origin       string
+	originRels   []*Relationship
+	signatures   []string
+	certificates map[string][]string // signature part:certificate parts
+}
+
+func (sp *signatureParts) contains(name string) bool {
+	n := PartName(name)
+	if n.Equal(PartName(sp.origin)) || n.Equal(PartName(relationshipsPartName(sp.origin))) {
+		return true
+	}
+	for _, s := range sp.signatures {
+		if n.Equal(PartName(s)) || n.Equal(PartName(relationshipsPartName(s))) {
+			return true
+		}
+		for _, c := range sp.certificates[s] {
+			if n.Equal(PartName(c)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// signatureVerifier verifies the signatures of a package.
+type signatureVerifier struct {
+	files map[string]archiveFile // part key:file
+	ct    *contentTypes
+	parts *signatureParts
+}
+
+func (v *signatureVerifier) readPart(name string) ([]byte, error) {
+	f, ok := v.files[partKey(name)]
+	if !ok {
+		return nil, newError(609, name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot be opened: %v", name, err)
+	}
+	defer rc.Close()
+	b, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot be read: %v", name, err)
+	}
+	return b, nil
+}
+
+// packageParts returns the names of all the parts and relationships parts
+// that can be signed, which excludes the parts implementing the signatures.
+func (v *signatureVerifier) packageParts() []string {
+	names := make([]string, 0, len(v.files))
+	for _, f := range v.files {
+		name := "/" + f.Name()
+		if strings.EqualFold(name, contentTypesName) || v.parts.contains(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (v *signatureVerifier) verify(name string) *Signature {
+	s := &Signature{PartName: name}
+	s.Err = v.verifySignature(s)
+	covered := make(map[string]bool, len(s.Covered))
+	for _, c := range s.Covered {
+		covered[partKey(c)] = true
+	}
+	for _, p := range v.packageParts() {
+		if !covered[partKey(p)] {
+			s.Uncovered = append(s.Uncovered, p)
+		}
+	}
+	return s
+}
+
+func (v *signatureVerifier) verifySignature(s *Signature) error {
+	b, err := v.readPart(s.PartName)
+	if err != nil {
+		return err
+	}
+	doc, err := parseXMLTree(bytes.NewReader(b))
+	if err != nil {
+		return newError(616, s.PartName)
+	}
+	sig := doc.root()
+	if !sig.is(xmldsigNamespace, "Signature") {
+		return newError(616, s.PartName)
+	}
+	signedInfo := sig.element(xmldsigNamespace, "SignedInfo")
+	sigValue := sig.element(xmldsigNamespace, "SignatureValue")
+	if signedInfo == nil || sigValue == nil {
+		return newError(616, s.PartName)
+	}
+	if err = v.loadCertificates(s, sig); err != nil {
+		return err
+	}
+	object, err := packageObject(s.PartName, sig, signedInfo)
+	if err != nil {
+		return err
+	}
+	manifest := object.element(xmldsigNamespace, "Manifest")
+	if manifest == nil {
+		return newError(612, s.PartName)
+	}
+	if s.SigningTime, err = signatureTime(s.PartName, object); err != nil {
+		return err
+	}
+	refs := manifest.elements(xmldsigNamespace, "Reference")
+	for _, ref := range refs {
+		uri, _ := ref.attr("URI")
+		s.Covered = append(s.Covered, NormalizePartName(strings.SplitN(uri, "?", 2)[0]))
+	}
+
+	for _, ref := range signedInfo.elements(xmldsigNamespace, "Reference") {
+		if err = v.verifyObjectReference(s.PartName, sig, ref); err != nil {
+			return err
+		}
+	}
+	if err = verifySignatureValue(s, signedInfo, sigValue); err != nil {
+		return err
+	}
+	for _, ref := range refs {
+		if err = v.verifyPartReference(s.PartName, ref); err != nil {
+			return err
+		}
+	}
+	s.XAdES, err = verifyXAdES(s, sig, signedInfo, sigValue)
+	return err
+}
+
+func (v *signatureVerifier) loadCertificates(s *Signature, sig *xmlElement) error {
+	var raws [][]byte
+	if keyInfo := sig.element(xmldsigNamespace, "KeyInfo"); keyInfo != nil {
+		for _, data := range keyInfo.elements(xmldsigNamespace, "X509Data") {
+			for _, c := range data.elements(xmldsigNamespace, "X509Certificate") {
+				raw, err := decodeBase64(c.text())
+				if err != nil {
+					return newError(616, s.PartName)
+				}
+				raws = append(raws, raw)
+			}
+		}
+	}
+	for _, name := range v.parts.certificates[s.PartName] {
+		raw, err := v.readPart(name)
+		if err != nil {
+			return err
+		}
+		raws = append(raws, raw)
+	}
+	for _, raw := range raws {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return newError(610, s.PartName)
+		}
+		s.Certificates = append(s.Certificates, cert)
+	}
+	if len(s.Certificates) == 0 {
+		return newError(610, s.PartName)
+	}
+	s.Certificate = s.Certificates[0]
+	return nil
+}
+
+// packageObject returns the package-specific Object element, which shall be unique and signed.
+func packageObject(partName string, sig, signedInfo *xmlElement) (*xmlElement, error) {
+	objects := sig.findByID(packageObjectID)
+	if len(objects) != 1 || !objects[0].is(xmldsigNamespace, "Object") {
+		return nil, newError(611, partName)
+	}
+	for _, ref := range signedInfo.elements(xmldsigNamespace, "Reference") {
+		if uri, _ := ref.attr("URI"); uri == "#"+packageObjectID {
+			return objects[0], nil
+		}
+	}
+	return nil, newError(611, partName)
+}
+
+var signatureTimeLayouts = map[string]string{
+	"YYYY-MM-DDThh:mm:ss.sTZD": "2006-01-02T15:04:05Z07:00",
+	"YYYY-MM-DDThh:mm:ssTZD":   "2006-01-02T15:04:05Z07:00",
+	"YYYY-MM-DDThh:mmTZD":      "2006-01-02T15:04Z07:00",
+	"YYYY-MM-DD":               "2006-01-02",
+	"YYYY-MM":                  "2006-01",
+	"YYYY":                     "2006",
+}
+
+func signatureTime(partName string, object *xmlElement) (time.Time, error) {
+	for _, props := range object.elements(xmldsigNamespace, "SignatureProperties") {
+		for _, prop := range props.elements(xmldsigNamespace, "SignatureProperty") {
+			st := prop.element(opcSigNamespace, "SignatureTime")
+			if st == nil {
+				continue
+			}
+			format, value := st.element(opcSigNamespace, "Format"), st.element(opcSigNamespace, "Value")
+			if format == nil || value == nil {
+				return time.Time{}, newError(612, partName)
+			}
+			layout, ok := signatureTimeLayouts[strings.TrimSpace(format.text())]
+			if !ok {
+				return time.Time{}, newError(612, partName)
+			}
+			t, err := time.Parse(layout, strings.TrimSpace(value.text()))
+			if err != nil {
+				return time.Time{}, newError(612, partName)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, newError(612, partName)
+}
+
+// verifyObjectReference verifies a same-document reference of the SignedInfo element.
+func (v *signatureVerifier) verifyObjectReference(partName string, sig, ref *xmlElement) error {
+	uri, _ := ref.attr("URI")
+	if !strings.HasPrefix(uri, "#") {
+		return newError(615, partName)
+	}
+	targets := sig.findByID(uri[1:])
+	if len(targets) != 1 {
+		return newError(611, partName)
+	}
+	method := c14n.Canonical
+	var inclusive []string
+	for _, t := range transformElements(ref) {
+		m, prefixes, ok := canonicalizationMethod(t)
+		if !ok {
+			return newError(615, partName)
+		}
+		method, inclusive = m, prefixes
+	}
+	// A bare-name XPointer reference does not include comments.
+	switch method {
+	case c14n.CanonicalWithComments:
+		method = c14n.Canonical
+	case c14n.ExclusiveWithComments:
+		method = c14n.Exclusive
+	}
+	b, err := canonicalize(targets[0], method, inclusive...)
+	if err != nil {
+		return newError(616, partName)
+	}
+	return compareDigest(partName, ref, b)
+}
+
+// verifyPartReference verifies a reference of the Manifest element to a package part.
+func (v *signatureVerifier) verifyPartReference(partName string, ref *xmlElement) error {
+	uri, _ := ref.attr("URI")
+	name, query := split(uri, '?')
+	name = NormalizePartName(name)
+	if !strings.HasPrefix(query, "?ContentType=") {
+		return newError(607, name)
+	}
+	contentType := strings.TrimPrefix(query, "?ContentType=")
+	if strings.EqualFold(name, contentTypesName) || PartName(name).Equal(PartName(v.parts.origin)) || PartName(name).Equal(PartName(relationshipsPartName(v.parts.origin))) {
+		return newError(608, name)
+	}
+	b, err := v.readPart(name)
+	if err != nil {
+		return err
+	}
+	var wantType string
+	if isRelationshipURI(name) {
+		wantType = relationshipContentType
+	} else if wantType, err = v.ct.findType(name); err != nil {
+		return err
+	}
+	if normalizeContentType(contentType) != normalizeContentType(wantType) {
+		return newError(607, name)
+	}
+	transforms := transformElements(ref)
+	for i, t := range transforms {
+		if m, prefixes, ok := canonicalizationMethod(t); ok {
+			doc, err := parseXMLTree(bytes.NewReader(b))
+			if err != nil {
+				return fmt.Errorf("opc: %s: cannot be decoded: %v", name, err)
+			}
+			if b, err = canonicalize(doc, m, prefixes...); err != nil {
+				return fmt.Errorf("opc: %s: cannot be canonicalized: %v", name, err)
+			}
+			continue
+		}
+		// The Relationships Transform shall only be applied to relationships parts
+		// and shall be followed by a canonicalization transform.
+		if alg, _ := t.attr("Algorithm"); alg != relationshipTransformAlgorithm || !isRelationshipURI(name) || i+1 == len(transforms) {
+			return newError(615, name)
+		}
+		if _, _, ok := canonicalizationMethod(transforms[i+1]); !ok {
+			return newError(615, name)
+		}
+		if b, err = newRelationshipSelector(t).transformRelationships(b, name); err != nil {
+			return err
+		}
+	}
+	return compareDigest(name, ref, b)
+}
+
+// canonicalizationMethod returns the canonicalization method identified by the Algorithm
+// of a CanonicalizationMethod or Transform element, and the InclusiveNamespaces PrefixList
+// parameter of the exclusive methods.
+func canonicalizationMethod(e *xmlElement) (c14n.Method, []string, bool) {
+	alg, _ := e.attr("Algorithm")
+	m, ok := c14n.MethodByURI(alg)
+	if !ok {
+		return 0, nil, false
+	}
+	var prefixes []string
+	if m.IsExclusive() {
+		if incl := e.element(excC14NNamespace, "InclusiveNamespaces"); incl != nil {
+			list, _ := incl.attr("PrefixList")
+			prefixes = strings.Fields(list)
+		}
+	}
+	return m, prefixes, true
+}
+
+func transformElements(ref *xmlElement) []*xmlElement {
+	if transforms := ref.element(xmldsigNamespace, "Transforms"); transforms != nil {
+		return transforms.elements(xmldsigNamespace, "Transform")
+	}
+	return nil
+}
+
+func compareDigest(partName string, ref *xmlElement, b []byte) error {
+	method := ref.element(xmldsigNamespace, "DigestMethod")
+	value := ref.element(xmldsigNamespace, "DigestValue")
+	if method == nil || value == nil {
+		return newError(616, partName)
+	}
+	alg, _ := method.attr("Algorithm")
+	hash, ok := hashByAlgorithm(digestAlgorithms, alg)
+	if !ok {
+		return newError(605, partName)
+	}
+	want, err := decodeBase64(value.text())
+	if err != nil {
+		return newError(616, partName)
+	}
+	h := hash.New()
+	h.Write(b)
+	if !bytes.Equal(h.Sum(nil), want) {
+		return newError(613, partName)
+	}
+	return nil
+}
+
+func verifySignatureValue(s *Signature, signedInfo, sigValue *xmlElement) error {
+	c14nMethod := signedInfo.element(xmldsigNamespace, "CanonicalizationMethod")
+	sigMethod := signedInfo.element(xmldsigNamespace, "SignatureMethod")
+	if c14nMethod == nil || sigMethod == nil {
+		return newError(616, s.PartName)
+	}
+	m, prefixes, ok := canonicalizationMethod(c14nMethod)
+	if !ok {
+		return newError(605, s.PartName)
+	}
+	canonical, err := canonicalize(signedInfo, m, prefixes...)
+	if err != nil {
+		return newError(616, s.PartName)
+	}
+	value, err := decodeBase64(sigValue.text())
+	if err != nil {
+		return newError(616, s.PartName)
+	}
+	alg, _ := sigMethod.attr("Algorithm")
+	var hash crypto.Hash
+	switch pub := s.Certificate.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if hash, ok = hashByAlgorithm(rsaSignatureAlgorithms, alg); !ok {
+			return newError(605, s.PartName)
+		}
+		if rsa.VerifyPKCS1v15(pub, hash, digest(hash, canonical), value) != nil {
+			return newError(614, s.PartName)
+		}
+	case *ecdsa.PublicKey:
+		if hash, ok = hashByAlgorithm(ecdsaSignatureAlgorithms, alg); !ok {
+			return newError(605, s.PartName)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(value) != 2*size {
+			return newError(614, s.PartName)
+		}
+		r, ss := new(big.Int).SetBytes(value[:size]), new(big.Int).SetBytes(value[size:])
+		if !ecdsa.Verify(pub, digest(hash, canonical), r, ss) {
+			return newError(614, s.PartName)
+		}
+	default:
+		return newError(605, s.PartName)
+	}
+	return nil
+}
+
+func digest(hash crypto.Hash, b []byte) []byte {
+	h := hash.New()
+	h.Write(b)
+	return h.Sum(nil)
+}
+
+func hashByAlgorithm(algs map[crypto.Hash]string, alg string) (crypto.Hash, bool) {
+	for h, a := range algs {
+		if a == alg && h.Available() {
+			return h, true
+		}
+	}
+	return 0, false
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, s)
+	return base64.StdEncoding.DecodeString(s)
+}
@@ -0,0 +1,273 @@
+package opc
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qmuntal/opc/c14n"
+)
+
+func newSignedPackage(t *testing.T, signer crypto.Signer, opts *SignatureOptions) []byte {
+	t.Helper()
+	return newSignedPackageWithCerts(t, signer, []*x509.Certificate{newTestCertificate(t, signer)}, opts)
+}
+
+func newSignedPackageWithCerts(t *testing.T, signer crypto.Signer, certs []*x509.Certificate, opts *SignatureOptions) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Sign(signer, certs, opts); err != nil {
+		t.Fatalf("Writer.Sign() error = %v", err)
+	}
+	pw, _ := w.CreatePart(&Part{Name: "/a.xml", ContentType: "application/xml", Relationships: []*Relationship{
+		{ID: "rId1", Type: "text", TargetURI: "/b/c.txt"},
+	}}, CompressionNormal)
+	pw.Write([]byte("<a xmlns='urn:a'><b/></a>"))
+	pw, _ = w.Create("/b/c.txt", "text/plain")
+	pw.Write([]byte("hello"))
+	w.Properties.Title = "signed"
+	w.Relationships = append(w.Relationships, &Relationship{ID: "rId1", Type: "main", TargetURI: "/a.xml"})
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func rewriteZip(t *testing.T, b []byte, modify func(name string, content []byte) []byte) []byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, f := range zr.File {
+		rc, _ := f.Open()
+		content, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		fw, _ := zw.Create(f.Name)
+		fw.Write(modify(f.Name, content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestReader_Signatures(t *testing.T) {
+	rsaKey := newTestRSAKey(t)
+	ecKey := newTestECDSAKey(t)
+	signingTime := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name          string
+		signer        crypto.Signer
+		opts          *SignatureOptions
+		wantCovered   []string
+		wantUncovered []string
+	}{
+		{"rsaAll", rsaKey, &SignatureOptions{SigningTime: signingTime}, []string{
+			"/a.xml", "/b/c.txt", "/props/core.xml", "/_rels/a.xml.rels", "/_rels/.rels",
+		}, nil},
+		{"ecdsaSubset", ecKey, &SignatureOptions{SigningTime: signingTime, Hash: crypto.SHA512, Parts: []string{"/b/c.txt"}}, []string{
+			"/b/c.txt",
+		}, []string{"/_rels/.rels", "/_rels/a.xml.rels", "/a.xml", "/props/core.xml"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newSignedPackage(t, tt.signer, tt.opts)
+			r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			if len(r.Files) != 2 {
+				t.Errorf("NewReader() files = %d, want 2", len(r.Files))
+			}
+			if len(r.Signatures) != 1 {
+				t.Fatalf("NewReader() signatures = %d, want 1", len(r.Signatures))
+			}
+			s := r.Signatures[0]
+			if !s.Valid() {
+				t.Fatalf("Signature.Valid() error = %v", s.Err)
+			}
+			if s.PartName != "/_xmlsignatures/sig1.xml" {
+				t.Errorf("Signature.PartName = %s", s.PartName)
+			}
+			if !s.SigningTime.Equal(signingTime) {
+				t.Errorf("Signature.SigningTime = %v, want %v", s.SigningTime, signingTime)
+			}
+			if s.Certificate == nil || len(s.Certificates) != 1 {
+				t.Error("Signature.Certificate not loaded")
+			}
+			if !reflect.DeepEqual(s.Covered, tt.wantCovered) {
+				t.Errorf("Signature.Covered = %v, want %v", s.Covered, tt.wantCovered)
+			}
+			if !reflect.DeepEqual(s.Uncovered, tt.wantUncovered) {
+				t.Errorf("Signature.Uncovered = %v, want %v", s.Uncovered, tt.wantUncovered)
+			}
+		})
+	}
+}
+
+func TestReader_Signatures_Invalid(t *testing.T) {
+	b := newSignedPackage(t, newTestRSAKey(t), nil)
+	tests := []struct {
+		name     string
+		modify   func(name string, content []byte) []byte
+		wantCode int
+		wantPart string
+	}{
+		{"tamperedPart", func(name string, content []byte) []byte {
+			if name == "b/c.txt" {
+				return []byte("bye")
+			}
+			return content
+		}, 613, "/b/c.txt"},
+		{"tamperedObject", func(name string, content []byte) []byte {
+			if name == "_xmlsignatures/sig1.xml" {
+				return bytes.Replace(content, []byte("/b/c.txt?ContentType=text/plain"), []byte("/b/c.txt?ContentType=text/html"), 1)
+			}
+			return content
+		}, 613, "/_xmlsignatures/sig1.xml"},
+		{"tamperedSignatureValue", func(name string, content []byte) []byte {
+			if name == "_xmlsignatures/sig1.xml" {
+				i := bytes.Index(content, []byte("<SignatureValue>")) + len("<SignatureValue>")
+				if content[i] == 'A' {
+					content[i] = 'B'
+				} else {
+					content[i] = 'A'
+				}
+			}
+			return content
+		}, 614, "/_xmlsignatures/sig1.xml"},
+		{"malformed", func(name string, content []byte) []byte {
+			if name == "_xmlsignatures/sig1.xml" {
+				return content[:len(content)/2]
+			}
+			return content
+		}, 616, "/_xmlsignatures/sig1.xml"},
+		{"noCertificate", func(name string, content []byte) []byte {
+			if name == "_xmlsignatures/sig1.xml" {
+				i := bytes.Index(content, []byte("<KeyInfo>"))
+				j := bytes.Index(content, []byte("</KeyInfo>")) + len("</KeyInfo>")
+				return append(content[:i:i], content[j:]...)
+			}
+			return content
+		}, 610, "/_xmlsignatures/sig1.xml"},
+		{"contentTypeMismatch", func(name string, content []byte) []byte {
+			if name == "[Content_Types].xml" {
+				return bytes.Replace(content, []byte("text/plain"), []byte("text/html"), 1)
+			}
+			return content
+		}, 607, "/b/c.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nb := rewriteZip(t, b, tt.modify)
+			r, err := NewReader(bytes.NewReader(nb), int64(len(nb)))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			if len(r.Signatures) != 1 {
+				t.Fatalf("NewReader() signatures = %d, want 1", len(r.Signatures))
+			}
+			s := r.Signatures[0]
+			if s.Valid() {
+				t.Fatal("Signature.Valid() want invalid")
+			}
+			err2, ok := s.Err.(*Error)
+			if !ok {
+				t.Fatalf("Signature.Err = %v, want *Error", s.Err)
+			}
+			if err2.Code() != tt.wantCode || err2.PartName() != tt.wantPart {
+				t.Errorf("Signature.Err = %v (%d), want %d on %s", err2, err2.Code(), tt.wantCode, tt.wantPart)
+			}
+		})
+	}
+}
+
+func TestReader_Signatures_DuplicatedOrigin(t *testing.T) {
+	b := newSignedPackage(t, newTestRSAKey(t), nil)
+	b = rewriteZip(t, b, func(name string, content []byte) []byte {
+		if name == "_rels/.rels" {
+			return bytes.Replace(content, []byte("</Relationships>"), []byte(`<Relationship Id="rIdX" Type="`+signatureOriginRel+`" Target="/other.sigs"></Relationship></Relationships>`), 1)
+		}
+		return content
+	})
+	_, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err == nil {
+		t.Fatal("NewReader() want error")
+	}
+	if got := err.(*Error).Code(); got != 601 {
+		t.Errorf("NewReader() error code = %d, want 601", got)
+	}
+}
+
+func TestNewWriterFromReader_Signed(t *testing.T) {
+	b := newSignedPackage(t, newTestRSAKey(t), nil)
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	var buf bytes.Buffer
+	w, err := NewWriterFromReader(&buf, r)
+	if err != nil {
+		t.Fatalf("NewWriterFromReader() error = %v", err)
+	}
+	for _, rel := range w.Relationships {
+		if rel.Type == signatureOriginRel {
+			t.Error("NewWriterFromReader() shall not copy the signature origin relationship")
+		}
+	}
+	if err = w.Close(); err != nil {
+		t.Errorf("Writer.Close() error = %v", err)
+	}
+}
+
+func Test_canonicalize(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		id   string
+		m    c14n.Method
+		want string
+	}{
+		{"document", "<?xml version=\"1.0\"?>\n<?pi data?>\n<!--c-->\n<a b='2' a='1'><c/><!--d--></a>\n<?end?>", "", c14n.Canonical,
+			"<?pi data?>\n<a a=\"1\" b=\"2\"><c></c></a>\n<?end?>"},
+		{"documentWithComments", "<!--c--><a><!--d--></a><!--e-->", "", c14n.CanonicalWithComments,
+			"<!--c-->\n<a><!--d--></a>\n<!--e-->"},
+		{"escaping", "<a b='&quot;&lt;&#9;&#10;&#13;&amp;'>&lt;&gt;&amp;&#13;\"'</a>", "", c14n.Canonical,
+			"<a b=\"&quot;&lt;&#x9;&#xA;&#xD;&amp;\">&lt;&gt;&amp;&#xD;\"'</a>"},
+		{"attributeOrder", "<a xmlns:z='urn:a' xmlns:b='urn:b' z:x='1' b:y='2' c='3' xmlns='urn:c'/>", "", c14n.Canonical,
+			"<a xmlns=\"urn:c\" xmlns:b=\"urn:b\" xmlns:z=\"urn:a\" c=\"3\" z:x=\"1\" b:y=\"2\"></a>"},
+		{"redundantNamespaces", "<a xmlns='urn:a' xmlns:p='urn:p'><b xmlns='urn:a' xmlns:p='urn:q'/><c xmlns=''/></a>", "", c14n.Canonical,
+			"<a xmlns=\"urn:a\" xmlns:p=\"urn:p\"><b xmlns:p=\"urn:q\"></b><c xmlns=\"\"></c></a>"},
+		{"subset", "<a xmlns='urn:a' xmlns:p='urn:p' xml:lang='en'><p:b Id='x'><c/></p:b></a>", "x", c14n.Canonical,
+			"<p:b xmlns=\"urn:a\" xmlns:p=\"urn:p\" Id=\"x\" xml:lang=\"en\"><c></c></p:b>"},
+		{"exclusiveSubset", "<a xmlns='urn:a' xmlns:p='urn:p' xml:lang='en'><p:b Id='x'><c/></p:b></a>", "x", c14n.Exclusive,
+			"<p:b xmlns:p=\"urn:p\" Id=\"x\"><c xmlns=\"urn:a\"></c></p:b>"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := parseXMLTree(strings.NewReader(tt.doc))
+			if err != nil {
+				t.Fatalf("parseXMLTree() error = %v", err)
+			}
+			e := doc
+			if tt.id != "" {
+				e = doc.findByID(tt.id)[0]
+			}
+			got, err := canonicalize(e, tt.m)
+			if err != nil {
+				t.Fatalf("canonicalize() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("canonicalize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
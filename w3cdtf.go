@@ -0,0 +1,92 @@
+package opc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// W3CDTFPrecision is an enumerable for the precisions allowed by the W3C Date and Time Formats profile.
+type W3CDTFPrecision int
+
+const (
+	// PrecisionYear formats a date as YYYY.
+	PrecisionYear W3CDTFPrecision = iota
+	// PrecisionMonth formats a date as YYYY-MM.
+	PrecisionMonth
+	// PrecisionDay formats a date as YYYY-MM-DD.
+	PrecisionDay
+	// PrecisionMinute formats a date as YYYY-MM-DDThh:mmTZD.
+	PrecisionMinute
+	// PrecisionSecond formats a date as YYYY-MM-DDThh:mm:ssTZD.
+	PrecisionSecond
+	// PrecisionFraction formats a date as YYYY-MM-DDThh:mm:ss.sTZD, with as many fraction digits as needed.
+	PrecisionFraction
+)
+
+var w3cdtfRegexp = regexp.MustCompile(`^\d{4}(-\d{2}(-\d{2}(T\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:\d{2}))?)?)?$`)
+
+var w3cdtfLayouts = [...]string{
+	PrecisionYear:     "2006",
+	PrecisionMonth:    "2006-01",
+	PrecisionDay:      "2006-01-02",
+	PrecisionMinute:   "2006-01-02T15:04Z07:00",
+	PrecisionSecond:   "2006-01-02T15:04:05Z07:00",
+	PrecisionFraction: "2006-01-02T15:04:05Z07:00",
+}
+
+// ParseW3CDTF parses a date formatted as defined in the W3C Date and Time Formats profile
+// and returns it along with its precision.
+// The dates without time are returned in UTC.
+func ParseW3CDTF(s string) (time.Time, W3CDTFPrecision, error) {
+	m := w3cdtfRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}, 0, fmt.Errorf("opc: %q is not a W3CDTF date", s)
+	}
+	var p W3CDTFPrecision
+	switch {
+	case m[5] != "":
+		p = PrecisionFraction
+	case m[4] != "":
+		p = PrecisionSecond
+	case m[3] != "":
+		p = PrecisionMinute
+	case m[2] != "":
+		p = PrecisionDay
+	case m[1] != "":
+		p = PrecisionMonth
+	default:
+		p = PrecisionYear
+	}
+	t, err := time.Parse(w3cdtfLayouts[p], s)
+	if err != nil {
+		return time.Time{}, 0, fmt.Errorf("opc: %q is not a W3CDTF date: %v", s, err)
+	}
+	return t, p, nil
+}
+
+// FormatW3CDTF returns t formatted with precision p as defined in the W3C Date and Time Formats profile.
+func FormatW3CDTF(t time.Time, p W3CDTFPrecision) string {
+	if p < PrecisionYear || p > PrecisionFraction {
+		p = PrecisionFraction
+	}
+	if p != PrecisionFraction {
+		return t.Format(w3cdtfLayouts[p])
+	}
+	frac := strings.TrimRight(fmt.Sprintf("%09d", t.Nanosecond()), "0")
+	if frac == "" {
+		frac = "0"
+	}
+	return t.Format("2006-01-02T15:04:05") + "." + frac + t.Format("Z07:00")
+}
+
+// parseW3CDTF parses the value of a date core property,
+// returning the zero time if it is empty.
+func parseW3CDTF(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, _, err := ParseW3CDTF(value)
+	return t, err
+}
@@ -0,0 +1,100 @@
+package opc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseW3CDTF(t *testing.T) {
+	plus1 := time.FixedZone("", 3600)
+	tests := []struct {
+		s       string
+		want    time.Time
+		wantP   W3CDTFPrecision
+		wantErr bool
+	}{
+		{"2019", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC), PrecisionYear, false},
+		{"2019-02", time.Date(2019, 2, 1, 0, 0, 0, 0, time.UTC), PrecisionMonth, false},
+		{"2019-02-03", time.Date(2019, 2, 3, 0, 0, 0, 0, time.UTC), PrecisionDay, false},
+		{"2019-02-03T04:05Z", time.Date(2019, 2, 3, 4, 5, 0, 0, time.UTC), PrecisionMinute, false},
+		{"2019-02-03T04:05:06+01:00", time.Date(2019, 2, 3, 4, 5, 6, 0, plus1), PrecisionSecond, false},
+		{"2019-02-03T04:05:06.25-01:00", time.Date(2019, 2, 3, 4, 5, 6, 250000000, time.FixedZone("", -3600)), PrecisionFraction, false},
+		{"", time.Time{}, 0, true},
+		{"19", time.Time{}, 0, true},
+		{"2019-2", time.Time{}, 0, true},
+		{"2019-13", time.Time{}, 0, true},
+		{"2019-02-30", time.Time{}, 0, true},
+		{"2019-02-03T04:05", time.Time{}, 0, true},
+		{"2019-02-03T04Z", time.Time{}, 0, true},
+		{"2019-02-03T4:05Z", time.Time{}, 0, true},
+		{"2019-02-03T04:05:06.Z", time.Time{}, 0, true},
+		{"2019-02-03 04:05:06Z", time.Time{}, 0, true},
+		{"2019-02-03T25:05:06Z", time.Time{}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			got, gotP, err := ParseW3CDTF(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseW3CDTF() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !got.Equal(tt.want) || gotP != tt.wantP {
+				t.Errorf("ParseW3CDTF() = (%v, %v), want (%v, %v)", got, gotP, tt.want, tt.wantP)
+			}
+		})
+	}
+}
+
+func TestFormatW3CDTF(t *testing.T) {
+	d := time.Date(2019, 2, 3, 4, 5, 6, 120000000, time.FixedZone("", -5400))
+	tests := []struct {
+		name string
+		t    time.Time
+		p    W3CDTFPrecision
+		want string
+	}{
+		{"year", d, PrecisionYear, "2019"},
+		{"month", d, PrecisionMonth, "2019-02"},
+		{"day", d, PrecisionDay, "2019-02-03"},
+		{"minute", d, PrecisionMinute, "2019-02-03T04:05-01:30"},
+		{"second", d.UTC(), PrecisionSecond, "2019-02-03T05:35:06Z"},
+		{"fraction", d, PrecisionFraction, "2019-02-03T04:05:06.12-01:30"},
+		{"fractionZero", d.Truncate(time.Second), PrecisionFraction, "2019-02-03T04:05:06.0-01:30"},
+		{"invalid", d, PrecisionFraction + 1, "2019-02-03T04:05:06.12-01:30"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FormatW3CDTF(tt.t, tt.p)
+			if got != tt.want {
+				t.Errorf("FormatW3CDTF() = %v, want %v", got, tt.want)
+			}
+			if _, p, err := ParseW3CDTF(got); err != nil || (tt.p <= PrecisionFraction && p != tt.p) {
+				t.Errorf("ParseW3CDTF() = (%v, %v), want precision %v", p, err, tt.p)
+			}
+		})
+	}
+}
+
+func TestCoreProperties_Times(t *testing.T) {
+	d := time.Date(2019, 2, 3, 4, 5, 6, 0, time.UTC)
+	var c CoreProperties
+	c.SetCreatedTime(d, PrecisionDay)
+	c.SetModifiedTime(d, PrecisionSecond)
+	c.SetLastPrintedTime(d, PrecisionMinute)
+	if c.Created != "2019-02-03" || c.Modified != "2019-02-03T04:05:06Z" || c.LastPrinted != "2019-02-03T04:05Z" {
+		t.Errorf("CoreProperties = (%s, %s, %s)", c.Created, c.Modified, c.LastPrinted)
+	}
+	if got, err := c.ModifiedTime(); err != nil || !got.Equal(d) {
+		t.Errorf("CoreProperties.ModifiedTime() = (%v, %v), want %v", got, err, d)
+	}
+	if got, err := c.CreatedTime(); err != nil || !got.Equal(d.Truncate(24*time.Hour)) {
+		t.Errorf("CoreProperties.CreatedTime() = (%v, %v), want %v", got, err, d.Truncate(24*time.Hour))
+	}
+	c.LastPrinted = ""
+	if got, err := c.LastPrintedTime(); err != nil || !got.IsZero() {
+		t.Errorf("CoreProperties.LastPrintedTime() = (%v, %v), want zero time", got, err)
+	}
+	c.LastPrinted = "yesterday"
+	if _, err := c.LastPrintedTime(); err == nil {
+		t.Error("CoreProperties.LastPrintedTime() want error")
+	}
+}
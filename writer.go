@@ -3,9 +3,12 @@ package opc
 import (
 	"archive/zip"
 	"compress/flate"
+	"crypto"
+	"crypto/x509"
+	"encoding/xml"
 	"fmt"
+	"hash"
 	"io"
-	"path"
 	"strings"
 	"time"
 )
@@ -28,17 +31,65 @@ const (
 
 // Writer implements a OPC file writer.
 type Writer struct {
-	Properties    CoreProperties  // Package metadata. Can be modified until the Writer is closed.
-	Relationships []*Relationship // The relationships associated to the package. Can be modified until the Writer is closed.
-	p             *pkg
-	w             *zip.Writer
-	last          *Part
+	Properties          CoreProperties       // Package metadata. Can be modified until the Writer is closed.
+	ExtendedProperties  ExtendedProperties   // Application specific package metadata. Can be modified until the Writer is closed.
+	CustomProperties    CustomProperties     // User defined package metadata. Can be modified until the Writer is closed.
+	Relationships       []*Relationship      // The relationships associated to the package. Can be modified until the Writer is closed.
+	UpdateDates         bool                 // If true the Modified core property, and the Created one if empty, are set to the current time when the Writer is closed.
+	ContentTypeStrategy ContentTypeStrategy  // The way the content types stream maps the parts to their content types. Can be modified until the Writer is closed.
+	Deterministic       bool                 // If true the same package content is always written with the same bytes. See ModTime.
+	ModTime             time.Time            // If Deterministic is true, the time used as ZIP item modification time and as the current time. If zero, 1980-01-01 00:00:00 UTC is used.
+	ContentTypeRegistry *ContentTypeRegistry // The registry used by CreateAuto. If nil DefaultContentTypeRegistry is used.
+	StrictRelationships bool                 // If true Close fails if an internal relationship targets a part that has not been written.
+	RelativeTargets     bool                 // If true the absolute internal relationship targets are made relative to their source, using RelativeRelationship, when the relationships are written.
+	p                   *pkg
+	w                   *zip.Writer
+	last                *Part
+	signatures          []*signatureRequest
+	digests             []*partDigest
+	interleaved         []*PartWriter
+	thumbnails          []*Part // The sources, and their written relationships, which have a thumbnail.
+	pending             *sniffWriter
+	pendingErr          error                 // The error creating the pending part, which is sticky.
+	rels                []SourcedRelationship // The written relationships, checked by Close if StrictRelationships is true.
+	coreDoc             []byte                // The core properties part copied by NewWriterFromReader, updated with Properties when written.
+}
+
+// partDigest holds the running digests of a part written after a signature has been requested.
+// If the part is a relationships part, rels holds the relationships of source.
+// If the part has been copied from a Reader, file is used to digest it on demand.
+type partDigest struct {
+	part   *Part
+	hashes map[crypto.Hash]hash.Hash
+	source string
+	rels   []*Relationship
+	file   *File
+}
+
+// sum returns the digest of the part contents computed with hash.
+func (d *partDigest) sum(hash crypto.Hash) ([]byte, error) {
+	if h, ok := d.hashes[hash]; ok {
+		return h.Sum(nil), nil
+	}
+	if d.file == nil {
+		return nil, fmt.Errorf("opc: %s: cannot be signed: the part was created before calling Writer.Sign", d.part.Name)
+	}
+	rc, err := d.file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot be signed: %v", d.part.Name, err)
+	}
+	defer rc.Close()
+	h := hash.New()
+	if _, err = io.Copy(h, rc); err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot be signed: %v", d.part.Name, err)
+	}
+	return h.Sum(nil), nil
 }
 
 // NewWriter returns a new Writer writing an OPC package to w.
 func NewWriter(w io.Writer) *Writer {
 	return &Writer{p: &pkg{
-		parts: make(map[string]struct{}, 0),
+		parts: make(map[string]packagePart, 0),
 		contentTypes: contentTypes{
 			defaults: map[string]string{
 				"rels": relationshipContentType,
@@ -54,9 +105,18 @@ func NewWriter(w io.Writer) *Writer {
 // The original package is not modified.
 // Parts coming from r cannot be modified but new parts can be appended
 // and package core properties and relationships can be updated.
+// The digital signatures of r are not copied, use NewSignatureEditor to add or remove signatures
+// without invalidating the other ones.
+// The copied parts can be signed by calling Sign before closing the Writer,
+// in which case r shall remain readable until then.
 func NewWriterFromReader(w io.Writer, r *Reader) (*Writer, error) {
 	ow := NewWriter(w)
 	for _, p := range r.Files {
+		if (r.ExtendedProperties.doc != nil && PartName(p.Name).Equal(PartName(ResolveRelationship("/", r.ExtendedProperties.PartName)))) ||
+			(r.CustomProperties.PartName != "" && PartName(p.Name).Equal(PartName(ResolveRelationship("/", r.CustomProperties.PartName)))) {
+			// The decoded properties are written when the Writer is closed.
+			continue
+		}
 		pw, err := ow.CreatePart(p.Part, CompressionNormal)
 		if err != nil {
 			return nil, err
@@ -70,26 +130,52 @@ func NewWriterFromReader(w io.Writer, r *Reader) (*Writer, error) {
 		if err != nil {
 			return nil, err
 		}
+		ow.digests = append(ow.digests, &partDigest{part: p.Part, file: p})
 	}
 	ow.Properties = r.Properties
-	ow.Relationships = make([]*Relationship, len(r.Relationships))
-	for i, rel := range r.Relationships {
-		ow.Relationships[i] = &(*rel)
+	ow.coreDoc = r.coreDoc
+	ow.ExtendedProperties = r.ExtendedProperties
+	ow.CustomProperties = r.CustomProperties.clone()
+	ow.Relationships = make([]*Relationship, 0, len(r.Relationships))
+	for _, rel := range r.Relationships {
+		if strings.EqualFold(rel.Type, signatureOriginRel) {
+			continue
+		}
+		ow.Relationships = append(ow.Relationships, &(*rel))
 	}
 	return ow, nil
 }
 
+// ContentTypes returns the content types of the parts created so far,
+// arranged as they would be written with the current ContentTypeStrategy.
+func (w *Writer) ContentTypes() ContentTypes {
+	return w.p.arrangeContentTypes(w.ContentTypeStrategy).view(w.p)
+}
+
 // Flush flushes any buffered data to the underlying writer.
 // Part metadata, relationships, content types and other OPC related files won't be flushed.
 // Calling Flush is not normally necessary; calling Close is sufficient.
 // Useful to do simultaneous writing and reading.
 func (w *Writer) Flush() error {
+	if err := w.createPending(); err != nil {
+		return err
+	}
 	return w.w.Flush()
 }
 
 // Close finishes writing the opc file.
 // It does not close the underlying writer.
 func (w *Writer) Close() error {
+	if err := w.createPending(); err != nil {
+		w.w.Close()
+		return err
+	}
+	for _, pw := range w.interleaved {
+		if err := pw.Close(); err != nil {
+			w.w.Close()
+			return err
+		}
+	}
 	if err := w.createLastPartRelationships(); err != nil {
 		w.w.Close()
 		return err
@@ -98,10 +184,35 @@ func (w *Writer) Close() error {
 		w.w.Close()
 		return err
 	}
+	if err := w.createExtendedProperties(); err != nil {
+		w.w.Close()
+		return err
+	}
+	if err := w.createCustomProperties(); err != nil {
+		w.w.Close()
+		return err
+	}
+	if len(w.signatures) > 0 {
+		w.addSignatureOriginRelationship()
+	}
 	if err := w.createOwnRelationships(); err != nil {
 		w.w.Close()
 		return err
 	}
+	if err := w.createSignatures(); err != nil {
+		w.w.Close()
+		return err
+	}
+	if err := w.validateThumbnails(); err != nil {
+		w.w.Close()
+		return err
+	}
+	if w.StrictRelationships {
+		if err := w.validateRelationshipTargets(); err != nil {
+			w.w.Close()
+			return err
+		}
+	}
 	if err := w.createContentTypes(); err != nil {
 		w.w.Close()
 		return err
@@ -131,8 +242,268 @@ func (w *Writer) CreatePart(part *Part, compression CompressionOption) (io.Write
 	return w.add(part, compression)
 }
 
+// CreateInterleaved adds an interleaved part to the OPC archive using the provided part.
+// The part contents are stored as a sequence of pieces, as defined in ISO/IEC 29500-2 §10.2.4,
+// so several interleaved parts can be written at the same time.
+// Writer takes ownership of part and may mutate all its fields except the Relationships,
+// which can be modified until the returned PartWriter is closed.
+//
+// The contents written to the returned PartWriter are buffered until it is flushed or closed,
+// which creates a new piece. The contents of a part created with Create or CreatePart
+// shall be completely written before flushing or closing a PartWriter.
+func (w *Writer) CreateInterleaved(part *Part, compression CompressionOption) (*PartWriter, error) {
+	if err := w.createLastPartRelationships(); err != nil {
+		return nil, err
+	}
+	w.last = nil
+	if err := w.p.add(part); err != nil {
+		return nil, err
+	}
+	pw := &PartWriter{w: w, part: part, compression: compression}
+	pw.dst = w.digestPart(part, &pw.buf)
+	w.interleaved = append(w.interleaved, pw)
+	return pw, nil
+}
+
+// Sign requests a package digital signature, as defined in ISO/IEC 29500-2 §13,
+// which will be created when the Writer is closed.
+// The signature value is computed using signer and certs shall contain the signer certificate
+// followed by the rest of the certificate chain, which will be embedded in the signature.
+// RSA and ECDSA signers are supported.
+//
+// The part contents are digested while they are written, so Sign shall be called
+// before creating the parts and relationships covered by the signature.
+// Parts copied by NewWriterFromReader are the exception, as they are digested when the Writer is closed.
+// Sign can be called several times to create multiple signatures.
+func (w *Writer) Sign(signer crypto.Signer, certs []*x509.Certificate, opts *SignatureOptions) error {
+	s := &signatureRequest{signer: signer, certs: certs}
+	if opts != nil {
+		s.opts = *opts
+	}
+	if s.opts.Hash == 0 {
+		s.opts.Hash = crypto.SHA256
+	}
+	if s.opts.PartName == "" {
+		s.opts.PartName = w.newSignaturePartName()
+	}
+	if s.opts.Office != nil {
+		s.opts.Office = s.opts.Office.withDefaults()
+		if s.opts.XAdES == nil {
+			s.opts.XAdES = new(XAdESOptions)
+		}
+	}
+	if signer == nil {
+		return fmt.Errorf("opc: %s: cannot be signed: nil signer", s.opts.PartName)
+	}
+	if err := validatePartName(s.opts.PartName); err != nil {
+		return err
+	}
+	if err := s.validate(); err != nil {
+		return err
+	}
+	w.signatures = append(w.signatures, s)
+	return nil
+}
+
+func (w *Writer) newSignaturePartName() string {
+	var name string
+	for i := len(w.signatures) + 1; ; i++ {
+		name = fmt.Sprintf("%s/sig%d.xml", signatureDefaultDir, i)
+		if !w.p.partExists(name) && !w.isSignaturePartName(name) {
+			break
+		}
+	}
+	return name
+}
+
+func (w *Writer) isSignaturePartName(name string) bool {
+	for _, s := range w.signatures {
+		if PartName(s.opts.PartName).Equal(PartName(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *Writer) signatureOriginName() string {
+	for _, r := range w.Relationships {
+		if strings.EqualFold(r.Type, signatureOriginRel) {
+			return ResolveRelationship("/", r.TargetURI)
+		}
+	}
+	return signatureOriginDefaultName
+}
+
+func (w *Writer) addSignatureOriginRelationship() {
+	for _, r := range w.Relationships {
+		if strings.EqualFold(r.Type, signatureOriginRel) {
+			return
+		}
+	}
+	w.Relationships = append(w.Relationships, &Relationship{
+		Type: signatureOriginRel, TargetURI: signatureOriginDefaultName, TargetMode: ModeInternal,
+	})
+}
+
+func (w *Writer) createSignatures() error {
+	if len(w.signatures) == 0 {
+		return nil
+	}
+	originName := w.signatureOriginName()
+	originRels, err := w.writeSignatures(make([]*Relationship, 0, len(w.signatures)))
+	if err != nil {
+		return err
+	}
+	origin := &Part{Name: originName, ContentType: signatureOriginContentType, Relationships: originRels}
+	if _, err := w.addToPackage(origin, CompressionNormal); err != nil {
+		return err
+	}
+	w.last = origin
+	return w.createLastPartRelationships()
+}
+
+// writeSignatures builds the requested signatures and writes their parts.
+// It returns originRels with the relationships targeting the new signature parts appended.
+func (w *Writer) writeSignatures(originRels []*Relationship) ([]*Relationship, error) {
+	// Build all the signatures before writing them so they don't sign each other.
+	now := w.now()
+	sigs := make([][]byte, len(w.signatures))
+	for i, s := range w.signatures {
+		refs, err := w.signedReferences(s)
+		if err != nil {
+			return nil, err
+		}
+		signingTime := s.opts.SigningTime
+		if signingTime.IsZero() {
+			signingTime = now
+		}
+		node, err := s.buildSignature(refs, signingTime)
+		if err != nil {
+			return nil, err
+		}
+		sigs[i] = node.canonical()
+	}
+	for i, s := range w.signatures {
+		sw, err := w.addToPackage(&Part{Name: s.opts.PartName, ContentType: signatureContentType}, CompressionNormal)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = sw.Write(([]byte)(xml.Header)); err != nil {
+			return nil, err
+		}
+		if _, err = sw.Write(sigs[i]); err != nil {
+			return nil, err
+		}
+		rel := &Relationship{Type: signatureRel, TargetURI: s.opts.PartName, TargetMode: ModeInternal}
+		originRels = append(originRels, rel)
+		rel.ID = newRelationshipID(originRels)
+	}
+	return originRels, nil
+}
+
+// signedReferences returns the references to the parts and relationships parts covered by s.
+func (w *Writer) signedReferences(s *signatureRequest) ([]*signedReference, error) {
+	parts, rels := s.opts.Parts, s.opts.Relationships
+	if len(parts) == 0 && len(rels) == 0 && s.opts.Office != nil {
+		parts, rels = w.officeReferences()
+	} else if len(parts) == 0 && len(rels) == 0 {
+		for _, d := range w.digests {
+			if d.rels == nil {
+				parts = append(parts, d.part.Name)
+			} else {
+				rels = append(rels, SignedRelationships{Source: d.source})
+			}
+		}
+	}
+	refs := make([]*signedReference, 0, len(parts)+len(rels))
+	for _, name := range parts {
+		d, err := w.signedDigest(name)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := d.sum(s.opts.Hash)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, &signedReference{
+			partName:    d.part.Name,
+			contentType: normalizeContentType(d.part.ContentType),
+			digest:      sum,
+		})
+	}
+	for _, sr := range rels {
+		d, err := w.signedDigest(relationshipsPartName(sr.Source))
+		if err != nil {
+			return nil, err
+		}
+		sel := &relationshipSelector{ids: sr.IDs, types: sr.Types}
+		if len(sel.ids) == 0 && len(sel.types) == 0 {
+			for _, r := range d.rels {
+				sel.ids = append(sel.ids, r.ID)
+			}
+		}
+		h := s.opts.Hash.New()
+		h.Write(sel.transform(d.rels))
+		refs = append(refs, &signedReference{
+			partName:    d.part.Name,
+			contentType: relationshipContentType,
+			digest:      h.Sum(nil),
+			selector:    sel,
+		})
+	}
+	return refs, nil
+}
+
+func (w *Writer) signedDigest(name string) (*partDigest, error) {
+	d := w.findDigest(name)
+	if d == nil {
+		if !w.p.partExists(name) {
+			return nil, newError(609, name)
+		}
+		return nil, fmt.Errorf("opc: %s: cannot be signed: the part was created before calling Writer.Sign", name)
+	}
+	return d, nil
+}
+
+// setDigestRelationships stores a copy of the relationships written
+// to the relationships part of source so they can be signed.
+func (w *Writer) setDigestRelationships(source string, rs []*Relationship) {
+	d := w.findDigest(relationshipsPartName(source))
+	if d == nil {
+		// The relationships of a part copied from a Reader can be signed
+		// even if they are written before calling Sign.
+		if src := w.findDigest(source); src == nil || src.file == nil {
+			return
+		}
+		d = &partDigest{part: &Part{Name: relationshipsPartName(source), ContentType: relationshipContentType}}
+		w.digests = append(w.digests, d)
+	}
+	d.source = source
+	d.rels = make([]*Relationship, len(rs))
+	for i, r := range rs {
+		rc := *r
+		d.rels[i] = &rc
+	}
+}
+
+func (w *Writer) findDigest(name string) *partDigest {
+	for _, d := range w.digests {
+		if PartName(d.part.Name).Equal(PartName(name)) {
+			return d
+		}
+	}
+	return nil
+}
+
 func (w *Writer) createCoreProperties() error {
-	if w.Properties == (CoreProperties{}) {
+	if w.UpdateDates {
+		now := w.now().UTC()
+		if w.Properties.Created == "" {
+			w.Properties.SetCreatedTime(now, PrecisionSecond)
+		}
+		w.Properties.SetModifiedTime(now, PrecisionSecond)
+	}
+	if w.Properties == (CoreProperties{}) && w.coreDoc == nil {
 		return nil
 	}
 	partName := w.Properties.PartName
@@ -159,64 +530,204 @@ func (w *Writer) createCoreProperties() error {
 			w.Properties.RelationshipID, corePropsRel, partName, ModeInternal,
 		})
 	}
+	if w.coreDoc != nil {
+		return w.Properties.encodeDocument(cw, w.coreDoc)
+	}
 	return w.Properties.encode(cw)
 }
 
+func (w *Writer) createExtendedProperties() error {
+	if w.ExtendedProperties.isZero() {
+		return nil
+	}
+	partName := w.ExtendedProperties.PartName
+	if partName == "" {
+		partName = extendedPropsDefaultName
+	}
+	part := &Part{Name: ResolveRelationship("/", partName), ContentType: extendedPropsContentType}
+	ew, err := w.addToPackage(part, CompressionNormal)
+	if err != nil {
+		return err
+	}
+	var hasRel bool
+	for _, rel := range w.Relationships {
+		if strings.EqualFold(rel.Type, extendedPropsRel) {
+			hasRel = true
+			break
+		}
+	}
+	if !hasRel {
+		w.Relationships = append(w.Relationships, &Relationship{
+			w.ExtendedProperties.RelationshipID, extendedPropsRel, partName, ModeInternal,
+		})
+	}
+	return w.ExtendedProperties.encode(ew)
+}
+
+func (w *Writer) createCustomProperties() error {
+	if len(w.CustomProperties.props) == 0 {
+		// The relationship copied by NewWriterFromReader would be dangling.
+		if id := w.CustomProperties.RelationshipID; id != "" {
+			rels := w.Relationships[:0]
+			for _, rel := range w.Relationships {
+				if rel.ID != id || !strings.EqualFold(rel.Type, customPropsRel) {
+					rels = append(rels, rel)
+				}
+			}
+			w.Relationships = rels
+		}
+		return nil
+	}
+	partName := w.CustomProperties.PartName
+	if partName == "" {
+		partName = customPropsDefaultName
+	}
+	part := &Part{Name: ResolveRelationship("/", partName), ContentType: customPropsContentType}
+	cw, err := w.addToPackage(part, CompressionNormal)
+	if err != nil {
+		return err
+	}
+	var hasRel bool
+	for _, rel := range w.Relationships {
+		if strings.EqualFold(rel.Type, customPropsRel) {
+			hasRel = true
+			break
+		}
+	}
+	if !hasRel {
+		w.Relationships = append(w.Relationships, &Relationship{
+			w.CustomProperties.RelationshipID, customPropsRel, partName, ModeInternal,
+		})
+	}
+	return w.CustomProperties.encode(cw)
+}
+
 func (w *Writer) createContentTypes() error {
 	// ISO/IEC 29500-2 M3.10
 	fh := &zip.FileHeader{
 		Name:     zipName(contentTypesName),
-		Modified: time.Now(),
+		Modified: w.now(),
 	}
 	w.setCompressor(fh, CompressionNormal)
 	cw, err := w.w.CreateHeader(fh)
 	if err != nil {
 		return err
 	}
-	return w.p.encodeContentTypes(cw)
+	return w.p.encodeContentTypes(cw, w.ContentTypeStrategy)
 }
 
 func (w *Writer) createOwnRelationships() error {
 	if len(w.Relationships) == 0 {
 		return nil
 	}
+	setRelationshipIDs(w.Relationships, w.Deterministic)
+	rels := w.relativizeTargets("/", w.Relationships)
+	if err := validateRelationships("/", rels); err != nil {
+		return err
+	}
+	// ISO/IEC 29500-2 M4.1
+	var hasCoreRel bool
 	for _, r := range w.Relationships {
-		if r.ID == "" {
-			r.ID = newRelationshipID(w.Relationships)
+		if strings.EqualFold(r.Type, corePropsRel) {
+			if hasCoreRel {
+				return newErrorRelationship(401, "/", r.ID)
+			}
+			hasCoreRel = true
 		}
 	}
-	if err := validateRelationships("/", w.Relationships); err != nil {
-		return err
-	}
 	rw, err := w.addToPackage(&Part{Name: packageRelName, ContentType: relationshipContentType}, CompressionNormal)
 	if err != nil {
 		return err
 	}
-	return encodeRelationships(rw, w.Relationships)
+	w.setDigestRelationships("/", rels)
+	w.addThumbnailSource("/", rels)
+	w.addWrittenRelationships("/", rels)
+	return encodeRelationships(rw, w.orderRelationships(rels))
 }
 
 func (w *Writer) createLastPartRelationships() error {
-	if w.last == nil || len(w.last.Relationships) == 0 {
+	if w.last == nil {
 		return nil
 	}
-	for _, r := range w.last.Relationships {
-		if r.ID == "" {
-			r.ID = newRelationshipID(w.last.Relationships)
-		}
+	return w.createPartRelationships(w.last)
+}
+
+func (w *Writer) createPartRelationships(part *Part) error {
+	if len(part.Relationships) == 0 {
+		return nil
 	}
-	if err := validateRelationships(w.last.Name, w.last.Relationships); err != nil {
+	setRelationshipIDs(part.Relationships, w.Deterministic)
+	rels := w.relativizeTargets(part.Name, part.Relationships)
+	if err := validateRelationships(part.Name, rels); err != nil {
 		return err
 	}
-	dirName := path.Dir(w.last.Name)[1:]
-	if dirName != "" {
-		dirName = "/" + dirName
-	}
-	relName := fmt.Sprintf("%s/_rels/%s.rels", dirName, path.Base(w.last.Name))
+	relName := string(PartName(part.Name).RelationshipsPartName())
 	rw, err := w.addToPackage(&Part{Name: relName, ContentType: relationshipContentType}, CompressionNormal)
 	if err != nil {
 		return err
 	}
-	return encodeRelationships(rw, w.last.Relationships)
+	w.setDigestRelationships(part.Name, rels)
+	w.addThumbnailSource(part.Name, rels)
+	w.addWrittenRelationships(part.Name, rels)
+	return encodeRelationships(rw, w.orderRelationships(rels))
+}
+
+// relativizeTargets returns the relationships to be written for rels, whose absolute internal targets
+// are replaced by their URI relative to source if RelativeTargets is true.
+// The relationships of rels are not modified, as they are owned by the caller.
+func (w *Writer) relativizeTargets(source string, rels []*Relationship) []*Relationship {
+	if !w.RelativeTargets {
+		return rels
+	}
+	written := make([]*Relationship, len(rels))
+	for i, rel := range rels {
+		written[i] = rel
+		if rel.TargetMode != ModeInternal || !strings.HasPrefix(strings.TrimSpace(rel.TargetURI), "/") {
+			continue
+		}
+		if target := RelativeRelationship(source, rel.TargetURI); target != "" {
+			relCopy := *rel
+			relCopy.TargetURI = target
+			written[i] = &relCopy
+		}
+	}
+	return written
+}
+
+// addWrittenRelationships records the relationships written to the relationships part of source.
+func (w *Writer) addWrittenRelationships(source string, rels []*Relationship) {
+	for _, rel := range rels {
+		w.rels = append(w.rels, SourcedRelationship{source, rel})
+	}
+}
+
+// validateRelationshipTargets checks that the written internal relationships target written parts.
+func (w *Writer) validateRelationshipTargets() error {
+	return validateRelationshipTargets(w.rels, func(name string) bool {
+		return w.p.partExists(name)
+	})
+}
+
+// deterministicModTime is the default ModTime, the earliest date that can be stored in a ZIP file.
+var deterministicModTime = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// now returns the current time, or ModTime if the Writer is deterministic.
+func (w *Writer) now() time.Time {
+	if !w.Deterministic {
+		return time.Now()
+	}
+	if w.ModTime.IsZero() {
+		return deterministicModTime
+	}
+	return w.ModTime
+}
+
+// orderRelationships returns rels in the order they are written.
+func (w *Writer) orderRelationships(rels []*Relationship) []*Relationship {
+	if w.Deterministic {
+		return sortRelationshipsByID(rels)
+	}
+	return rels
 }
 
 func (w *Writer) add(part *Part, compression CompressionOption) (io.Writer, error) {
@@ -231,13 +742,16 @@ func (w *Writer) add(part *Part, compression CompressionOption) (io.Writer, erro
 }
 
 func (w *Writer) addToPackage(part *Part, compression CompressionOption) (io.Writer, error) {
+	if err := w.createPending(); err != nil {
+		return nil, err
+	}
 	// Validate name and check for duplicated names ISO/IEC 29500-2 M3.3
 	if err := w.p.add(part); err != nil {
 		return nil, err
 	}
 	fh := &zip.FileHeader{
 		Name:     zipName(part.Name),
-		Modified: time.Now(),
+		Modified: w.now(),
 	}
 	w.setCompressor(fh, compression)
 	pw, err := w.w.CreateHeader(fh)
@@ -245,7 +759,26 @@ func (w *Writer) addToPackage(part *Part, compression CompressionOption) (io.Wri
 		w.p.deletePart(part.Name)
 		return nil, fmt.Errorf("opc: %s: cannot be created: %v", part.Name, err)
 	}
-	return pw, nil
+	return w.digestPart(part, pw), nil
+}
+
+// digestPart returns a writer that digests the part contents written to pw
+// using the hash algorithms of the requested signatures.
+func (w *Writer) digestPart(part *Part, pw io.Writer) io.Writer {
+	if len(w.signatures) == 0 {
+		return pw
+	}
+	d := &partDigest{part: part, hashes: make(map[crypto.Hash]hash.Hash)}
+	writers := []io.Writer{pw}
+	for _, s := range w.signatures {
+		if _, ok := d.hashes[s.opts.Hash]; !ok {
+			h := s.opts.Hash.New()
+			d.hashes[s.opts.Hash] = h
+			writers = append(writers, h)
+		}
+	}
+	w.digests = append(w.digests, d)
+	return io.MultiWriter(writers...)
 }
 
 func (w *Writer) setCompressor(fh *zip.FileHeader, compression CompressionOption) {
@@ -3,7 +3,10 @@ package opc
 import (
 	"archive/zip"
 	"bytes"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestWriter_Flush(t *testing.T) {
@@ -28,9 +31,9 @@ func TestWriter_Close(t *testing.T) {
 	p.contentTypes.add("/a.xml", "a/b")
 	p.contentTypes.add("/b.xml", "c/d")
 	pCore := newPackage()
-	pCore.parts["/PROPS/CORE.XML"] = struct{}{}
+	pCore.parts["/PROPS/CORE.XML"] = packagePart{"/props/core.xml", corePropsContentType}
 	pRel := newPackage()
-	pRel.parts["/_RELS/.RELS"] = struct{}{}
+	pRel.parts["/_RELS/.RELS"] = packagePart{"/_rels/.rels", relationshipContentType}
 	tests := []struct {
 		name    string
 		w       *Writer
@@ -43,6 +46,10 @@ func TestWriter_Close(t *testing.T) {
 		{"withDuplicatedCoreProps", &Writer{p: pCore, w: zip.NewWriter(&bytes.Buffer{}), Properties: CoreProperties{Title: "Song"}}, true},
 		{"withDuplicatedRels", &Writer{p: pRel, w: zip.NewWriter(&bytes.Buffer{}), Properties: CoreProperties{Title: "Song"}}, true},
 		{"withCoreProps", &Writer{p: newPackage(), w: zip.NewWriter(&bytes.Buffer{}), Properties: CoreProperties{Title: "Song"}}, false},
+		{"withDuplicatedCorePropsRel", &Writer{p: newPackage(), w: zip.NewWriter(&bytes.Buffer{}), Relationships: []*Relationship{
+			{ID: "rId1", TargetURI: "props.xml", Type: corePropsRel}, {ID: "rId2", TargetURI: "props2.xml", Type: corePropsRel},
+		}, Properties: CoreProperties{Title: "Song", PartName: "props.xml"}}, true},
+		{"withInvalidCorePropsDate", &Writer{p: newPackage(), w: zip.NewWriter(&bytes.Buffer{}), Properties: CoreProperties{Created: "today"}}, true},
 		{"withCorePropsWithName", &Writer{p: newPackage(), w: zip.NewWriter(&bytes.Buffer{}), Relationships: []*Relationship{
 			{TargetURI: "props.xml", Type: corePropsRel},
 		}, Properties: CoreProperties{Title: "Song", PartName: "props.xml"}}, false},
@@ -57,6 +64,43 @@ func TestWriter_Close(t *testing.T) {
 	}
 }
 
+func TestWriter_UpdateDates(t *testing.T) {
+	tests := []struct {
+		name        string
+		props       CoreProperties
+		wantCreated string
+	}{
+		{"empty", CoreProperties{}, ""},
+		{"created", CoreProperties{Created: "2015-06-05T18:19:34Z", Modified: "2016"}, "2015-06-05T18:19:34Z"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.Properties = tt.props
+			w.UpdateDates = true
+			before := time.Now().Truncate(time.Second)
+			if err := w.Close(); err != nil {
+				t.Fatalf("Writer.Close() error = %v", err)
+			}
+			r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			modified, err := r.Properties.ModifiedTime()
+			if err != nil || modified.Before(before) || modified.After(time.Now()) {
+				t.Errorf("CoreProperties.ModifiedTime() = (%v, %v), want current time", modified, err)
+			}
+			if tt.wantCreated == "" {
+				tt.wantCreated = r.Properties.Modified
+			}
+			if r.Properties.Created != tt.wantCreated {
+				t.Errorf("CoreProperties.Created = %s, want %s", r.Properties.Created, tt.wantCreated)
+			}
+		})
+	}
+}
+
 func TestWriter_setCompressor(t *testing.T) {
 	type args struct {
 		fh          *zip.FileHeader
@@ -133,7 +177,7 @@ func TestWriter_CreatePart(t *testing.T) {
 	rel := &Relationship{ID: "fakeId", Type: "asd", TargetURI: "/fakeTarget", TargetMode: ModeInternal}
 	w := NewWriter(&bytes.Buffer{})
 	pRel := newPackage()
-	pRel.parts["/_RELS/A.XML.RELS"] = struct{}{}
+	pRel.parts["/_RELS/A.XML.RELS"] = packagePart{"/_rels/a.xml.rels", relationshipContentType}
 	type args struct {
 		part        *Part
 		compression CompressionOption
@@ -213,3 +257,239 @@ func TestNewWriterFromReader(t *testing.T) {
 		t.Errorf("NewWriterFromReader() created package that cannot be closed: %v", err)
 	}
 }
+
+func TestNewWriterFromReader_CoreProperties(t *testing.T) {
+	core := `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:x="urn:x">` +
+		`<dc:title xml:lang="en">a</dc:title><x:custom>b</x:custom></cp:coreProperties>`
+	b := newTestZip(t,
+		zipItem{"[Content_Types].xml", new(cTypeBuilder).withDefault(relationshipContentType, "rels").withOverride(corePropsContentType, "/meta/core.xml").String()},
+		zipItem{"_rels/.rels", new(relsBuilder).withRel("rIdCore", corePropsRel, "meta/core.xml").String()},
+		zipItem{"meta/core.xml", core},
+	)
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	var buf bytes.Buffer
+	w, _ := NewWriterFromReader(&buf, r)
+	w.Properties.Creator = "c"
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	r, err = NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if r.Properties.PartName != "meta/core.xml" || r.Properties.RelationshipID != "rIdCore" {
+		t.Errorf("Reader.Properties = (%s, %s), want (meta/core.xml, rIdCore)", r.Properties.PartName, r.Properties.RelationshipID)
+	}
+	if r.Properties.Title != "a" || r.Properties.Creator != "c" {
+		t.Errorf("Reader.Properties = (%s, %s), want (a, c)", r.Properties.Title, r.Properties.Creator)
+	}
+	want := strings.Replace(core, "</cp:coreProperties>", "<dc:creator>c</dc:creator></cp:coreProperties>", 1)
+	if got := string(r.coreDoc); got != want {
+		t.Errorf("Writer.Close() core properties = %s, want %s", got, want)
+	}
+}
+
+func TestWriter_ContentTypeStrategy(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy ContentTypeStrategy
+		want     ContentTypes
+	}{
+		{"automatic", ContentTypeAutomatic, ContentTypes{
+			map[string]string{"rels": relationshipContentType, "xml": "application/xml"},
+			map[string]string{"/b.xml": "x/y", "/c.xml": "x/y", "/d": "a/b"},
+		}},
+		{"overridesOnly", ContentTypeOverridesOnly, ContentTypes{
+			map[string]string{},
+			map[string]string{"/a.xml": "application/xml", "/b.xml": "x/y", "/c.xml": "x/y", "/d": "a/b"},
+		}},
+		{"optimal", ContentTypeOptimal, ContentTypes{
+			map[string]string{"xml": "x/y"},
+			map[string]string{"/a.xml": "application/xml", "/d": "a/b"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			w := NewWriter(&buf)
+			w.ContentTypeStrategy = tt.strategy
+			w.Create("/a.xml", "application/xml")
+			w.Create("/b.xml", "x/y")
+			w.Create("/c.xml", "x/y")
+			w.Create("/d", "a/b")
+			if got := w.ContentTypes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Writer.ContentTypes() = %v, want %v", got, tt.want)
+			}
+			if err := w.Close(); err != nil {
+				t.Fatalf("Writer.Close() error = %v", err)
+			}
+			r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			if got := r.ContentTypes(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Reader.ContentTypes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriter_Deterministic(t *testing.T) {
+	modTime := time.Date(2020, 5, 6, 7, 8, 10, 0, time.UTC)
+	write := func(reverse bool) []byte {
+		var buf bytes.Buffer
+		w := NewWriter(&buf)
+		w.Deterministic = true
+		w.ModTime = modTime
+		w.UpdateDates = true
+		rels := []*Relationship{
+			{Type: "http://a.com/b", TargetURI: "/b.xml"},
+			{Type: "http://a.com/a", TargetURI: "/a.xml"},
+			{ID: "rIdFixed", Type: "http://a.com/c", TargetURI: "/c.xml"},
+		}
+		if reverse {
+			rels[0], rels[2] = rels[2], rels[0]
+		}
+		w.Relationships = rels
+		for _, name := range []string{"/a.xml", "/b.xml", "/c.xml", "/d"} {
+			pw, _ := w.Create(name, "application/xml")
+			pw.Write([]byte("<a/>"))
+		}
+		w.CustomProperties.Set("a", 1)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Writer.Close() error = %v", err)
+		}
+		return buf.Bytes()
+	}
+	b := write(false)
+	if !bytes.Equal(b, write(true)) {
+		t.Error("Writer.Close() wrote different bytes for the same package")
+	}
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("zip.NewReader() error = %v", err)
+	}
+	for _, f := range zr.File {
+		if !f.Modified.Equal(modTime) {
+			t.Errorf("%s modification time = %v, want %v", f.Name, f.Modified, modTime)
+		}
+	}
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if r.Properties.Modified != "2020-05-06T07:08:10Z" {
+		t.Errorf("CoreProperties.Modified = %s, want the ModTime", r.Properties.Modified)
+	}
+	var ids []string
+	for _, rel := range r.Relationships {
+		ids = append(ids, rel.ID+" "+rel.TargetURI)
+	}
+	want := []string{"rId0 /a.xml", "rId1 /b.xml", "rId2 /docProps/custom.xml", "rId3 /props/core.xml", "rIdFixed /c.xml"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("Reader.Relationships = %v, want %v", ids, want)
+	}
+}
+
+func TestWriter_StrictRelationships(t *testing.T) {
+	rel := func(id, target string) *Relationship {
+		return &Relationship{ID: id, Type: "http://a.com/b", TargetURI: target}
+	}
+	tests := []struct {
+		name     string
+		rels     []*Relationship
+		partRels []*Relationship
+		wantPart string
+		wantID   string
+	}{
+		{"valid", []*Relationship{rel("rId1", "/a.xml")}, []*Relationship{rel("rId1", "b.xml")}, "", ""},
+		{"forward", nil, []*Relationship{rel("rId1", "/b.xml"), rel("rId2", "./b.xml#frag")}, "", ""},
+		{"self", nil, []*Relationship{rel("rId1", "a.xml")}, "", ""},
+		{"external", nil, []*Relationship{{ID: "rId1", Type: "http://a.com/b", TargetURI: "http://a.com", TargetMode: ModeExternal}}, "", ""},
+		{"danglingPackage", []*Relationship{rel("rId1", "/a.xml"), rel("rId2", "/c.xml")}, nil, "/", "rId2"},
+		{"danglingPart", nil, []*Relationship{rel("rId1", "b.xml"), rel("rId2", "c.xml#frag")}, "/a.xml", "rId2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewWriter(&bytes.Buffer{})
+			w.StrictRelationships = true
+			w.Properties.Title = "Song"
+			w.Relationships = tt.rels
+			w.CreatePart(&Part{Name: "/a.xml", ContentType: "application/xml", Relationships: tt.partRels}, CompressionNormal)
+			w.Create("/b.xml", "application/xml")
+			err := w.Close()
+			if tt.wantPart == "" {
+				if err != nil {
+					t.Errorf("Writer.Close() error = %v", err)
+				}
+				return
+			}
+			e, ok := err.(*Error)
+			if !ok || e.Code() != 130 || e.PartName() != tt.wantPart || e.RelationshipID() != tt.wantID {
+				t.Errorf("Writer.Close() error = %v, want 130 for %s#%s", err, tt.wantPart, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestWriter_RelativeTargets(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.RelativeTargets = true
+	w.StrictRelationships = true
+	w.Properties.Title = "Song"
+	w.Relationships = []*Relationship{{ID: "rId1", Type: "http://a.com/doc", TargetURI: "/docs/a.xml"}}
+	partRels := []*Relationship{
+		{ID: "rId1", Type: "http://a.com/b", TargetURI: "/media/image.png"},
+		{ID: "rId2", Type: "http://a.com/b", TargetURI: "/docs/b.xml#frag"},
+		{ID: "rId3", Type: "http://a.com/b", TargetURI: "b.xml"},
+		{ID: "rId4", Type: "http://a.com/b", TargetURI: "/c.xml", TargetMode: ModeExternal},
+	}
+	w.CreatePart(&Part{Name: "/docs/a.xml", ContentType: "application/xml", Relationships: partRels}, CompressionNormal)
+	w.Create("/docs/b.xml", "application/xml")
+	w.Create("/media/image.png", "image/png")
+	if err := w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	if w.Relationships[0].TargetURI != "/docs/a.xml" || partRels[0].TargetURI != "/media/image.png" || partRels[1].TargetURI != "/docs/b.xml#frag" {
+		t.Errorf("Writer.RelativeTargets modified the caller relationships: %v, %v", w.Relationships, partRels)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	var got []string
+	for _, rel := range r.Relationships {
+		got = append(got, rel.TargetURI)
+	}
+	for _, rel := range r.file("/docs/a.xml").Relationships {
+		got = append(got, rel.TargetURI)
+	}
+	want := []string{"docs/a.xml", "props/core.xml", "../media/image.png", "b.xml#frag", "b.xml", "/c.xml"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Writer.RelativeTargets wrote %v, want %v", got, want)
+	}
+
+	// The relationships of a copied Reader are not modified either.
+	b := newTestZip(t,
+		zipItem{"[Content_Types].xml", new(cTypeBuilder).withDefault(relationshipContentType, "rels").withDefault("application/xml", "xml").String()},
+		zipItem{"_rels/.rels", new(relsBuilder).withRel("rId1", "http://a.com/doc", "/docs/a.xml").String()},
+		zipItem{"docs/a.xml", "<a/>"},
+	)
+	r, err = NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	buf.Reset()
+	w, _ = NewWriterFromReader(&buf, r)
+	w.RelativeTargets = true
+	if err = w.Close(); err != nil {
+		t.Fatalf("Writer.Close() error = %v", err)
+	}
+	if got := r.Relationships[0].TargetURI; got != "/docs/a.xml" {
+		t.Errorf("Writer.RelativeTargets modified the Reader relationships: %s", got)
+	}
+}
@@ -0,0 +1,310 @@
+package opc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/qmuntal/opc/c14n"
+)
+
+const (
+	xadesNamespace          = "http://uri.etsi.org/01903/v1.3.2#"
+	signedPropertiesType    = "http://uri.etsi.org/01903#SignedProperties"
+	signedPropertiesID      = "idSignedProperties"
+	xadesSigningTimeLayout  = "2006-01-02T15:04:05Z07:00"
+	xadesSignatureTimestamp = "idSignatureTimestamp"
+)
+
+// Commitment types defined in ETSI TS 119 172-1, which can be indicated in a XAdES signature.
+const (
+	CommitmentProofOfOrigin   = "http://uri.etsi.org/01903/v1.2.2#ProofOfOrigin"
+	CommitmentProofOfReceipt  = "http://uri.etsi.org/01903/v1.2.2#ProofOfReceipt"
+	CommitmentProofOfDelivery = "http://uri.etsi.org/01903/v1.2.2#ProofOfDelivery"
+	CommitmentProofOfSender   = "http://uri.etsi.org/01903/v1.2.2#ProofOfSender"
+	CommitmentProofOfApproval = "http://uri.etsi.org/01903/v1.2.2#ProofOfApproval"
+	CommitmentProofOfCreation = "http://uri.etsi.org/01903/v1.2.2#ProofOfCreation"
+)
+
+// XAdESOptions defines the XAdES qualifying properties added to a package signature,
+// as specified in ETSI EN 319 132-1.
+// The signature always includes the SigningTime and SigningCertificateV2 signed properties,
+// or SigningCertificate when using the Office layout,
+// which makes it a XAdES-BES signature.
+type XAdESOptions struct {
+	CommitmentType     string             // URI of the commitment type indicated for all the signed data, such as CommitmentProofOfOrigin. If empty it is not indicated.
+	TimestampAuthority TimestampAuthority // If not nil the signature value is timestamped, which makes it a XAdES-T signature.
+}
+
+// XAdESProperties holds the XAdES qualifying properties of a package signature read by the Reader.
+type XAdESProperties struct {
+	SigningTime    time.Time  // The claimed signing time stated in the SigningTime signed property.
+	CommitmentType string     // The commitment type identifier. Empty if not indicated.
+	Timestamp      *Timestamp // The signature timestamp. Nil if the signature does not have one.
+
+	// Validation data of XAdES-X-L signatures, which is not signed.
+	// The values that cannot be decoded are ignored.
+	CertificateValues []*x509.Certificate // Certificates of the signer chain stored in the CertificateValues property.
+	CRLValues         [][]byte            // DER encoded CRLs stored in the RevocationValues property.
+	OCSPValues        [][]byte            // DER encoded OCSP responses stored in the RevocationValues property.
+}
+
+// Timestamp is a RFC 3161 time-stamp token over the signature value of a package signature.
+type Timestamp struct {
+	Time         time.Time           // The time at which the token was issued by the time-stamping authority.
+	Certificates []*x509.Certificate // The certificates embedded in the token.
+	Err          error               // The reason why the token does not match the signature. Nil if it matches.
+}
+
+// Valid reports whether the token matches the signature value and its signature is valid.
+func (t *Timestamp) Valid() bool {
+	return t.Err == nil
+}
+
+// newQualifyingPropertiesNode creates the XAdES QualifyingProperties of a signature,
+// whose SignedProperties shall be referenced from the SignedInfo.
+func (s *signatureRequest) newQualifyingPropertiesNode(signingTime time.Time) (*xmlNode, error) {
+	cert := s.certs[0]
+	issuerSerial, err := marshalIssuerSerial(cert)
+	if err != nil {
+		return nil, fmt.Errorf("opc: %s: cannot encode the signer certificate issuer: %v", s.opts.PartName, err)
+	}
+	certDigest := newXMLNode("xd:CertDigest").add(newDigestNodes(s.opts.Hash, digest(s.opts.Hash, cert.Raw))...)
+	sigProps := newXMLNode("xd:SignedSignatureProperties").add(
+		newXMLTextNode("xd:SigningTime", signingTime.UTC().Format(xadesSigningTimeLayout)),
+	)
+	if s.opts.Office != nil {
+		// Office only understands the XAdES 1.3.2 SigningCertificate property
+		// and expects an implied signature policy.
+		sigProps.add(
+			newXMLNode("xd:SigningCertificate").add(
+				newXMLNode("xd:Cert").add(
+					certDigest,
+					newXMLNode("xd:IssuerSerial").add(
+						newXMLTextNode("X509IssuerName", cert.Issuer.String()),
+						newXMLTextNode("X509SerialNumber", cert.SerialNumber.String()),
+					),
+				),
+			),
+			newXMLNode("xd:SignaturePolicyIdentifier").add(newXMLNode("xd:SignaturePolicyImplied")),
+		)
+	} else {
+		sigProps.add(newXMLNode("xd:SigningCertificateV2").add(
+			newXMLNode("xd:Cert").add(
+				certDigest,
+				newXMLTextNode("xd:IssuerSerialV2", base64.StdEncoding.EncodeToString(issuerSerial)),
+			),
+		))
+	}
+	props := newXMLNode("xd:SignedProperties", "Id", signedPropertiesID).add(sigProps)
+	if s.opts.XAdES.CommitmentType != "" {
+		props.add(newXMLNode("xd:SignedDataObjectProperties").add(
+			newXMLNode("xd:CommitmentTypeIndication").add(
+				newXMLNode("xd:CommitmentTypeId").add(newXMLTextNode("xd:Identifier", s.opts.XAdES.CommitmentType)),
+				newXMLNode("xd:AllSignedDataObjects"),
+			),
+		))
+	}
+	return newXMLNode("xd:QualifyingProperties", "xmlns:xd", xadesNamespace, "Target", "#"+packageSignatureID).add(props), nil
+}
+
+// marshalIssuerSerial returns the DER encoded IssuerSerial structure defined in RFC 5035
+// that identifies cert.
+func marshalIssuerSerial(cert *x509.Certificate) ([]byte, error) {
+	return asn1.Marshal(struct {
+		Issuer []asn1.RawValue
+		Serial *big.Int
+	}{
+		Issuer: []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: cert.RawIssuer}},
+		Serial: cert.SerialNumber,
+	})
+}
+
+// timestamp requests a signature timestamp over the canonicalized SignatureValue element
+// and adds it to the unsigned properties of qualifyingProps.
+func (s *signatureRequest) timestamp(qualifyingProps, sigValue *xmlNode, dsigNS xml.Attr) error {
+	canonical := sigValue.canonical(dsigNS)
+	token, err := s.opts.XAdES.TimestampAuthority.Timestamp(digest(s.opts.Hash, canonical), s.opts.Hash)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be timestamped: %v", s.opts.PartName, err)
+	}
+	t, err := parseTimeStampToken(token)
+	if err != nil {
+		return fmt.Errorf("opc: %s: cannot be timestamped: %v", s.opts.PartName, err)
+	}
+	if !t.matches(canonical) {
+		return fmt.Errorf("opc: %s: cannot be timestamped: the token does not match the signature value", s.opts.PartName)
+	}
+	qualifyingProps.add(newXMLNode("xd:UnsignedProperties").add(
+		newXMLNode("xd:UnsignedSignatureProperties").add(
+			newXMLNode("xd:SignatureTimeStamp", "Id", xadesSignatureTimestamp).add(
+				newXMLNode("CanonicalizationMethod", "Algorithm", c14nAlgorithm),
+				newXMLTextNode("xd:EncapsulatedTimeStamp", base64.StdEncoding.EncodeToString(token)),
+			),
+		),
+	))
+	return nil
+}
+
+// verifyXAdES returns the XAdES qualifying properties of sig, if any.
+// The SignedProperties element shall be referenced from the SignedInfo and
+// its signing certificate shall identify the signer certificate.
+func verifyXAdES(s *Signature, sig, signedInfo, sigValue *xmlElement) (*XAdESProperties, error) {
+	var qualifying *xmlElement
+	for _, object := range sig.elements(xmldsigNamespace, "Object") {
+		if qualifying = object.element(xadesNamespace, "QualifyingProperties"); qualifying != nil {
+			break
+		}
+	}
+	if qualifying == nil {
+		return nil, nil
+	}
+	signedProps := qualifying.element(xadesNamespace, "SignedProperties")
+	if signedProps == nil {
+		return nil, newError(617, s.PartName)
+	}
+	id, _ := signedProps.attr("Id")
+	signed := false
+	for _, ref := range signedInfo.elements(xmldsigNamespace, "Reference") {
+		if uri, _ := ref.attr("URI"); id != "" && uri == "#"+id {
+			signed = true
+		}
+	}
+	sigProps := signedProps.element(xadesNamespace, "SignedSignatureProperties")
+	if !signed || sigProps == nil {
+		return nil, newError(617, s.PartName)
+	}
+	props := new(XAdESProperties)
+	if st := sigProps.element(xadesNamespace, "SigningTime"); st != nil {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(st.text()))
+		if err != nil {
+			return nil, newError(617, s.PartName)
+		}
+		props.SigningTime = t
+	}
+	if !signingCertificateMatches(sigProps, s.Certificate) {
+		return nil, newError(617, s.PartName)
+	}
+	if dataProps := signedProps.element(xadesNamespace, "SignedDataObjectProperties"); dataProps != nil {
+		if ind := dataProps.element(xadesNamespace, "CommitmentTypeIndication"); ind != nil {
+			if typeID := ind.element(xadesNamespace, "CommitmentTypeId"); typeID != nil {
+				if ident := typeID.element(xadesNamespace, "Identifier"); ident != nil {
+					props.CommitmentType = strings.TrimSpace(ident.text())
+				}
+			}
+		}
+	}
+	if unsigned := qualifying.element(xadesNamespace, "UnsignedProperties"); unsigned != nil {
+		if sigUnsigned := unsigned.element(xadesNamespace, "UnsignedSignatureProperties"); sigUnsigned != nil {
+			if ts := sigUnsigned.element(xadesNamespace, "SignatureTimeStamp"); ts != nil {
+				props.Timestamp = verifyTimestamp(s.PartName, ts, sigValue)
+			}
+			props.loadValidationData(sigUnsigned)
+		}
+	}
+	return props, nil
+}
+
+// loadValidationData reads the certificate and revocation values of the unsigned signature properties.
+func (props *XAdESProperties) loadValidationData(sigUnsigned *xmlElement) {
+	if values := sigUnsigned.element(xadesNamespace, "CertificateValues"); values != nil {
+		for _, der := range encapsulatedValues(values, "EncapsulatedX509Certificate") {
+			if cert, err := x509.ParseCertificate(der); err == nil {
+				props.CertificateValues = append(props.CertificateValues, cert)
+			}
+		}
+	}
+	if values := sigUnsigned.element(xadesNamespace, "RevocationValues"); values != nil {
+		if crls := values.element(xadesNamespace, "CRLValues"); crls != nil {
+			props.CRLValues = encapsulatedValues(crls, "EncapsulatedCRLValue")
+		}
+		if ocsps := values.element(xadesNamespace, "OCSPValues"); ocsps != nil {
+			props.OCSPValues = encapsulatedValues(ocsps, "EncapsulatedOCSPValue")
+		}
+	}
+}
+
+// encapsulatedValues returns the base64 decoded content of the children of e named local.
+func encapsulatedValues(e *xmlElement, local string) [][]byte {
+	var values [][]byte
+	for _, v := range e.elements(xadesNamespace, local) {
+		if der, err := decodeBase64(v.text()); err == nil {
+			values = append(values, der)
+		}
+	}
+	return values
+}
+
+// signingCertificateMatches reports whether the SigningCertificateV2 or SigningCertificate
+// property of sigProps has the digest of cert.
+func signingCertificateMatches(sigProps *xmlElement, cert *x509.Certificate) bool {
+	signingCert := sigProps.element(xadesNamespace, "SigningCertificateV2")
+	if signingCert == nil {
+		signingCert = sigProps.element(xadesNamespace, "SigningCertificate")
+	}
+	if signingCert == nil {
+		return false
+	}
+	for _, c := range signingCert.elements(xadesNamespace, "Cert") {
+		certDigest := c.element(xadesNamespace, "CertDigest")
+		if certDigest == nil {
+			continue
+		}
+		method := certDigest.element(xmldsigNamespace, "DigestMethod")
+		value := certDigest.element(xmldsigNamespace, "DigestValue")
+		if method == nil || value == nil {
+			continue
+		}
+		alg, _ := method.attr("Algorithm")
+		hash, ok := hashByAlgorithm(digestAlgorithms, alg)
+		want, err := decodeBase64(value.text())
+		if !ok || err != nil {
+			continue
+		}
+		if bytes.Equal(digest(hash, cert.Raw), want) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTimestamp checks that the token of a SignatureTimeStamp element is a valid
+// time-stamp token over the canonicalized SignatureValue element.
+func verifyTimestamp(partName string, ts, sigValue *xmlElement) *Timestamp {
+	t := new(Timestamp)
+	m, prefixes := c14n.Canonical, []string(nil)
+	if method := ts.element(xmldsigNamespace, "CanonicalizationMethod"); method != nil {
+		var ok bool
+		if m, prefixes, ok = canonicalizationMethod(method); !ok {
+			t.Err = newError(618, partName)
+			return t
+		}
+	}
+	encapsulated := ts.element(xadesNamespace, "EncapsulatedTimeStamp")
+	if encapsulated == nil {
+		t.Err = newError(618, partName)
+		return t
+	}
+	der, err := decodeBase64(encapsulated.text())
+	if err != nil {
+		t.Err = newError(618, partName)
+		return t
+	}
+	token, err := parseTimeStampToken(der)
+	if err != nil {
+		t.Err = newError(618, partName)
+		return t
+	}
+	t.Time, t.Certificates = token.GenTime, token.certs
+	canonical, err := canonicalize(sigValue, m, prefixes...)
+	if err != nil || !token.matches(canonical) || token.verify() != nil {
+		t.Err = newError(618, partName)
+	}
+	return t
+}
@@ -0,0 +1,152 @@
+package opc
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+)
+
+type failingTSA struct {
+	err error
+}
+
+func (f failingTSA) Timestamp([]byte, crypto.Hash) ([]byte, error) {
+	return nil, f.err
+}
+
+// wrongImprintTSA issues tokens over a different digest.
+type wrongImprintTSA struct {
+	*testTSA
+}
+
+func (w wrongImprintTSA) Timestamp(digest []byte, hash crypto.Hash) ([]byte, error) {
+	other := sha256.Sum256(digest)
+	return w.testTSA.Timestamp(other[:], hash)
+}
+
+func TestReader_Signatures_XAdES(t *testing.T) {
+	tsa := newTestTSA(t)
+	signingTime := time.Date(2021, 5, 6, 7, 8, 9, 0, time.UTC)
+	tests := []struct {
+		name           string
+		opts           *XAdESOptions
+		wantCommitment string
+		wantTimestamp  bool
+	}{
+		{"bes", &XAdESOptions{}, "", false},
+		{"commitment", &XAdESOptions{CommitmentType: CommitmentProofOfApproval}, CommitmentProofOfApproval, false},
+		{"timestamp", &XAdESOptions{CommitmentType: CommitmentProofOfOrigin, TimestampAuthority: tsa}, CommitmentProofOfOrigin, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newSignedPackage(t, newTestRSAKey(t), &SignatureOptions{SigningTime: signingTime, XAdES: tt.opts})
+			r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+			if err != nil {
+				t.Fatalf("NewReader() error = %v", err)
+			}
+			s := r.Signatures[0]
+			if !s.Valid() {
+				t.Fatalf("Signature.Valid() error = %v", s.Err)
+			}
+			if s.XAdES == nil {
+				t.Fatal("Signature.XAdES = nil")
+			}
+			if !s.XAdES.SigningTime.Equal(signingTime) {
+				t.Errorf("XAdESProperties.SigningTime = %v, want %v", s.XAdES.SigningTime, signingTime)
+			}
+			if s.XAdES.CommitmentType != tt.wantCommitment {
+				t.Errorf("XAdESProperties.CommitmentType = %v, want %v", s.XAdES.CommitmentType, tt.wantCommitment)
+			}
+			if (s.XAdES.Timestamp != nil) != tt.wantTimestamp {
+				t.Fatalf("XAdESProperties.Timestamp = %v, want %v", s.XAdES.Timestamp, tt.wantTimestamp)
+			}
+			if tt.wantTimestamp {
+				ts := s.XAdES.Timestamp
+				if !ts.Valid() {
+					t.Errorf("Timestamp.Valid() error = %v", ts.Err)
+				}
+				if !ts.Time.Equal(tsa.time) {
+					t.Errorf("Timestamp.Time = %v, want %v", ts.Time, tsa.time)
+				}
+				if len(ts.Certificates) != 1 {
+					t.Errorf("Timestamp.Certificates = %d, want 1", len(ts.Certificates))
+				}
+			}
+		})
+	}
+}
+
+func TestReader_Signatures_XAdESNotSigned(t *testing.T) {
+	b := newSignedPackage(t, newTestRSAKey(t), nil)
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	if r.Signatures[0].XAdES != nil {
+		t.Error("Signature.XAdES want nil")
+	}
+}
+
+func TestReader_Signatures_TimestampMismatch(t *testing.T) {
+	tsa := newTestTSA(t)
+	b1 := newSignedPackage(t, newTestRSAKey(t), &SignatureOptions{XAdES: &XAdESOptions{TimestampAuthority: tsa}})
+	b2 := newSignedPackage(t, newTestRSAKey(t), &SignatureOptions{XAdES: &XAdESOptions{TimestampAuthority: tsa}})
+	tokenRe := regexp.MustCompile(`<xd:EncapsulatedTimeStamp>[^<]*</xd:EncapsulatedTimeStamp>`)
+	var otherToken []byte
+	rewriteZip(t, b2, func(name string, content []byte) []byte {
+		if name == "_xmlsignatures/sig1.xml" {
+			otherToken = tokenRe.Find(content)
+		}
+		return content
+	})
+	// The unsigned properties can be replaced without invalidating the signature.
+	b := rewriteZip(t, b1, func(name string, content []byte) []byte {
+		if name == "_xmlsignatures/sig1.xml" {
+			return tokenRe.ReplaceAll(content, otherToken)
+		}
+		return content
+	})
+	r, err := NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatalf("NewReader() error = %v", err)
+	}
+	s := r.Signatures[0]
+	if !s.Valid() {
+		t.Fatalf("Signature.Valid() error = %v", s.Err)
+	}
+	ts := s.XAdES.Timestamp
+	if ts.Valid() {
+		t.Fatal("Timestamp.Valid() want invalid")
+	}
+	if got := ts.Err.(*Error).Code(); got != 618 {
+		t.Errorf("Timestamp.Err code = %d, want 618", got)
+	}
+}
+
+func TestWriter_Close_TimestampError(t *testing.T) {
+	tests := []struct {
+		name string
+		tsa  TimestampAuthority
+	}{
+		{"tsaError", failingTSA{errors.New("unavailable")}},
+		{"invalidToken", failingTSA{}},
+		{"wrongImprint", wrongImprintTSA{newTestTSA(t)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := newTestRSAKey(t)
+			w := NewWriter(new(bytes.Buffer))
+			w.Sign(key, []*x509.Certificate{newTestCertificate(t, key)}, &SignatureOptions{XAdES: &XAdESOptions{TimestampAuthority: tt.tsa}})
+			pw, _ := w.Create("/a.xml", "application/xml")
+			pw.Write([]byte("<a/>"))
+			if err := w.Close(); err == nil {
+				t.Error("Writer.Close() want error")
+			}
+		})
+	}
+}
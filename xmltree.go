@@ -0,0 +1,314 @@
+package opc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+
+	"github.com/qmuntal/opc/c14n"
+)
+
+const xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+
+// xmlElement is an XML element that preserves the namespace prefixes and declarations
+// of the original document, which are lost when using the encoding/xml unmarshaler
+// and are required to compute the canonical form of any subset of the document.
+// The root of a parsed document is an element without name.
+type xmlElement struct {
+	Name     xml.Name   // Space holds the prefix, not the namespace URI.
+	Attr     []xml.Attr // Space holds the prefix, including the namespace declarations.
+	Children []xml.Token
+	parent   *xmlElement
+}
+
+// parseXMLTree decodes the document read from r, whose well-formedness
+// is checked by the decoder.
+func parseXMLTree(r io.Reader) (*xmlElement, error) {
+	doc := new(xmlElement)
+	cur := doc
+	d := c14n.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &xmlElement{Name: t.Name, Attr: append([]xml.Attr(nil), t.Attr...), parent: cur}
+			cur.Children = append(cur.Children, e)
+			cur = e
+		case xml.EndElement:
+			cur = cur.parent
+		default:
+			cur.Children = append(cur.Children, t)
+		}
+	}
+	return doc, nil
+}
+
+// root returns the document element.
+func (e *xmlElement) root() *xmlElement {
+	for _, c := range e.Children {
+		if ce, ok := c.(*xmlElement); ok {
+			return ce
+		}
+	}
+	return nil
+}
+
+// lookupNamespace returns the namespace URI bound to prefix in the scope of e.
+func (e *xmlElement) lookupNamespace(prefix string) string {
+	if prefix == "xml" {
+		return xmlNamespace
+	}
+	for n := e; n != nil; n = n.parent {
+		for _, a := range n.Attr {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") || (a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// is reports whether e has the given namespace URI and local name.
+func (e *xmlElement) is(space, local string) bool {
+	return e.Name.Local == local && e.lookupNamespace(e.Name.Space) == space
+}
+
+func (e *xmlElement) attr(name string) (string, bool) {
+	for _, a := range e.Attr {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// elements returns the child elements of e with the given namespace URI and local name.
+func (e *xmlElement) elements(space, local string) []*xmlElement {
+	var els []*xmlElement
+	for _, c := range e.Children {
+		if ce, ok := c.(*xmlElement); ok && ce.is(space, local) {
+			els = append(els, ce)
+		}
+	}
+	return els
+}
+
+// element returns the first child element of e with the given namespace URI and local name.
+func (e *xmlElement) element(space, local string) *xmlElement {
+	if els := e.elements(space, local); len(els) > 0 {
+		return els[0]
+	}
+	return nil
+}
+
+// text returns the concatenation of the character data of e, not including descendants.
+func (e *xmlElement) text() string {
+	var b bytes.Buffer
+	for _, c := range e.Children {
+		if cd, ok := c.(xml.CharData); ok {
+			b.Write(cd)
+		}
+	}
+	return string(b.Bytes())
+}
+
+// findByID returns the element in the subtree of e with an Id attribute equal to id.
+func (e *xmlElement) findByID(id string) []*xmlElement {
+	var found []*xmlElement
+	if v, ok := e.attr("Id"); ok && v == id {
+		found = append(found, e)
+	}
+	for _, c := range e.Children {
+		if ce, ok := c.(*xmlElement); ok {
+			found = append(found, ce.findByID(id)...)
+		}
+	}
+	return found
+}
+
+// inScopeNamespaces returns the namespace declarations visible from e, indexed by prefix.
+func (e *xmlElement) inScopeNamespaces() map[string]string {
+	var chain []*xmlElement
+	for n := e; n != nil; n = n.parent {
+		chain = append(chain, n)
+	}
+	ns := make(map[string]string)
+	for i := len(chain) - 1; i >= 0; i-- {
+		chain[i].addNamespaces(ns)
+	}
+	return ns
+}
+
+func (e *xmlElement) addNamespaces(ns map[string]string) {
+	for _, a := range e.Attr {
+		if a.Name.Space == "" && a.Name.Local == "xmlns" {
+			ns[""] = a.Value
+		} else if a.Name.Space == "xmlns" {
+			ns[a.Name.Local] = a.Value
+		}
+	}
+}
+
+// canonicalize returns the canonical form of e using the method m. If e is not the document
+// it is the apex of a document subset that inherits the context of its ancestors.
+// The inclusive prefixes are the InclusiveNamespaces PrefixList of the exclusive methods.
+func canonicalize(e *xmlElement, m c14n.Method, inclusive ...string) ([]byte, error) {
+	var b bytes.Buffer
+	enc := c14n.NewEncoder(&b, m)
+	enc.SetInclusiveNamespaces(inclusive...)
+	var context []xml.Attr
+	for n := e.parent; n != nil; n = n.parent {
+		context = append(context, n.Attr...)
+	}
+	enc.SetContext(context)
+	if err := e.encode(enc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (e *xmlElement) encode(enc *c14n.Encoder) error {
+	isDoc := e.parent == nil && e.Name.Local == ""
+	if !isDoc {
+		if err := enc.EncodeToken(xml.StartElement{Name: e.Name, Attr: e.Attr}); err != nil {
+			return err
+		}
+	}
+	for _, c := range e.Children {
+		var err error
+		if ce, ok := c.(*xmlElement); ok {
+			err = ce.encode(enc)
+		} else {
+			err = enc.EncodeToken(c)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if isDoc {
+		return nil
+	}
+	return enc.EncodeToken(xml.EndElement{Name: e.Name})
+}
+
+// splitQName splits a qualified name into its prefix, stored in Space, and its local part.
+func splitQName(qname string) xml.Name {
+	if i := strings.IndexByte(qname, ':'); i > 0 {
+		return xml.Name{Space: qname[:i], Local: qname[i+1:]}
+	}
+	return xml.Name{Local: qname}
+}
+
+// childElements returns all the child elements of e.
+func (e *xmlElement) childElements() []*xmlElement {
+	var els []*xmlElement
+	for _, c := range e.Children {
+		if ce, ok := c.(*xmlElement); ok {
+			els = append(els, ce)
+		}
+	}
+	return els
+}
+
+// xmlSpan is the byte range of an element in a document.
+// The content goes from contentStart to contentEnd, which equals end if the element is empty.
+type xmlSpan struct {
+	start, contentStart, contentEnd, end int
+}
+
+// selfClosing reports whether the element is written as an empty-element tag.
+func (s xmlSpan) selfClosing() bool {
+	return s.contentEnd == s.end
+}
+
+// scanXMLElements returns the byte ranges of the document element of doc
+// and of its child elements, in document order.
+func scanXMLElements(doc []byte) (xmlSpan, []xmlSpan, error) {
+	var (
+		root     xmlSpan
+		children []xmlSpan
+		depth    int
+	)
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		start := int(d.InputOffset())
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return root, nil, err
+		}
+		end := int(d.InputOffset())
+		switch tok.(type) {
+		case xml.StartElement:
+			depth++
+			switch depth {
+			case 1:
+				root = xmlSpan{start: start, contentStart: end}
+			case 2:
+				children = append(children, xmlSpan{start: start, contentStart: end})
+			}
+		case xml.EndElement:
+			switch depth {
+			case 1:
+				root.contentEnd, root.end = start, end
+			case 2:
+				children[len(children)-1].contentEnd, children[len(children)-1].end = start, end
+			}
+			depth--
+		}
+	}
+	return root, children, nil
+}
+
+// writeXMLElement writes e and its descendants, escaping the character data.
+func writeXMLElement(b *bytes.Buffer, e *xmlElement) {
+	b.WriteString("<" + qualifiedName(e.Name))
+	for _, a := range e.Attr {
+		b.WriteByte(' ')
+		writeXMLAttr(b, a)
+	}
+	b.WriteByte('>')
+	writeXMLContent(b, e)
+	b.WriteString("</" + qualifiedName(e.Name) + ">")
+}
+
+// writeXMLContent writes the character data and the child elements of e.
+func writeXMLContent(b *bytes.Buffer, e *xmlElement) {
+	for _, c := range e.Children {
+		switch t := c.(type) {
+		case *xmlElement:
+			writeXMLElement(b, t)
+		case xml.CharData:
+			xml.EscapeText(b, t)
+		}
+	}
+}
+
+func writeXMLAttr(b *bytes.Buffer, a xml.Attr) {
+	b.WriteString(qualifiedName(a.Name) + `="`)
+	xml.EscapeText(b, []byte(a.Value))
+	b.WriteByte('"')
+}
+
+func qualifiedName(n xml.Name) string {
+	if n.Space == "" {
+		return n.Local
+	}
+	return n.Space + ":" + n.Local
+}
+
+func isXMLSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}